@@ -0,0 +1,761 @@
+// Package aip reads and writes query parameters following Google's API Improvement Proposals, so that handlers can serve AIP-style APIs, such as gRPC gateways, without depending on the query string format.
+//
+// The following query parameters are supported:
+//
+//	filter=serves >= 4 AND title = "Bolognese"    Read into Page.FilterExpr, and Page.Filters if it is a simple conjunction (AIP-160)
+//	order_by=serves desc, title                   Read into Page.Sorts (AIP-132)
+//	page_size=10&page_token=...                   Read into Page.Pagination (AIP-158)
+//
+// Page tokens are opaque to clients. They record an offset along with a digest of the filter and sorts, so a token cannot be reused with a different query.
+// Use NextPageToken to create the next_page_token of a response.
+// Set ReadPageOptions.TokenSigner to sign page tokens, so that clients cannot forge them.
+//
+// Errors are reported as a *qs.ParseError wrapping the equivalent qs query error, such as qs.ErrInvalidFilter.
+package aip
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// Query string key.
+const (
+	FilterKey    = "filter"
+	OrderByKey   = "order_by"
+	PageSizeKey  = "page_size"
+	PageTokenKey = "page_token"
+)
+
+// operators maps AIP-160 comparators to qs filter operators.
+// The has comparator : is handled separately.
+var operators = map[string]string{
+	"=":  "eq",
+	"!=": "neq",
+	">":  "gt",
+	">=": "gte",
+	"<":  "lt",
+	"<=": "lte",
+}
+
+// formatOperators maps qs filter operators to AIP-160 comparators.
+var formatOperators = map[string]string{
+	"eq":       "=",
+	"neq":      "!=",
+	"gt":       ">",
+	"gte":      ">=",
+	"lt":       "<",
+	"lte":      "<=",
+	"like":     "=",
+	"not like": "!=",
+}
+
+// ReadPageOptions configures the behaviour of ReadPage.
+type ReadPageOptions struct {
+	DefaultPageSize int // Page size used if page_size is not provided or is 0
+	MaxPageSize     int // If this is > 0, the page size is clamped to this maximum value
+	MaxFilters      int // If this is > 0, a maximum number of comparisons is imposed
+	MaxSorts        int // If this is > 0, a maximum number of sorts is imposed
+
+	Schema        *qs.Schema       // If set, the page is validated against this schema.
+	CollectErrors bool             // If true, all errors are returned together as qs.Errors instead of only the first error.
+	TokenSigner   *qs.CursorSigner // If set, page tokens must be signed and are verified by this signer
+}
+
+// ReadPage parses URL values containing AIP query parameters into a qs.Page.
+// Pagination is always set, even if neither page_size nor page_token is provided.
+//
+// If a page token was created for a different filter or order_by, it is rejected with qs.ErrInvalidCursor.
+// If a TokenSigner is configured, the page token is verified, returning qs.ErrTamperedCursor or qs.ErrExpiredCursor if it is not valid.
+func ReadPage(values url.Values, opt *ReadPageOptions) (*qs.Page, error) {
+	if opt == nil {
+		opt = &ReadPageOptions{}
+	}
+
+	page := &qs.Page{Pagination: &qs.Pagination{Limit: opt.DefaultPageSize}}
+	errs := qs.Errors{}
+
+	if values.Has(PageSizeKey) {
+		size, err := qs.ReadNumber(values, PageSizeKey, qs.ErrInvalidLimit)
+		if err != nil {
+			errs = append(errs, err)
+		} else if size > 0 {
+			page.Pagination.Limit = size
+		}
+	}
+	if opt.MaxPageSize > 0 && page.Pagination.Limit > opt.MaxPageSize {
+		page.Pagination.Limit = opt.MaxPageSize
+	}
+
+	and := qs.And{}
+	count := 0
+	for i, s := range values[FilterKey] {
+		expr, err := ParseFilter(s)
+		if err != nil {
+			errs = append(errs, qs.WithKey(err, FilterKey, i))
+			continue
+		}
+		if expr == nil {
+			continue
+		}
+		count += qs.CountFilters(expr)
+		if opt.MaxFilters > 0 && count > opt.MaxFilters {
+			errs = append(errs, qs.NewParseError(FilterKey, i, s, 0, qs.ReasonTooMany, qs.ErrTooManyFilters))
+			break
+		}
+		and = append(and, expr)
+	}
+	if len(and) == 1 {
+		page.FilterExpr = and[0]
+	} else if len(and) > 1 {
+		page.FilterExpr = and
+	}
+	// Flat filters are only provided if the expression is a simple conjunction
+	page.Filters, _ = qs.FlattenFilters(page.FilterExpr)
+
+	for i, s := range values[OrderByKey] {
+		sorts, err := ParseOrderBy(s)
+		if err != nil {
+			errs = append(errs, qs.WithKey(err, OrderByKey, i))
+			continue
+		}
+		if opt.MaxSorts > 0 && len(page.Sorts)+len(sorts) > opt.MaxSorts {
+			errs = append(errs, qs.NewParseError(OrderByKey, i, s, 0, qs.ReasonTooMany, qs.ErrTooManySorts))
+			break
+		}
+		page.Sorts = append(page.Sorts, sorts...)
+	}
+
+	if token := values.Get(PageTokenKey); token != "" {
+		offset, err := readPageToken(token, page, len(errs) == 0, opt.TokenSigner)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		page.Pagination.Offset = offset
+	}
+
+	if len(errs) > 0 && !opt.CollectErrors {
+		return nil, errs[0]
+	}
+
+	if opt.Schema != nil && len(errs) == 0 {
+		if err := opt.Schema.ValidatePage(page); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return page, nil
+	}
+	if !opt.CollectErrors {
+		return nil, errs[0]
+	}
+	return nil, errs
+}
+
+// ReadRequestPage parses a request's query string containing AIP query parameters into a qs.Page.
+func ReadRequestPage(req *http.Request, opt *ReadPageOptions) (*qs.Page, error) {
+	return ReadPage(req.URL.Query(), opt)
+}
+
+// ReadStringPage parses a query string literal containing AIP query parameters into a qs.Page.
+func ReadStringPage(s string, opt *ReadPageOptions) (*qs.Page, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPage(values, opt)
+}
+
+// Values returns a page as URL values containing AIP query parameters.
+// The filter expression is written in preference to flat filters, and the offset is written as a page token.
+// Zero values are omitted. Joins, fields and search are not written.
+//
+// If opt has a TokenSigner, the page token is signed with it, so that the values can be read using the same options.
+func Values(page *qs.Page, opt *ReadPageOptions) (url.Values, error) {
+	values := url.Values{}
+
+	filter, err := formatPageFilter(page)
+	if err != nil {
+		return nil, err
+	}
+	if filter != "" {
+		values.Set(FilterKey, filter)
+	}
+	if len(page.Sorts) > 0 {
+		values.Set(OrderByKey, FormatOrderBy(page.Sorts))
+	}
+
+	if page.Pagination != nil {
+		if page.Pagination.Limit > 0 {
+			values.Set(PageSizeKey, strconv.Itoa(page.Pagination.Limit))
+		}
+		if page.Pagination.Offset > 0 {
+			token, err := encodePageToken(page, page.Pagination.Offset, opt)
+			if err != nil {
+				return nil, err
+			}
+			values.Set(PageTokenKey, token)
+		}
+	}
+
+	return values, nil
+}
+
+// NextPageToken returns the page token for the page following a page of results, for use as the next_page_token of a response.
+// n is the number of results in the page. If it is less than the page size, there are no more results and this function returns an empty string.
+// If opt has a TokenSigner, the token is signed with it; use the same options to read the next page.
+func NextPageToken(page *qs.Page, n int, opt *ReadPageOptions) (string, error) {
+	if page.Pagination == nil || page.Pagination.Limit == 0 || n < page.Pagination.Limit {
+		return "", nil
+	}
+	return encodePageToken(page, page.Pagination.Offset+page.Pagination.Limit, opt)
+}
+
+// ParseFilter parses an AIP-160 filter expression, such as:
+//
+//	(status = "draft" OR author = 3) AND NOT title:soup
+//
+// Restrictions compare a field with a value using the comparators =, !=, >, >=, < and <=, which map to the qs operators eq, neq, gt, gte, lt and lte.
+// In = and != comparisons, * is a wildcard, and the comparison maps to like or not like.
+// The has comparator : maps to a like filter matching values that contain the argument, so title:soup is read as title like %soup%.
+// A presence test, such as title:*, maps to a pr filter.
+//
+// Restrictions can be combined with AND, OR and NOT (or a leading -), and grouped with parentheses.
+// Restrictions separated only by whitespace are combined with AND.
+// As specified by AIP-160, OR binds more tightly than AND, so a AND b OR c is read as a AND (b OR c).
+//
+// Values may be quoted with either single or double quotes, within which \ escapes the following character, so \* matches a literal asterisk.
+// Traversal of nested fields, functions and global restrictions are not supported.
+//
+// This function returns nil if the expression is empty.
+// If the expression is invalid, it returns a *qs.ParseError wrapping qs.ErrInvalidFilter.
+func ParseFilter(s string) (qs.FilterExpr, error) {
+	p := &parser{input: s}
+	p.skipSpace()
+	if p.pos == len(s) {
+		return nil, nil
+	}
+
+	expr, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(s) {
+		return nil, p.errorAt(p.pos, qs.ReasonUnexpectedToken)
+	}
+	return expr, nil
+}
+
+// ParseOrderBy parses an AIP-132 order_by expression, such as serves desc, title.
+// If a direction is not specified, the sort is ascending.
+//
+// This function returns nil if the expression is empty.
+// If the expression is invalid, it returns a *qs.ParseError wrapping qs.ErrInvalidSort.
+func ParseOrderBy(s string) (qs.Sorts, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	sorts := qs.Sorts{}
+	start := 0
+	for _, item := range strings.Split(s, ",") {
+		words := strings.Fields(item)
+		if len(words) == 0 || !qs.IsField(words[0]) {
+			offset := start + len(item) - len(strings.TrimLeft(item, " "))
+			return nil, qs.NewParseError("", 0, s, offset, qs.ReasonBadField, qs.ErrInvalidSort)
+		}
+
+		sort := qs.Sort{Field: words[0], Direction: "asc"}
+		if len(words) > 1 {
+			if len(words) > 2 || words[1] != "asc" && words[1] != "desc" {
+				end := strings.Index(item, words[0]) + len(words[0])
+				offset := start + end + strings.Index(item[end:], words[1])
+				return nil, qs.NewParseError("", 0, s, offset, qs.ReasonBadDirection, qs.ErrInvalidSort)
+			}
+			sort.Direction = words[1]
+		}
+		sorts = append(sorts, sort)
+		start += len(item) + 1
+	}
+	return sorts, nil
+}
+
+// Format returns a filter expression as an AIP-160 filter.
+//
+// in and not in filters are written as a group of = or != comparisons.
+// like, co, sw and ew filters are written using the has comparator if possible, or otherwise using * wildcards, and pr filters are written as presence tests.
+// Nested junctions are always grouped with parentheses.
+// If the expression uses an operator that cannot be written, this function returns qs.ErrUnsupportedOperator.
+func Format(expr qs.FilterExpr) (string, error) {
+	switch node := expr.(type) {
+	case qs.Filter:
+		return formatFilter(node)
+	case qs.And:
+		return formatJunction(node, " AND ")
+	case qs.Or:
+		return formatJunction(node, " OR ")
+	case qs.Not:
+		s, err := Format(node.Expr)
+		if err != nil {
+			return "", err
+		}
+		if isJunction(node.Expr) {
+			s = "(" + s + ")"
+		}
+		return "NOT " + s, nil
+	}
+	return "", qs.ErrUnsupportedExpr
+}
+
+// FormatOrderBy returns sorts as an AIP-132 order_by expression, such as serves desc, title.
+func FormatOrderBy(sorts qs.Sorts) string {
+	strs := []string{}
+	for _, sort := range sorts {
+		if sort.Direction == "desc" {
+			strs = append(strs, sort.Field+" desc")
+		} else {
+			strs = append(strs, sort.Field)
+		}
+	}
+	return strings.Join(strs, ", ")
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorAt(offset int, reason qs.Reason) error {
+	return qs.NewParseError("", 0, p.input, offset, reason, qs.ErrInvalidFilter)
+}
+
+// keyword advances past a keyword, such as AND, if it is next in the input and followed by whitespace or a parenthesis.
+func (p *parser) keyword(word string) bool {
+	if !strings.HasPrefix(p.input[p.pos:], word) {
+		return false
+	}
+	end := p.pos + len(word)
+	if end < len(p.input) && !isSpace(p.input[end]) && p.input[end] != '(' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// skip advances past a character, if it is next in the input.
+func (p *parser) skip(c byte) bool {
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+// parseExpression parses sequences separated by AND.
+func (p *parser) parseExpression() (qs.FilterExpr, error) {
+	and := qs.And{}
+	for {
+		expr, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr...)
+
+		pos := p.pos
+		p.skipSpace()
+		if !p.keyword("AND") {
+			p.pos = pos
+			break
+		}
+		p.skipSpace()
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// parseSequence parses factors separated only by whitespace, which are implicitly combined with AND.
+func (p *parser) parseSequence() ([]qs.FilterExpr, error) {
+	sequence := []qs.FilterExpr{}
+	for {
+		expr, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		sequence = append(sequence, expr)
+
+		pos := p.pos
+		p.skipSpace()
+		if p.pos == pos || p.pos == len(p.input) || p.input[p.pos] == ')' || p.keyword("AND") {
+			p.pos = pos
+			return sequence, nil
+		}
+	}
+}
+
+// parseFactor parses terms separated by OR.
+func (p *parser) parseFactor() (qs.FilterExpr, error) {
+	expr, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	or := qs.Or{expr}
+	for {
+		pos := p.pos
+		p.skipSpace()
+		if !p.keyword("OR") {
+			p.pos = pos
+			break
+		}
+		p.skipSpace()
+		expr, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseTerm() (qs.FilterExpr, error) {
+	if p.keyword("NOT") {
+		p.skipSpace()
+	} else if !p.skip('-') {
+		return p.parseSimple()
+	}
+
+	expr, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	return qs.Not{Expr: expr}, nil
+}
+
+func (p *parser) parseSimple() (qs.FilterExpr, error) {
+	if open := p.pos; p.skip('(') {
+		p.skipSpace()
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.skip(')') {
+			return nil, p.errorAt(open, qs.ReasonUnclosedGroup)
+		}
+		return expr, nil
+	}
+	return p.parseRestriction()
+}
+
+func (p *parser) parseRestriction() (qs.FilterExpr, error) {
+	start := p.pos
+	n := scanName(p.input[p.pos:])
+	field := p.input[p.pos : p.pos+n]
+	if !qs.IsField(field) {
+		return nil, p.errorAt(start, qs.ReasonBadField)
+	}
+	p.pos += n
+	p.skipSpace()
+
+	opStart := p.pos
+	op := scanComparator(p.input[p.pos:])
+	if op == "" {
+		return nil, p.errorAt(opStart, qs.ReasonMissingOperator)
+	}
+	p.pos += len(op)
+	p.skipSpace()
+
+	value, pattern, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if op == ":" {
+		if pattern == "%" {
+			return qs.Filter{Field: field, Operator: "pr"}, nil
+		}
+		return qs.Filter{Field: field, Operator: "like", Value: "%" + qs.EscapeLike(value) + "%"}, nil
+	}
+
+	operator := operators[op]
+	if pattern != "" && operator == "eq" {
+		return qs.Filter{Field: field, Operator: "like", Value: pattern}, nil
+	}
+	if pattern != "" && operator == "neq" {
+		return qs.Filter{Field: field, Operator: "not like", Value: pattern}, nil
+	}
+	return qs.Filter{Field: field, Operator: operator, Value: value}, nil
+}
+
+// parseValue parses a quoted or unquoted argument.
+// If the argument contains a wildcard, it is also returned as a like pattern; otherwise, the pattern is empty.
+func (p *parser) parseValue() (string, string, error) {
+	value := strings.Builder{}
+	pattern := strings.Builder{}
+	wildcard := false
+
+	if p.pos < len(p.input) && (p.input[p.pos] == '"' || p.input[p.pos] == '\'') {
+		quote := p.input[p.pos]
+		start := p.pos
+		for p.pos++; p.pos < len(p.input) && p.input[p.pos] != quote; p.pos++ {
+			c := p.input[p.pos]
+			if c == '\\' && p.pos+1 < len(p.input) {
+				p.pos++
+				c = p.input[p.pos]
+				value.WriteByte(c)
+				pattern.WriteString(qs.EscapeLike(string(c)))
+				continue
+			}
+			value.WriteByte(c)
+			if c == '*' {
+				pattern.WriteByte('%')
+				wildcard = true
+			} else {
+				pattern.WriteString(qs.EscapeLike(string(c)))
+			}
+		}
+		if !p.skip(quote) {
+			return "", "", p.errorAt(start, qs.ReasonUnterminatedQuote)
+		}
+	} else {
+		n := scanText(p.input[p.pos:])
+		if n == 0 {
+			return "", "", p.errorAt(p.pos, qs.ReasonMissingValue)
+		}
+		for _, c := range []byte(p.input[p.pos : p.pos+n]) {
+			value.WriteByte(c)
+			if c == '*' {
+				pattern.WriteByte('%')
+				wildcard = true
+			} else {
+				pattern.WriteString(qs.EscapeLike(string(c)))
+			}
+		}
+		p.pos += n
+	}
+
+	if !wildcard {
+		return value.String(), "", nil
+	}
+	return value.String(), pattern.String(), nil
+}
+
+// formatJunction writes the operands of an And or Or, joined with a separator.
+func formatJunction(operands []qs.FilterExpr, sep string) (string, error) {
+	if len(operands) == 0 {
+		// An empty junction is always true or false, which cannot be written
+		return "", qs.ErrUnsupportedExpr
+	}
+
+	strs := []string{}
+	for _, operand := range operands {
+		s, err := Format(operand)
+		if err != nil {
+			return "", err
+		}
+		if len(operands) > 1 && isJunction(operand) {
+			s = "(" + s + ")"
+		}
+		strs = append(strs, s)
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func formatFilter(filter qs.Filter) (string, error) {
+	switch filter.Operator {
+	case "co", "sw", "ew":
+		filter = qs.Filter{Field: filter.Field, Operator: "like", Value: filter.LikePattern()}
+	case "pr":
+		return filter.Field + ":*", nil
+	}
+
+	switch filter.Operator {
+	case "in", "not in":
+		op, sep := " = ", " OR "
+		if filter.Operator == "not in" {
+			op, sep = " != ", " AND "
+		}
+		values, _ := filter.StringSlice()
+		for i, value := range values {
+			values[i] = filter.Field + op + quoteValue(value)
+		}
+		if len(values) == 1 {
+			return values[0], nil
+		}
+		return "(" + strings.Join(values, sep) + ")", nil
+	case "like", "not like":
+		segments := filter.LikeSegments()
+		if filter.Operator == "like" && len(segments) == 3 && segments[0] == "" && segments[1] != "" && segments[2] == "" {
+			return filter.Field + ":" + quoteValue(segments[1]), nil
+		}
+		for i, segment := range segments {
+			segments[i] = valueEscaper.Replace(segment)
+		}
+		return filter.Field + " " + formatOperators[filter.Operator] + ` "` + strings.Join(segments, "*") + `"`, nil
+	}
+
+	op, ok := formatOperators[filter.Operator]
+	if !ok {
+		return "", qs.ErrUnsupportedOperator
+	}
+	return filter.Field + " " + op + " " + quoteValue(filter.Value), nil
+}
+
+// formatPageFilter returns the filter expression of a page, or its flat filters if it has no expression.
+func formatPageFilter(page *qs.Page) (string, error) {
+	if page.FilterExpr != nil {
+		return Format(page.FilterExpr)
+	}
+	if len(page.Filters) > 0 {
+		return Format(page.Filters.Expr())
+	}
+	return "", nil
+}
+
+// isJunction returns true if an expression is an And or Or with more than one operand.
+func isJunction(expr qs.FilterExpr) bool {
+	switch node := expr.(type) {
+	case qs.And:
+		return len(node) > 1
+	case qs.Or:
+		return len(node) > 1
+	}
+	return false
+}
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "*", `\*`)
+
+// quoteValue quotes an argument unless it consists only of letters, digits and the characters . - _ : +, and is not a keyword.
+func quoteValue(value string) string {
+	plain := value != "" && value != "AND" && value != "OR" && value != "NOT"
+	for i := 0; plain && i < len(value); i++ {
+		c := value[i]
+		plain = c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte(".-_:+", c) >= 0
+	}
+	if plain {
+		return value
+	}
+	return `"` + valueEscaper.Replace(value) + `"`
+}
+
+// Page token value.
+// Page tokens are encoded as qs cursors with these values, so that they can be signed by a qs.CursorSigner.
+const (
+	tokenOffset = "o"
+	tokenQuery  = "q"
+)
+
+func encodePageToken(page *qs.Page, offset int, opt *ReadPageOptions) (string, error) {
+	query, err := pageQuery(page)
+	if err != nil {
+		return "", err
+	}
+	cursor := &qs.Cursor{Values: map[string]string{tokenOffset: strconv.Itoa(offset), tokenQuery: query}}
+	if opt != nil && opt.TokenSigner != nil {
+		return opt.TokenSigner.Encode(cursor)
+	}
+	return cursor.Encode(), nil
+}
+
+// pageQuery returns a digest of a page's filter and sorts, which binds a page token to the query it was created for.
+// The formatted filter is parsed and formatted again before it is digested.
+// This ensures that a page read from Values has the same digest as the page that was written, even if formatting changed the expression, such as by expanding an in filter.
+func pageQuery(page *qs.Page) (string, error) {
+	filter, err := formatPageFilter(page)
+	if err != nil {
+		return "", err
+	}
+	if filter != "" {
+		expr, err := ParseFilter(filter)
+		if err != nil {
+			return "", err
+		}
+		if filter, err = Format(expr); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256([]byte(filter + "\n" + FormatOrderBy(page.Sorts)))
+	return base64.RawURLEncoding.EncodeToString(sum[:8]), nil
+}
+
+// readPageToken decodes a page token and returns its offset.
+// If verify is true, the token must have been created for the same filter and sorts as the page.
+func readPageToken(token string, page *qs.Page, verify bool, signer *qs.CursorSigner) (int, error) {
+	var cursor *qs.Cursor
+	var err error
+	if signer != nil {
+		cursor, err = signer.Decode(token)
+	} else {
+		cursor, err = qs.DecodeCursor(token)
+	}
+	if err != nil {
+		return 0, qs.NewParseError(PageTokenKey, 0, token, 0, qs.ReasonBadCursor, err)
+	}
+
+	invalid := qs.NewParseError(PageTokenKey, 0, token, 0, qs.ReasonBadCursor, qs.ErrInvalidCursor)
+	offset, err := strconv.Atoi(cursor.Values[tokenOffset])
+	if err != nil || offset < 0 {
+		return 0, invalid
+	}
+
+	if verify {
+		query, err := pageQuery(page)
+		if err != nil || query != cursor.Values[tokenQuery] {
+			return 0, invalid
+		}
+	}
+	return offset, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// scanComparator returns the comparator at the start of a string, or an empty string if there is none.
+func scanComparator(s string) string {
+	for _, op := range []string{"<=", ">=", "!=", "<", ">", "=", ":"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// scanName returns the length of the field name at the start of a string.
+// Dots are included, so that traversals can be reported as invalid fields.
+func scanName(s string) int {
+	n := 0
+	for n < len(s) && (s[n] >= 'A' && s[n] <= 'Z' || s[n] >= 'a' && s[n] <= 'z' || s[n] >= '0' && s[n] <= '9' || s[n] == '_' || s[n] == '.') {
+		n++
+	}
+	return n
+}
+
+// scanText returns the length of the unquoted argument at the start of a string, which ends at whitespace or a parenthesis.
+func scanText(s string) int {
+	n := 0
+	for n < len(s) && !isSpace(s[n]) && s[n] != '(' && s[n] != ')' && s[n] != '"' && s[n] != '\'' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,442 @@
+package aip
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestReadPage(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output *qs.Page
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{Input: "filter=&order_by=", Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{
+			Input: `filter=serves >= 4 AND title = "Bolognese"&order_by=serves desc, title&page_size=10`,
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{Limit: 10},
+				Filters:    qs.Filters{{Field: "serves", Operator: "gte", Value: "4"}, {Field: "title", Operator: "eq", Value: "Bolognese"}},
+				FilterExpr: qs.And{qs.Filter{Field: "serves", Operator: "gte", Value: "4"}, qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}},
+				Sorts:      qs.Sorts{{Field: "serves", Direction: "desc"}, {Field: "title", Direction: "asc"}},
+			},
+		},
+		{
+			Input: "filter=title:soup OR serves < 2",
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				FilterExpr: qs.Or{qs.Filter{Field: "title", Operator: "like", Value: "%soup%"}, qs.Filter{Field: "serves", Operator: "lt", Value: "2"}},
+			},
+		},
+		{Input: "", Opt: &ReadPageOptions{DefaultPageSize: 20}, Output: &qs.Page{Pagination: &qs.Pagination{Limit: 20}}},
+		{Input: "page_size=0", Opt: &ReadPageOptions{DefaultPageSize: 20}, Output: &qs.Page{Pagination: &qs.Pagination{Limit: 20}}},
+		{Input: "page_size=500", Opt: &ReadPageOptions{MaxPageSize: 100}, Output: &qs.Page{Pagination: &qs.Pagination{Limit: 100}}},
+		{
+			Input: "filter=title = Soup&filter=serves > 2&filter=serves < 6",
+			Opt:   &ReadPageOptions{MaxFilters: 2},
+			Err:   qs.ErrTooManyFilters,
+		},
+		{Input: "order_by=serves desc, title", Opt: &ReadPageOptions{MaxSorts: 1}, Err: qs.ErrTooManySorts},
+		{Input: "page_size=ten", Err: qs.ErrInvalidLimit},
+		{Input: "page_size=-1", Err: qs.ErrInvalidLimit},
+		{Input: "page_token=nope", Err: qs.ErrInvalidCursor},
+		{Input: "filter=title = ", Err: qs.ErrInvalidFilter},
+		{Input: "order_by=title up", Err: qs.ErrInvalidSort},
+		{
+			Input: "order_by=rating",
+			Opt:   &ReadPageOptions{Schema: &qs.Schema{Fields: map[string]qs.Field{"rating": {Filter: true}}}},
+			Err:   qs.ErrSortNotAllowed,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := ReadStringPage(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if !reflect.DeepEqual(page, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, page)
+		}
+	}
+}
+
+func TestReadPageErrors(t *testing.T) {
+	_, err := ReadStringPage("filter=serves ~ 4&order_by=title,&page_size=ten", &ReadPageOptions{CollectErrors: true})
+
+	errs := qs.Errors{}
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected qs.Errors, got %v", err)
+	}
+
+	expected := []*qs.ParseError{
+		{Key: "page_size", Input: "ten", Reason: qs.ReasonBadNumber, Err: qs.ErrInvalidLimit},
+		{Key: "filter", Input: "serves ~ 4", Offset: 7, Reason: qs.ReasonMissingOperator, Err: qs.ErrInvalidFilter},
+		{Key: "order_by", Input: "title,", Offset: 6, Reason: qs.ReasonBadField, Err: qs.ErrInvalidSort},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !reflect.DeepEqual(err, expected[i]) {
+			t.Errorf("Expected %+v, got %+v", expected[i], err)
+		}
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	page := &qs.Page{
+		Pagination: &qs.Pagination{Limit: 10, Offset: 20},
+		FilterExpr: qs.Filter{Field: "serves", Operator: "gte", Value: "4"},
+		Sorts:      qs.Sorts{{Field: "title", Direction: "asc"}},
+	}
+
+	token, err := NextPageToken(page, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" {
+		t.Fatal("Expected next page token, got empty string")
+	}
+
+	values := url.Values{"filter": {"serves >= 4"}, "order_by": {"title asc"}, "page_size": {"10"}, "page_token": {token}}
+	next, err := ReadPage(values, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Pagination.Offset != 30 {
+		t.Errorf("Expected offset 30, got %d", next.Pagination.Offset)
+	}
+
+	values.Set("filter", "serves >= 5")
+	if _, err := ReadPage(values, nil); !errors.Is(err, qs.ErrInvalidCursor) {
+		t.Errorf("Expected error %v, got %v", qs.ErrInvalidCursor, err)
+	}
+
+	if token, _ := NextPageToken(page, 9, nil); token != "" {
+		t.Errorf("Expected empty string, got %q", token)
+	}
+}
+
+func TestValues(t *testing.T) {
+	page := &qs.Page{
+		Pagination: &qs.Pagination{Limit: 10, Offset: 20},
+		Filters:    qs.Filters{{Field: "serves", Operator: "gte", Value: "4"}, {Field: "title", Operator: "eq", Value: "Spaghetti Bolognese"}},
+		Sorts:      qs.Sorts{{Field: "serves", Direction: "desc"}, {Field: "title", Direction: "asc"}},
+	}
+
+	values, err := Values(page, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := values.Get("filter"); s != `serves >= 4 AND title = "Spaghetti Bolognese"` {
+		t.Errorf("Expected filter %q, got %q", `serves >= 4 AND title = "Spaghetti Bolognese"`, s)
+	}
+	if s := values.Get("order_by"); s != "serves desc, title" {
+		t.Errorf("Expected order_by %q, got %q", "serves desc, title", s)
+	}
+
+	read, err := ReadPage(values, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read.Filters, page.Filters) || !reflect.DeepEqual(read.Sorts, page.Sorts) || *read.Pagination != *page.Pagination {
+		t.Errorf("Expected %+v, got %+v", page, read)
+	}
+
+	if _, err := Values(&qs.Page{FilterExpr: qs.Filter{Field: "title", Operator: "is", Value: "x"}}, nil); !errors.Is(err, qs.ErrUnsupportedOperator) {
+		t.Errorf("Expected error %v, got %v", qs.ErrUnsupportedOperator, err)
+	}
+}
+
+func TestValuesPageToken(t *testing.T) {
+	type TestCase struct {
+		Input *qs.Page
+		Opt   *ReadPageOptions
+	}
+
+	signer := &qs.CursorSigner{Keys: map[string][]byte{"1": []byte("secret")}, KeyID: "1"}
+	pag := &qs.Pagination{Limit: 10, Offset: 20}
+	sorts := qs.Sorts{{Field: "title", Direction: "asc"}}
+
+	testCases := []TestCase{
+		{Input: &qs.Page{Pagination: pag, Sorts: sorts}},
+		{Input: &qs.Page{Pagination: pag, Filters: qs.Filters{{Field: "author", Operator: "in", Value: "1,2"}}, Sorts: sorts}},
+		{Input: &qs.Page{Pagination: pag, Filters: qs.Filters{{Field: "author", Operator: "not in", Value: "1,2"}}, Sorts: sorts}},
+		{Input: &qs.Page{Pagination: pag, FilterExpr: qs.And{
+			qs.Filter{Field: "author", Operator: "in", Value: "1,2"},
+			qs.Filter{Field: "status", Operator: "not in", Value: "draft,deleted"},
+			qs.Filter{Field: "title", Operator: "co", Value: "Soup"},
+		}}},
+		{Input: &qs.Page{Pagination: pag, Filters: qs.Filters{{Field: "author", Operator: "in", Value: "1,2"}}}, Opt: &ReadPageOptions{TokenSigner: signer}},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("(%d) Testing %+v with options %+v", i, tc.Input, tc.Opt)
+
+		values, err := Values(tc.Input, tc.Opt)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		read, err := ReadPage(values, tc.Opt)
+		if err != nil {
+			t.Errorf("Expected no error reading %v, got %v", values, err)
+			continue
+		}
+		if read.Pagination.Offset != tc.Input.Pagination.Offset {
+			t.Errorf("Expected offset %d, got %d", tc.Input.Pagination.Offset, read.Pagination.Offset)
+		}
+
+		token, err := NextPageToken(read, 10, tc.Opt)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		values.Set("page_token", token)
+		next, err := ReadPage(values, tc.Opt)
+		if err != nil {
+			t.Errorf("Expected no error reading next page, got %v", err)
+			continue
+		}
+		if next.Pagination.Offset != 30 {
+			t.Errorf("Expected offset 30, got %d", next.Pagination.Offset)
+		}
+	}
+}
+
+func TestSignedPageToken(t *testing.T) {
+	signer := &qs.CursorSigner{Keys: map[string][]byte{"1": []byte("secret")}, KeyID: "1"}
+	opt := &ReadPageOptions{TokenSigner: signer}
+	page := &qs.Page{Pagination: &qs.Pagination{Limit: 10, Offset: 20}}
+
+	signed, err := NextPageToken(page, 10, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPage(url.Values{"page_token": {signed}}, opt); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	unsigned, err := NextPageToken(page, 10, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPage(url.Values{"page_token": {unsigned}}, opt); !errors.Is(err, qs.ErrTamperedCursor) {
+		t.Errorf("Expected error %v, got %v", qs.ErrTamperedCursor, err)
+	}
+
+	other := &qs.CursorSigner{Keys: map[string][]byte{"1": []byte("other")}, KeyID: "1"}
+	if _, err := ReadPage(url.Values{"page_token": {signed}}, &ReadPageOptions{TokenSigner: other}); !errors.Is(err, qs.ErrTamperedCursor) {
+		t.Errorf("Expected error %v, got %v", qs.ErrTamperedCursor, err)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.FilterExpr
+		Err    error
+		Offset int
+		Reason qs.Reason
+	}
+
+	testCases := []TestCase{
+		{Input: "  "},
+		{Input: `title = "Bolognese"`, Output: qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}},
+		{Input: "title!='Spaghetti Bolognese'", Output: qs.Filter{Field: "title", Operator: "neq", Value: "Spaghetti Bolognese"}},
+		{Input: `title = "say \"hi\""`, Output: qs.Filter{Field: "title", Operator: "eq", Value: `say "hi"`}},
+		{Input: "serves>4", Output: qs.Filter{Field: "serves", Operator: "gt", Value: "4"}},
+		{Input: "serves >= 4", Output: qs.Filter{Field: "serves", Operator: "gte", Value: "4"}},
+		{Input: "serves < -1", Output: qs.Filter{Field: "serves", Operator: "lt", Value: "-1"}},
+		{Input: "created <= 2012-04-21T11:30:00-04:00", Output: qs.Filter{Field: "created", Operator: "lte", Value: "2012-04-21T11:30:00-04:00"}},
+		{Input: "title:pasta", Output: qs.Filter{Field: "title", Operator: "like", Value: "%pasta%"}},
+		{Input: `title:"100%"`, Output: qs.Filter{Field: "title", Operator: "like", Value: `%100\%%`}},
+		{Input: "rating:*", Output: qs.Filter{Field: "rating", Operator: "pr"}},
+		{Input: "title = Spag*", Output: qs.Filter{Field: "title", Operator: "like", Value: "Spag%"}},
+		{Input: `title != "*a\*b"`, Output: qs.Filter{Field: "title", Operator: "not like", Value: "%a*b"}},
+		{Input: "NOT title = Soup", Output: qs.Not{Expr: qs.Filter{Field: "title", Operator: "eq", Value: "Soup"}}},
+		{Input: "-title:soup", Output: qs.Not{Expr: qs.Filter{Field: "title", Operator: "like", Value: "%soup%"}}},
+		{
+			Input: "title = Soup serves > 2",
+			Output: qs.And{
+				qs.Filter{Field: "title", Operator: "eq", Value: "Soup"},
+				qs.Filter{Field: "serves", Operator: "gt", Value: "2"},
+			},
+		},
+		{
+			Input: "status = draft AND author = 3 OR author = 4",
+			Output: qs.And{
+				qs.Filter{Field: "status", Operator: "eq", Value: "draft"},
+				qs.Or{qs.Filter{Field: "author", Operator: "eq", Value: "3"}, qs.Filter{Field: "author", Operator: "eq", Value: "4"}},
+			},
+		},
+		{
+			Input: "(status = draft AND author = 3) OR NOT(serves < 4)",
+			Output: qs.Or{
+				qs.And{qs.Filter{Field: "status", Operator: "eq", Value: "draft"}, qs.Filter{Field: "author", Operator: "eq", Value: "3"}},
+				qs.Not{Expr: qs.Filter{Field: "serves", Operator: "lt", Value: "4"}},
+			},
+		},
+
+		{Input: "author.name = Anny", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonBadField},
+		{Input: "title", Err: qs.ErrInvalidFilter, Offset: 5, Reason: qs.ReasonMissingOperator},
+		{Input: "title ~ Soup", Err: qs.ErrInvalidFilter, Offset: 6, Reason: qs.ReasonMissingOperator},
+		{Input: "title = ", Err: qs.ErrInvalidFilter, Offset: 8, Reason: qs.ReasonMissingValue},
+		{Input: "title = 'Soup", Err: qs.ErrInvalidFilter, Offset: 8, Reason: qs.ReasonUnterminatedQuote},
+		{Input: "(title = Soup", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonUnclosedGroup},
+		{Input: "title = Soup)", Err: qs.ErrInvalidFilter, Offset: 12, Reason: qs.ReasonUnexpectedToken},
+		{Input: "title = Soup AND", Err: qs.ErrInvalidFilter, Offset: 16, Reason: qs.ReasonBadField},
+		{Input: "title = Soup OR ", Err: qs.ErrInvalidFilter, Offset: 16, Reason: qs.ReasonBadField},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		expr, err := ParseFilter(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			var perr *qs.ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("Expected *qs.ParseError, got %T", err)
+			} else if perr.Offset != tc.Offset || perr.Reason != tc.Reason {
+				t.Errorf("Expected %s at offset %d, got %s at offset %d", tc.Reason, tc.Offset, perr.Reason, perr.Offset)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.Sorts
+		Err    error
+		Offset int
+	}
+
+	testCases := []TestCase{
+		{Input: ""},
+		{Input: "title", Output: qs.Sorts{{Field: "title", Direction: "asc"}}},
+		{Input: "serves desc, title", Output: qs.Sorts{{Field: "serves", Direction: "desc"}, {Field: "title", Direction: "asc"}}},
+		{Input: "serves desc,title asc", Output: qs.Sorts{{Field: "serves", Direction: "desc"}, {Field: "title", Direction: "asc"}}},
+		{Input: "serves desc, ", Err: qs.ErrInvalidSort, Offset: 13},
+		{Input: "author.name", Err: qs.ErrInvalidSort, Offset: 0},
+		{Input: "serves down", Err: qs.ErrInvalidSort, Offset: 7},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		sorts, err := ParseOrderBy(tc.Input)
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			var perr *qs.ParseError
+			if errors.As(err, &perr) && perr.Offset != tc.Offset {
+				t.Errorf("Expected offset %d, got %d", tc.Offset, perr.Offset)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(sorts, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, sorts)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	type TestCase struct {
+		Input  qs.FilterExpr
+		Output string
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}, Output: "title = Bolognese"},
+		{Input: qs.Filter{Field: "title", Operator: "neq", Value: "Spaghetti Bolognese"}, Output: `title != "Spaghetti Bolognese"`},
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: "a*b"}, Output: `title = "a\*b"`},
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: "AND"}, Output: `title = "AND"`},
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: ""}, Output: `title = ""`},
+		{Input: qs.Filter{Field: "serves", Operator: "gte", Value: "4"}, Output: "serves >= 4"},
+		{Input: qs.Filter{Field: "author", Operator: "in", Value: "1,2"}, Output: "(author = 1 OR author = 2)"},
+		{Input: qs.Filter{Field: "status", Operator: "not in", Value: "draft"}, Output: "status != draft"},
+		{Input: qs.Filter{Field: "title", Operator: "like", Value: "%pasta%"}, Output: "title:pasta"},
+		{Input: qs.Filter{Field: "title", Operator: "like", Value: `Spag%`}, Output: `title = "Spag*"`},
+		{Input: qs.Filter{Field: "title", Operator: "not like", Value: "%a*b"}, Output: `title != "*a\*b"`},
+		{Input: qs.Filter{Field: "title", Operator: "co", Value: "100%"}, Output: `title:"100%"`},
+		{Input: qs.Filter{Field: "title", Operator: "sw", Value: "a*b"}, Output: `title = "a\*b*"`},
+		{Input: qs.Filter{Field: "rating", Operator: "pr"}, Output: "rating:*"},
+		{
+			Input: qs.And{
+				qs.Or{qs.Filter{Field: "status", Operator: "eq", Value: "draft"}, qs.Filter{Field: "author", Operator: "eq", Value: "3"}},
+				qs.Not{Expr: qs.Filter{Field: "status", Operator: "not in", Value: "a,b"}},
+			},
+			Output: "(status = draft OR author = 3) AND NOT (status != a AND status != b)",
+		},
+		{
+			Input:  qs.Not{Expr: qs.And{qs.Filter{Field: "a", Operator: "eq", Value: "1"}, qs.Filter{Field: "b", Operator: "lt", Value: "2"}}},
+			Output: "NOT (a = 1 AND b < 2)",
+		},
+		{Input: qs.Filter{Field: "title", Operator: "is", Value: "x"}, Err: qs.ErrUnsupportedOperator},
+		{Input: qs.Or{}, Err: qs.ErrUnsupportedExpr},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v", n, tc.Input)
+
+		s, err := Format(tc.Input)
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if s != tc.Output {
+			t.Errorf("Expected %q, got %q", tc.Output, s)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	inputs := []string{
+		`serves >= 4 AND title = "Bolognese"`,
+		"(status = draft AND author = 3) OR NOT title:soup",
+		`title != "O'Brien\\\*s*" AND created < 2012-04-21T11:30:00Z`,
+	}
+
+	for n, input := range inputs {
+		t.Logf("(%d) Testing %q", n, input)
+
+		expr, err := ParseFilter(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := Format(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reparsed, err := ParseFilter(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expr, reparsed) {
+			t.Errorf("Expected %+v, got %+v from %q", expr, reparsed, s)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package qs
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Apply evaluates a page against a slice of items in memory, as an alternative to querying a database.
+// Items may be structs, pointers to structs, or maps with string keys.
+// Struct fields are matched by qs tag name, json tag name or Go field name, in that order.
+//
+// Items are filtered, then stably sorted, and finally sliced according to pagination.
+// The input slice is not modified.
+// This function returns the resulting items and the total number of items that matched filters before pagination.
+//
+// If pagination has a cursor, items are sought from the cursor position instead of being offset.
+// Items before a cursor are returned in the order given by the sorts, as with items after a cursor.
+//
+// Comparisons follow the field type: numbers are compared numerically, bools and time.Time values are parsed from the filter value, and anything else is compared as a string.
+// Like SQL, nil values do not satisfy any comparison, even if it is negated with Not.
+//
+// Search terms are matched case-insensitively against the field they specify.
+// Terms that do not specify a field cannot be matched without ApplyOptions.SearchFields, so this function returns ErrUnsupportedSearch for them; use ApplyWithOptions instead.
+func Apply[T any](items []T, page *Page) ([]T, int, error) {
+	return ApplyWithOptions(items, page, nil)
+}
+
+// ApplyWithOptions evaluates a page against a slice of items in memory in the same way as Apply, using the given options.
+// A search term that does not specify a field matches if any of ApplyOptions.SearchFields contains it.
+// If there are no SearchFields, this function returns ErrUnsupportedSearch for such terms.
+func ApplyWithOptions[T any](items []T, page *Page, opt *ApplyOptions) ([]T, int, error) {
+	if page == nil {
+		page = &Page{}
+	}
+	if opt == nil {
+		opt = &ApplyOptions{}
+	}
+
+	expr := page.FilterExpr
+	if expr == nil {
+		expr = page.Filters.Expr()
+	}
+
+	result, err := filterItems(items, expr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if result, err = searchItems(result, page.Search, opt.SearchFields); err != nil {
+		return nil, 0, err
+	}
+	total := len(result)
+
+	sorts := page.Sorts
+	var cursor *Cursor
+	if page.Pagination != nil {
+		cursor = page.Pagination.Cursor
+	}
+	if cursor != nil {
+		seek, err := cursor.Seek(sorts)
+		if err != nil {
+			return nil, 0, err
+		}
+		result, err = filterItems(result, seek)
+		if err != nil {
+			return nil, 0, err
+		}
+		if cursor.Before {
+			sorts = sorts.Reverse()
+		}
+	}
+
+	if err := sortItems(result, sorts); err != nil {
+		return nil, 0, err
+	}
+
+	result = paginateItems(result, page.Pagination)
+	if cursor != nil && cursor.Before {
+		for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+			result[i], result[j] = result[j], result[i]
+		}
+	}
+	return result, total, nil
+}
+
+// ApplyOptions configures the behaviour of ApplyWithOptions.
+type ApplyOptions struct {
+	SearchFields []string // Fields matched by search terms that do not specify a field. If this is empty, such terms are rejected with ErrUnsupportedSearch.
+}
+
+// Project applies a projection to a slice of items in memory, returning each item as a map of field names to values.
+// Items may be structs, pointers to structs, or maps with string keys, and fields are matched in the same way as Apply.
+//
+// If the projection does not specify fields for an entity, all of its fields are included.
+// For structs, each field is named by its qs tag name, json tag name or Go field name, in that order.
+// Values of joined entities, including slices of them, are projected using the fields for their join path.
+func Project[T any](items []T, fields Fields) ([]map[string]any, error) {
+	result := make([]map[string]any, len(items))
+	for i, item := range items {
+		projected, err := projectItem(item, fields, "")
+		if err != nil {
+			return nil, err
+		}
+		result[i] = projected
+	}
+	return result, nil
+}
+
+func searchItems[T any](items []T, search *Search, searchFields []string) ([]T, error) {
+	if search == nil {
+		return items, nil
+	}
+
+	if len(searchFields) == 0 {
+		for _, term := range search.Terms {
+			if term.Field == "" {
+				return nil, ErrUnsupportedSearch
+			}
+		}
+	}
+
+	result := []T{}
+	for _, item := range items {
+		ok := true
+		for _, term := range search.Terms {
+			match, err := matchSearchTerm(item, term, searchFields)
+			if err != nil {
+				return nil, err
+			}
+			if match == term.Exclude {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func matchSearchTerm(item any, term SearchTerm, searchFields []string) (bool, error) {
+	fields := []string{term.Field}
+	if term.Field == "" {
+		fields = searchFields
+	}
+
+	value := strings.ToLower(term.Value)
+	for _, field := range fields {
+		fieldValue, err := lookupField(item, field)
+		if err != nil {
+			return false, err
+		}
+		if fieldValue != nil && strings.Contains(strings.ToLower(stringValue(fieldValue)), value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func filterItems[T any](items []T, expr FilterExpr) ([]T, error) {
+	result := []T{}
+	if expr == nil {
+		return append(result, items...), nil
+	}
+
+	match, err := compileMatcher(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		t, err := match(item)
+		if err != nil {
+			return nil, err
+		}
+		if t == truthTrue {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// truth is the result of matching an item against a filter expression.
+// Like SQL, comparisons with nil values are unknown, and remain unknown when negated.
+type truth int
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+type matcher func(item any) (truth, error)
+
+func compileMatcher(expr FilterExpr) (matcher, error) {
+	switch node := expr.(type) {
+	case Filter:
+		return compileFilterMatcher(node)
+	case And:
+		matchers, err := compileMatchers(node)
+		if err != nil {
+			return nil, err
+		}
+		return func(item any) (truth, error) {
+			result := truthTrue
+			for _, match := range matchers {
+				t, err := match(item)
+				if err != nil || t == truthFalse {
+					return truthFalse, err
+				}
+				if t == truthUnknown {
+					result = truthUnknown
+				}
+			}
+			return result, nil
+		}, nil
+	case Or:
+		matchers, err := compileMatchers(node)
+		if err != nil {
+			return nil, err
+		}
+		return func(item any) (truth, error) {
+			result := truthFalse
+			for _, match := range matchers {
+				t, err := match(item)
+				if err != nil || t == truthTrue {
+					return t, err
+				}
+				if t == truthUnknown {
+					result = truthUnknown
+				}
+			}
+			return result, nil
+		}, nil
+	case Not:
+		match, err := compileMatcher(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return func(item any) (truth, error) {
+			t, err := match(item)
+			switch t {
+			case truthTrue:
+				return truthFalse, err
+			case truthFalse:
+				return truthTrue, err
+			}
+			return t, err
+		}, nil
+	}
+	return nil, ErrInvalidFilter
+}
+
+func compileMatchers(operands []FilterExpr) ([]matcher, error) {
+	matchers := []matcher{}
+	for _, operand := range operands {
+		match, err := compileMatcher(operand)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, match)
+	}
+	return matchers, nil
+}
+
+func compileFilterMatcher(filter Filter) (matcher, error) {
+	var test func(value any) (bool, error)
+
+	switch filter.Operator {
+	case "eq", "neq", "gt", "gte", "lt", "lte":
+		test = func(value any) (bool, error) {
+			cmp, err := compareFilterValue(value, filter.Value)
+			if err != nil {
+				return false, err
+			}
+			switch filter.Operator {
+			case "eq":
+				return cmp == 0, nil
+			case "neq":
+				return cmp != 0, nil
+			case "gt":
+				return cmp > 0, nil
+			case "gte":
+				return cmp >= 0, nil
+			case "lt":
+				return cmp < 0, nil
+			}
+			return cmp <= 0, nil
+		}
+	case "in", "not in":
+		values, _ := filter.StringSlice()
+		test = func(value any) (bool, error) {
+			for _, v := range values {
+				cmp, err := compareFilterValue(value, v)
+				if err != nil {
+					return false, err
+				}
+				if cmp == 0 {
+					return filter.Operator == "in", nil
+				}
+			}
+			return filter.Operator == "not in", nil
+		}
+	case "like", "not like", "co", "sw", "ew":
+		re := likeRegexp(filter)
+		test = func(value any) (bool, error) {
+			return re.MatchString(stringValue(value)) == (filter.Operator != "not like"), nil
+		}
+	case "pr":
+		// Like IS NOT NULL, presence is known even for nil values
+		return func(item any) (truth, error) {
+			value, err := lookupField(item, filter.Field)
+			if err != nil || value == nil {
+				return truthFalse, err
+			}
+			return truthTrue, nil
+		}, nil
+	default:
+		return nil, ErrInvalidFilter
+	}
+
+	return func(item any) (truth, error) {
+		value, err := lookupField(item, filter.Field)
+		if err != nil {
+			return truthFalse, err
+		}
+		if value == nil {
+			return truthUnknown, nil
+		}
+		ok, err := test(value)
+		if err != nil || !ok {
+			return truthFalse, err
+		}
+		return truthTrue, nil
+	}, nil
+}
+
+func likeRegexp(filter Filter) *regexp.Regexp {
+	segments := filter.LikeSegments()
+	for i, segment := range segments {
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	return regexp.MustCompile("(?s)^" + strings.Join(segments, ".*") + "$")
+}
+
+// compareFilterValue compares a field value with a filter value, parsed according to the type of the field value.
+// It returns ErrInvalidValue if the filter value cannot be parsed.
+func compareFilterValue(value any, filterValue string) (int, error) {
+	switch v := value.(type) {
+	case time.Time:
+		t, err := time.Parse(time.RFC3339, filterValue)
+		if err != nil {
+			return 0, ErrInvalidValue
+		}
+		return v.Compare(t), nil
+	case fmt.Stringer:
+		return strings.Compare(v.String(), filterValue), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(filterValue, 64)
+		if err != nil {
+			return 0, ErrInvalidValue
+		}
+		return compareFloats(floatValue(rv), f), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(filterValue)
+		if err != nil {
+			return 0, ErrInvalidValue
+		}
+		return compareBools(rv.Bool(), b), nil
+	}
+	return strings.Compare(stringValue(value), filterValue), nil
+}
+
+// compareValues compares two field values for sorting.
+// nil values are sorted first.
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+
+	ra, rb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if isNumber(ra) && isNumber(rb) {
+		return compareFloats(floatValue(ra), floatValue(rb))
+	}
+	if ra.Kind() == reflect.Bool && rb.Kind() == reflect.Bool {
+		return compareBools(ra.Bool(), rb.Bool())
+	}
+	return strings.Compare(stringValue(a), stringValue(b))
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	}
+	return 1
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func isNumber(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func floatValue(rv reflect.Value) float64 {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	}
+	return rv.Float()
+}
+
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
+
+// structFields caches field indexes by public name for each struct type.
+var structFields sync.Map
+
+// lookupField retrieves a field value from a struct or map, dereferencing pointers.
+// This function returns ErrUnknownField if a struct does not have the field.
+// Missing map keys and nil pointers produce a nil value.
+func lookupField(item any, name string) (any, error) {
+	rv := reflect.ValueOf(item)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	var field reflect.Value
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, ErrUnknownField
+		}
+		field = rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !field.IsValid() {
+			return nil, nil
+		}
+	case reflect.Struct:
+		index, ok := structFieldIndexes(rv.Type())[name]
+		if !ok {
+			return nil, ErrUnknownField
+		}
+		var err error
+		field, err = rv.FieldByIndexErr(index)
+		if err != nil {
+			// Nil embedded pointer
+			return nil, nil
+		}
+	default:
+		return nil, ErrUnknownField
+	}
+
+	for field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+	return field.Interface(), nil
+}
+
+func structFieldIndexes(t reflect.Type) map[string][]int {
+	if indexes, ok := structFields.Load(t); ok {
+		return indexes.(map[string][]int)
+	}
+
+	indexes := map[string][]int{}
+	set := func(name string, index []int) {
+		if _, ok := indexes[name]; !ok && name != "" && name != "-" {
+			indexes[name] = index
+		}
+	}
+	// Names are assigned in order of priority
+	fields := reflect.VisibleFields(t)
+	for _, sf := range fields {
+		if sf.IsExported() && !sf.Anonymous {
+			name, _ := parseTag(sf.Tag.Get("qs"))
+			set(name, sf.Index)
+		}
+	}
+	for _, sf := range fields {
+		if sf.IsExported() && !sf.Anonymous {
+			name, _ := parseTag(sf.Tag.Get("json"))
+			set(name, sf.Index)
+		}
+	}
+	for _, sf := range fields {
+		if sf.IsExported() && !sf.Anonymous {
+			set(sf.Name, sf.Index)
+		}
+	}
+
+	structFields.Store(t, indexes)
+	return indexes
+}
+
+// itemFields returns the names of all fields of a struct or map.
+// Struct fields are named by priority, as in structFieldIndexes, so each field appears only once.
+func itemFields(item any) []string {
+	rv := reflect.ValueOf(item)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	names := []string{}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range rv.MapKeys() {
+			names = append(names, key.String())
+		}
+		sort.Strings(names)
+	case reflect.Struct:
+		for _, sf := range reflect.VisibleFields(rv.Type()) {
+			if !sf.IsExported() || sf.Anonymous {
+				continue
+			}
+			name, _ := parseTag(sf.Tag.Get("qs"))
+			if name == "" {
+				name, _ = parseTag(sf.Tag.Get("json"))
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if name != "-" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func projectItem(item any, fields Fields, path string) (map[string]any, error) {
+	names := fields.Get(path)
+	if names == nil {
+		names = itemFields(item)
+	}
+
+	projected := map[string]any{}
+	for _, name := range names {
+		value, err := lookupField(item, name)
+		if err != nil {
+			return nil, err
+		}
+		childPath := name
+		if path != "" {
+			childPath = path + "." + name
+		}
+		if _, ok := fields[childPath]; ok {
+			if value, err = projectValue(value, fields, childPath); err != nil {
+				return nil, err
+			}
+		}
+		projected[name] = value
+	}
+	return projected, nil
+}
+
+// projectValue projects a joined entity, or each entity in a slice.
+func projectValue(value any, fields Fields, path string) (any, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Slice, reflect.Array:
+		projected := make([]map[string]any, rv.Len())
+		for i := range projected {
+			item, err := projectItem(rv.Index(i).Interface(), fields, path)
+			if err != nil {
+				return nil, err
+			}
+			projected[i] = item
+		}
+		return projected, nil
+	}
+	return projectItem(value, fields, path)
+}
+
+func sortItems[T any](items []T, sorts Sorts) error {
+	if len(sorts) == 0 {
+		return nil
+	}
+
+	for _, s := range sorts {
+		if s.Direction != "asc" && s.Direction != "desc" {
+			return ErrInvalidSort
+		}
+	}
+
+	// Look up sort values once per item
+	keys := make([][]any, len(items))
+	for i, item := range items {
+		keys[i] = make([]any, len(sorts))
+		for j, s := range sorts {
+			value, err := lookupField(item, s.Field)
+			if err != nil {
+				return err
+			}
+			keys[i][j] = value
+		}
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		for j, s := range sorts {
+			cmp := compareValues(keys[order[a]][j], keys[order[b]][j])
+			if s.Direction == "desc" {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	sorted := make([]T, len(items))
+	for i, n := range order {
+		sorted[i] = items[n]
+	}
+	copy(items, sorted)
+	return nil
+}
+
+func paginateItems[T any](items []T, pag *Pagination) []T {
+	if pag == nil {
+		return items
+	}
+	if pag.Offset > 0 {
+		if pag.Offset >= len(items) {
+			return []T{}
+		}
+		items = items[pag.Offset:]
+	}
+	if pag.Limit > 0 && pag.Limit < len(items) {
+		items = items[:pag.Limit]
+	}
+	return items
+}
@@ -0,0 +1,242 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testApplyRecipe struct {
+	ID     int     `json:"id"`
+	Title  string  `json:"title"`
+	Serves int     `qs:"serves" json:"numServes"`
+	Rating float64 `json:"rating"`
+	Vegan  bool    `json:"vegan"`
+	Author *string `json:"author"`
+}
+
+func testApplyRecipes() []testApplyRecipe {
+	anne := "anne"
+	bob := "bob"
+	return []testApplyRecipe{
+		{ID: 1, Title: "Spaghetti Bolognese", Serves: 4, Rating: 4.5, Author: &anne},
+		{ID: 2, Title: "Mushroom Risotto", Serves: 2, Rating: 4.5, Vegan: true, Author: &bob},
+		{ID: 3, Title: "Tomato Soup", Serves: 6, Rating: 3, Vegan: true},
+		{ID: 4, Title: "Spaghetti Carbonara", Serves: 2, Rating: 5, Author: &anne},
+		{ID: 5, Title: "100% Rye Bread", Serves: 8, Rating: 4, Vegan: true, Author: &bob},
+	}
+}
+
+func TestApply(t *testing.T) {
+	type TestCase struct {
+		Input    string
+		Opt      *ReadPageOptions
+		ApplyOpt *ApplyOptions
+		IDs      []int
+		Total    int
+		Err      error
+	}
+
+	search := &ReadPageOptions{Search: &ReadSearchOptions{}}
+	searchFields := &ApplyOptions{SearchFields: []string{"title", "rating"}}
+
+	testCases := []TestCase{
+		{Input: "", IDs: []int{1, 2, 3, 4, 5}, Total: 5},
+		{Input: "filter=serves eq 2", IDs: []int{2, 4}, Total: 2},
+		{Input: "filter=serves gte 4&filter=vegan eq true", IDs: []int{3, 5}, Total: 2},
+		{Input: "filter=rating gt 4.2", IDs: []int{1, 2, 4}, Total: 3},
+		{Input: "filter=title like Spaghetti%25", IDs: []int{1, 4}, Total: 2},
+		{Input: "filter=title not like %25o%25", IDs: []int{5}, Total: 1},
+		{Input: `filter=title like 100\%25%25`, IDs: []int{5}, Total: 1},
+		{Input: "filter=title co 100%25", IDs: []int{5}, Total: 1},
+		{Input: "filter=title sw spaghetti", IDs: []int{}, Total: 0},
+		{Input: "filter=title ew o", IDs: []int{2}, Total: 1},
+		{Input: "filter=author pr", IDs: []int{1, 2, 4, 5}, Total: 4},
+		{Input: "filter=id in 1,3,5", IDs: []int{1, 3, 5}, Total: 3},
+		{Input: "filter=id not in 1,3,5", IDs: []int{2, 4}, Total: 2},
+		{Input: "filter=author eq anne", IDs: []int{1, 4}, Total: 2},
+		{Input: "filter=author neq anne", IDs: []int{2, 5}, Total: 2},
+		{
+			Input: "filter=not author eq anne",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			IDs:   []int{2, 5},
+			Total: 2,
+		},
+		{
+			Input: "filter=not (author eq anne and vegan eq true)",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			IDs:   []int{1, 2, 4, 5},
+			Total: 4,
+		},
+		{
+			Input: "filter=not author pr",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			IDs:   []int{3},
+			Total: 1,
+		},
+		{
+			Input: "filter=serves eq 2 or not vegan eq false",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			IDs:   []int{2, 3, 4, 5},
+			Total: 4,
+		},
+		{Input: "sort=serves desc", IDs: []int{5, 3, 1, 2, 4}, Total: 5},
+		{Input: "sort=rating desc&sort=serves asc", IDs: []int{4, 2, 1, 5, 3}, Total: 5},
+		{Input: "sort=author asc&sort=id desc", IDs: []int{3, 4, 1, 5, 2}, Total: 5},
+		{Input: "sort=title asc&limit=2&offset=1", IDs: []int{2, 1}, Total: 5},
+		{Input: "filter=vegan eq true&sort=id desc&limit=2&page=2", IDs: []int{2}, Total: 3},
+		{Input: "q=SPAGHETTI", Opt: search, ApplyOpt: searchFields, IDs: []int{1, 4}, Total: 2},
+		{Input: "q=spaghetti -carbonara", Opt: search, ApplyOpt: searchFields, IDs: []int{1}, Total: 1},
+		{Input: "q=author:bob -title:bread", Opt: search, IDs: []int{2}, Total: 1},
+		{Input: `q="rye bread"&filter=serves gt 2`, Opt: search, ApplyOpt: searchFields, IDs: []int{5}, Total: 1},
+		{Input: "q=4.5", Opt: search, ApplyOpt: searchFields, IDs: []int{1, 2}, Total: 2},
+		{Input: "q=anne", Opt: search, ApplyOpt: searchFields, IDs: []int{}, Total: 0},
+		{Input: "q=spaghetti -anne", Opt: search, Err: ErrUnsupportedSearch},
+		{Input: "q=author:bob spaghetti", Opt: search, Err: ErrUnsupportedSearch},
+		{Input: "offset=10", IDs: []int{}, Total: 5},
+
+		{Input: "filter=secret eq 1", Err: ErrUnknownField},
+		{Input: "filter=serves gt many", Err: ErrInvalidValue},
+		{Input: "sort=secret asc", Err: ErrUnknownField},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v and %+v", n, tc.Input, tc.Opt, tc.ApplyOpt)
+
+		page, err := ReadStringPage(tc.Input, tc.Opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items := testApplyRecipes()
+		result, total, err := ApplyWithOptions(items, page, tc.ApplyOpt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if total != tc.Total {
+			t.Errorf("Expected total %d, got %d", tc.Total, total)
+		}
+
+		if len(result) != len(tc.IDs) {
+			t.Errorf("Expected %d items, got %d", len(tc.IDs), len(result))
+			continue
+		}
+
+		for i, id := range tc.IDs {
+			if result[i].ID != id {
+				t.Errorf("Expected ID %d for item %d, got %d", id, i, result[i].ID)
+			}
+		}
+
+		for i, item := range testApplyRecipes() {
+			if items[i].ID != item.ID {
+				t.Error("Expected input slice to be unmodified")
+				break
+			}
+		}
+	}
+}
+
+func TestApplyMap(t *testing.T) {
+	items := []map[string]any{
+		{"title": "Spaghetti Bolognese", "serves": 4},
+		{"title": "Mushroom Risotto", "serves": 2},
+		{"title": "Tomato Soup"},
+	}
+
+	page, _ := ReadStringPage("filter=serves lt 4&sort=title asc", nil)
+	result, total, err := Apply(items, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || result[0]["title"] != "Mushroom Risotto" {
+		t.Errorf("Expected Mushroom Risotto only, got %v", result)
+	}
+
+	page, _ = ReadStringPage("sort=serves asc", nil)
+	result, _, _ = Apply(items, page)
+	if result[0]["title"] != "Tomato Soup" {
+		t.Errorf("Expected missing value to be sorted first, got %v", result)
+	}
+}
+
+func TestProject(t *testing.T) {
+	type testProjectRecipe struct {
+		ID       int                 `json:"id"`
+		Title    string              `qs:"title" json:"name"`
+		Author   *testApplyRecipe    `json:"author"`
+		Variants []map[string]string `json:"variants"`
+	}
+
+	items := []testProjectRecipe{
+		{
+			ID:       1,
+			Title:    "Spaghetti Bolognese",
+			Author:   &testApplyRecipe{ID: 9, Title: "Anne"},
+			Variants: []map[string]string{{"name": "Vegan", "note": "Use lentils"}},
+		},
+		{ID: 2, Title: "Mushroom Risotto"},
+	}
+
+	type TestCase struct {
+		Input  string
+		Output []map[string]any
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{
+			Input: "fields=id,title",
+			Output: []map[string]any{
+				{"id": 1, "title": "Spaghetti Bolognese"},
+				{"id": 2, "title": "Mushroom Risotto"},
+			},
+		},
+		{
+			Input: "fields=title,author,variants&fields[author]=title&fields[variants]=name",
+			Output: []map[string]any{
+				{
+					"title":    "Spaghetti Bolognese",
+					"author":   map[string]any{"title": "Anne"},
+					"variants": []map[string]any{{"name": "Vegan"}},
+				},
+				{"title": "Mushroom Risotto", "author": nil, "variants": []map[string]any{}},
+			},
+		},
+		{
+			Input: "fields[author]=id",
+			Output: []map[string]any{
+				{"id": 1, "title": "Spaghetti Bolognese", "author": map[string]any{"id": 9}, "variants": []map[string]string{{"name": "Vegan", "note": "Use lentils"}}},
+				{"id": 2, "title": "Mushroom Risotto", "author": nil, "variants": []map[string]string(nil)},
+			},
+		},
+		{Input: "fields=id,secret", Err: ErrUnknownField},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		fields, err := ReadStringFields(tc.Input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := Project(items, fields)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(result, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, result)
+		}
+	}
+}
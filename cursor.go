@@ -0,0 +1,233 @@
+package qs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Query error.
+var (
+	ErrInvalidCursor  = errors.New("invalid cursor")
+	ErrTamperedCursor = errors.New("tampered cursor")
+	ErrExpiredCursor  = errors.New("expired cursor")
+)
+
+// Cursor error.
+var (
+	ErrCursorKeyNotFound = errors.New("cursor signing key not found")
+	ErrNullCursorValue   = errors.New("null cursor value")
+)
+
+// Cursor represents a position in a sorted result set, for keyset pagination.
+// It records the last-seen values of the sort keys, so that the next page can be found by seeking past them rather than counting an offset.
+type Cursor struct {
+	Values map[string]string `json:"values"`           // Sort key values at the cursor position, by field.
+	Before bool              `json:"before,omitempty"` // If this is true, the page ends before the cursor position rather than starting after it.
+}
+
+// CursorSigner signs and verifies cursor tokens using HMAC-SHA256, so that clients cannot forge cursor positions.
+// Signed tokens take the form payload.keyID.signature.
+//
+// Keys can be rotated by adding a new key, switching KeyID to it, and removing the old key once any cursors signed with it are no longer needed.
+type CursorSigner struct {
+	Keys  map[string][]byte // Keys by ID. Tokens signed with any of these keys are accepted
+	KeyID string            // ID of the key used to sign new tokens. Must not contain "."
+	TTL   time.Duration     // If this is > 0, tokens expire after this duration
+	Now   func() time.Time  // Returns the current time. The default is time.Now
+}
+
+type cursorToken struct {
+	Values  map[string]string `json:"v"`
+	Expires int64             `json:"e,omitempty"`
+}
+
+// DecodeCursor decodes an opaque cursor token produced by Cursor.Encode.
+// The returned cursor always seeks after its position; set Before if required.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return decodeCursorPayload(data)
+}
+
+// NewCursor creates a cursor positioned at an item, which may be a struct or map as described for Apply.
+//
+// Databases order null values inconsistently, so a cursor cannot seek past them.
+// This function returns ErrNullCursorValue if any sort value of the item is nil; sorts used for keyset pagination should be on non-null fields.
+func NewCursor(item any, sorts Sorts) (*Cursor, error) {
+	cursor := &Cursor{Values: map[string]string{}}
+	for _, sort := range sorts {
+		value, err := lookupField(item, sort.Field)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, ErrNullCursorValue
+		}
+		cursor.Values[sort.Field] = stringValue(value)
+	}
+	return cursor, nil
+}
+
+// NextCursor creates a cursor for the page following a slice of results.
+// This function returns nil if there are no results.
+func NextCursor[T any](items []T, sorts Sorts) (*Cursor, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return NewCursor(items[len(items)-1], sorts)
+}
+
+// PrevCursor creates a cursor for the page preceding a slice of results.
+// This function returns nil if there are no results.
+func PrevCursor[T any](items []T, sorts Sorts) (*Cursor, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	cursor, err := NewCursor(items[0], sorts)
+	if err != nil {
+		return nil, err
+	}
+	cursor.Before = true
+	return cursor, nil
+}
+
+// Encode encodes the cursor position as an opaque token for use in a query string.
+// The direction is not included; use the after or before query string key as appropriate.
+//
+// The token is not signed, so clients can forge it. Use a CursorSigner if that is a concern.
+func (cursor *Cursor) Encode() string {
+	return base64.RawURLEncoding.EncodeToString(cursor.payload())
+}
+
+// Seek returns a filter expression matching items beyond the cursor position, given the sorts used to produce it.
+// For example, given sorts "serves desc" and "id asc", a cursor after (4, 10) produces:
+//
+//	serves lt 4 or (serves eq 4 and id gt 10)
+//
+// If Before is true, the comparisons are reversed. Results must then be queried in reverse order (see Sorts.Reverse) and reversed again for display.
+//
+// The last sort should be on a unique field, such as an ID, so that the cursor position is unambiguous.
+// This function returns ErrInvalidCursor if the cursor does not have a value for every sort.
+func (cursor *Cursor) Seek(sorts Sorts) (FilterExpr, error) {
+	if len(sorts) == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	or := Or{}
+	for i, sort := range sorts {
+		and := And{}
+		for _, prev := range sorts[:i] {
+			value, ok := cursor.Values[prev.Field]
+			if !ok {
+				return nil, ErrInvalidCursor
+			}
+			and = append(and, Filter{Field: prev.Field, Operator: "eq", Value: value})
+		}
+
+		value, ok := cursor.Values[sort.Field]
+		if !ok {
+			return nil, ErrInvalidCursor
+		}
+		operator := "gt"
+		if (sort.Direction == "desc") != cursor.Before {
+			operator = "lt"
+		}
+		and = append(and, Filter{Field: sort.Field, Operator: operator, Value: value})
+
+		if len(and) == 1 {
+			or = append(or, and[0])
+		} else {
+			or = append(or, and)
+		}
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+// Decode verifies and decodes a signed cursor token.
+// This function returns ErrTamperedCursor if the token is unsigned, has an invalid signature or was signed with an unknown key.
+// If the token has expired, or has no expiry although the signer has a TTL, it returns ErrExpiredCursor.
+func (signer *CursorSigner) Decode(token string) (*Cursor, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTamperedCursor
+	}
+
+	key, ok := signer.Keys[parts[1]]
+	if !ok {
+		return nil, ErrTamperedCursor
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, signCursor(key, parts[0], parts[1])) {
+		return nil, ErrTamperedCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	payload := cursorToken{}
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Values == nil {
+		return nil, ErrInvalidCursor
+	}
+	if payload.Expires == 0 && signer.TTL > 0 || payload.Expires > 0 && signer.now().Unix() >= payload.Expires {
+		return nil, ErrExpiredCursor
+	}
+	return &Cursor{Values: payload.Values}, nil
+}
+
+// Encode encodes and signs a cursor as an opaque token for use in a query string.
+// This function returns ErrCursorKeyNotFound if KeyID does not identify a key.
+func (signer *CursorSigner) Encode(cursor *Cursor) (string, error) {
+	key, ok := signer.Keys[signer.KeyID]
+	if !ok || strings.Contains(signer.KeyID, ".") {
+		return "", ErrCursorKeyNotFound
+	}
+
+	payload := cursorToken{Values: cursor.Values}
+	if signer.TTL > 0 {
+		payload.Expires = signer.now().Add(signer.TTL).Unix()
+	}
+	data, _ := json.Marshal(payload)
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	sig := base64.RawURLEncoding.EncodeToString(signCursor(key, encoded, signer.KeyID))
+	return encoded + "." + signer.KeyID + "." + sig, nil
+}
+
+func (signer *CursorSigner) now() time.Time {
+	if signer.Now != nil {
+		return signer.Now()
+	}
+	return time.Now()
+}
+
+func (cursor *Cursor) payload() []byte {
+	data, _ := json.Marshal(cursorToken{Values: cursor.Values})
+	return data
+}
+
+func decodeCursorPayload(data []byte) (*Cursor, error) {
+	token := cursorToken{}
+	if err := json.Unmarshal(data, &token); err != nil || token.Values == nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Values: token.Values}, nil
+}
+
+func signCursor(key []byte, payload, keyID string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload + "." + keyID))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,228 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorSeek(t *testing.T) {
+	type TestCase struct {
+		Cursor *Cursor
+		Sorts  Sorts
+		Output FilterExpr
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{
+			Cursor: &Cursor{Values: map[string]string{"id": "10"}},
+			Sorts:  Sorts{{Field: "id", Direction: "asc"}},
+			Output: Filter{Field: "id", Operator: "gt", Value: "10"},
+		},
+		{
+			Cursor: &Cursor{Values: map[string]string{"serves": "4", "id": "10"}},
+			Sorts:  Sorts{{Field: "serves", Direction: "desc"}, {Field: "id", Direction: "asc"}},
+			Output: Or{
+				Filter{Field: "serves", Operator: "lt", Value: "4"},
+				And{
+					Filter{Field: "serves", Operator: "eq", Value: "4"},
+					Filter{Field: "id", Operator: "gt", Value: "10"},
+				},
+			},
+		},
+		{
+			Cursor: &Cursor{Values: map[string]string{"serves": "4", "id": "10"}, Before: true},
+			Sorts:  Sorts{{Field: "serves", Direction: "desc"}, {Field: "id", Direction: "asc"}},
+			Output: Or{
+				Filter{Field: "serves", Operator: "gt", Value: "4"},
+				And{
+					Filter{Field: "serves", Operator: "eq", Value: "4"},
+					Filter{Field: "id", Operator: "lt", Value: "10"},
+				},
+			},
+		},
+		{
+			Cursor: &Cursor{Values: map[string]string{"id": "10"}},
+			Sorts:  Sorts{{Field: "serves", Direction: "desc"}, {Field: "id", Direction: "asc"}},
+			Err:    ErrInvalidCursor,
+		},
+		{
+			Cursor: &Cursor{Values: map[string]string{"id": "10"}},
+			Err:    ErrInvalidCursor,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v with sorts %+v", n, tc.Cursor, tc.Sorts)
+
+		expr, err := tc.Cursor.Seek(tc.Sorts)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
+
+func TestCursorEncode(t *testing.T) {
+	cursor := &Cursor{Values: map[string]string{"title": "Spaghetti Bolognese", "id": "10"}}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, cursor) {
+		t.Errorf("Expected %+v, got %+v", cursor, decoded)
+	}
+
+	for _, token := range []string{"", "!!!", "bnVsbA", "e30"} {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected error %v for %q, got %v", ErrInvalidCursor, token, err)
+		}
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	sorts := Sorts{{Field: "serves", Direction: "asc"}, {Field: "id", Direction: "asc"}}
+	all, _, _ := Apply(testApplyRecipes(), &Page{Sorts: sorts})
+
+	// Walk forwards through every page
+	ids := []int{}
+	page := &Page{Sorts: sorts, Pagination: &Pagination{Limit: 2}}
+	for {
+		result, total, err := Apply(testApplyRecipes(), page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Errorf("Expected total 5, got %d", total)
+		}
+		if len(result) == 0 {
+			break
+		}
+		for _, item := range result {
+			ids = append(ids, item.ID)
+		}
+		page.Pagination.Cursor, _ = NextCursor(result, sorts)
+	}
+
+	if len(ids) != len(all) {
+		t.Fatalf("Expected %d items, got %d", len(all), len(ids))
+	}
+	for i, item := range all {
+		if ids[i] != item.ID {
+			t.Errorf("Expected ID %d for item %d, got %d", item.ID, i, ids[i])
+		}
+	}
+
+	// Walk backwards from the last item
+	cursor, _ := PrevCursor(all[4:], sorts)
+	result, _, _ := Apply(testApplyRecipes(), &Page{Sorts: sorts, Pagination: &Pagination{Limit: 2, Cursor: cursor}})
+	if len(result) != 2 || result[0].ID != all[2].ID || result[1].ID != all[3].ID {
+		t.Errorf("Expected IDs %d and %d, got %+v", all[2].ID, all[3].ID, result)
+	}
+}
+
+func TestNewCursor(t *testing.T) {
+	recipes := testApplyRecipes()
+	sorts := Sorts{{Field: "author", Direction: "asc"}, {Field: "id", Direction: "asc"}}
+
+	cursor, err := NewCursor(recipes[0], sorts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Cursor{Values: map[string]string{"author": "anne", "id": "1"}}
+	if !reflect.DeepEqual(cursor, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cursor)
+	}
+
+	if _, err := NextCursor(recipes[:3], sorts); !errors.Is(err, ErrNullCursorValue) {
+		t.Errorf("Expected error %v, got %v", ErrNullCursorValue, err)
+	}
+	if _, err := NewCursor(recipes[0], Sorts{{Field: "secret", Direction: "asc"}}); !errors.Is(err, ErrUnknownField) {
+		t.Errorf("Expected error %v, got %v", ErrUnknownField, err)
+	}
+}
+
+func TestCursorSigner(t *testing.T) {
+	now := time.Date(2024, 7, 19, 12, 0, 0, 0, time.UTC)
+	signer := &CursorSigner{
+		Keys:  map[string][]byte{"k1": []byte("old secret"), "k2": []byte("new secret")},
+		KeyID: "k1",
+		TTL:   time.Hour,
+		Now:   func() time.Time { return now },
+	}
+	cursor := &Cursor{Values: map[string]string{"id": "10"}}
+
+	oldToken, err := signer.Encode(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate key; tokens signed with the old key are still accepted
+	signer.KeyID = "k2"
+	newToken, _ := signer.Encode(cursor)
+	for _, token := range []string{oldToken, newToken} {
+		pag, err := ReadStringPagination("after="+token, &ReadPaginationOptions{CursorSigner: signer})
+		if err != nil {
+			t.Errorf("Expected no error for %q, got %v", token, err)
+		} else if !reflect.DeepEqual(pag.Cursor, cursor) {
+			t.Errorf("Expected %+v, got %+v", cursor, pag.Cursor)
+		}
+	}
+
+	parts := strings.Split(newToken, ".")
+	forged := (&Cursor{Values: map[string]string{"id": "99"}}).Encode()
+
+	type TestCase struct {
+		Token string
+		Err   error
+	}
+
+	testCases := []TestCase{
+		{Token: cursor.Encode(), Err: ErrTamperedCursor},
+		{Token: forged + "." + parts[1] + "." + parts[2], Err: ErrTamperedCursor},
+		{Token: parts[0] + ".k3." + parts[2], Err: ErrTamperedCursor},
+		{Token: parts[0] + ".k1." + parts[2], Err: ErrTamperedCursor},
+		{Token: parts[0] + "." + parts[1] + ".abc", Err: ErrTamperedCursor},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Token)
+
+		_, err := ReadStringPagination("before="+tc.Token, &ReadPaginationOptions{CursorSigner: signer})
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := signer.Decode(newToken); !errors.Is(err, ErrExpiredCursor) {
+		t.Errorf("Expected error %v, got %v", ErrExpiredCursor, err)
+	}
+
+	// Tokens signed without an expiry are not accepted by a signer with a TTL
+	unexpiring := *signer
+	unexpiring.TTL = 0
+	token, _ := unexpiring.Encode(cursor)
+	if _, err := unexpiring.Decode(token); err != nil {
+		t.Errorf("Expected no error without TTL, got %v", err)
+	}
+	if _, err := signer.Decode(token); !errors.Is(err, ErrExpiredCursor) {
+		t.Errorf("Expected error %v, got %v", ErrExpiredCursor, err)
+	}
+
+	signer.KeyID = "k3"
+	if _, err := signer.Encode(cursor); !errors.Is(err, ErrCursorKeyNotFound) {
+		t.Errorf("Expected error %v, got %v", ErrCursorKeyNotFound, err)
+	}
+}
@@ -0,0 +1,112 @@
+package qs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reason is a machine-readable reason for a ParseError.
+type Reason string
+
+// Parse error reason.
+const (
+	ReasonBadCursor          Reason = "bad_cursor"           // Cursor could not be decoded or verified
+	ReasonBadDirection       Reason = "bad_direction"        // Sort direction is missing or not asc or desc
+	ReasonBadField           Reason = "bad_field"            // Field name is missing or contains invalid characters
+	ReasonBadJoin            Reason = "bad_join"             // Join name is missing or contains invalid characters
+	ReasonBadNumber          Reason = "bad_number"           // Value is not an integer
+	ReasonInvalidValue       Reason = "invalid_value"        // Filter value is not valid for the field type
+	ReasonMissingOperator    Reason = "missing_operator"     // Filter operator is missing
+	ReasonMissingValue       Reason = "missing_value"        // Filter value is missing
+	ReasonOperatorNotAllowed Reason = "operator_not_allowed" // Filter operator is not permitted for the field
+	ReasonSelectNotAllowed   Reason = "select_not_allowed"   // Field cannot be included in a projection
+	ReasonSortNotAllowed     Reason = "sort_not_allowed"     // Field cannot be sorted
+	ReasonTooDeep            Reason = "too_deep"             // Join path is nested too deeply
+	ReasonTooMany            Reason = "too_many"             // Too many values are provided
+	ReasonUnclosedGroup      Reason = "unclosed_group"       // Parenthesis is not closed
+	ReasonUnexpectedToken    Reason = "unexpected_token"     // Token is not valid at this position
+	ReasonUnknownField       Reason = "unknown_field"        // Field is not declared in the schema
+	ReasonUnknownJoin        Reason = "unknown_join"         // Join is not declared in the schema
+	ReasonUnknownOperator    Reason = "unknown_operator"     // Filter operator is not recognised
+	ReasonUnterminatedQuote  Reason = "unterminated_quote"   // Quoted value is not terminated
+)
+
+// ParseError describes a problem with a query string value.
+// It wraps one of the package's query errors, such as ErrInvalidFilter, so it can still be matched using errors.Is.
+type ParseError struct {
+	Key    string // Query string key.
+	Index  int    // Index of the value among values for the key.
+	Input  string // Raw input value.
+	Offset int    // Byte offset within the input at which the problem was found.
+	Reason Reason // Machine-readable reason.
+	Err    error  // Query error, such as ErrInvalidFilter.
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Err, strings.ReplaceAll(string(e.Reason), "_", " "))
+	if e.Key != "" {
+		msg += fmt.Sprintf(" in %s[%d]", e.Key, e.Index)
+	}
+	return msg + fmt.Sprintf(" at offset %d", e.Offset)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of errors collected while reading a query.
+// It behaves like an error returned by errors.Join, so each error can be matched using errors.Is and errors.As.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// ByKey groups errors by query string key.
+// Errors that are not a ParseError, or have no key, are grouped under an empty key.
+func (e Errors) ByKey() map[string][]error {
+	keys := map[string][]error{}
+	for _, err := range e {
+		key := ""
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			key = perr.Key
+		}
+		keys[key] = append(keys[key], err)
+	}
+	return keys
+}
+
+// NewParseError creates a ParseError.
+// This is exported for the use of readers that parse other query formats.
+func NewParseError(key string, index int, input string, offset int, reason Reason, err error) *ParseError {
+	return &ParseError{
+		Key:    key,
+		Index:  index,
+		Input:  input,
+		Offset: offset,
+		Reason: reason,
+		Err:    err,
+	}
+}
+
+// WithKey sets the query string key and value index of a ParseError, and returns it.
+// Other errors are returned unchanged.
+// This is useful when parsing a value that was not read directly from a query string key.
+func WithKey(err error, key string, index int) error {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		perr.Key = key
+		perr.Index = index
+	}
+	return err
+}
@@ -0,0 +1,259 @@
+package qs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseError(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output ParseError
+	}
+
+	exprOpt := &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}}
+
+	testCases := []TestCase{
+		{
+			Input:  "filter=title eq Bolognese&filter=serves is 4",
+			Output: ParseError{Key: "filter", Index: 1, Input: "serves is 4", Offset: 7, Reason: ReasonUnknownOperator, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=title eq",
+			Output: ParseError{Key: "filter", Index: 0, Input: "title eq", Offset: 8, Reason: ReasonMissingValue, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=author pr anne",
+			Output: ParseError{Key: "filter", Index: 0, Input: "author pr anne", Offset: 10, Reason: ReasonUnexpectedToken, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=title",
+			Output: ParseError{Key: "filter", Index: 0, Input: "title", Offset: 5, Reason: ReasonMissingOperator, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=ti-tle eq Bolognese",
+			Output: ParseError{Key: "filter", Index: 0, Input: "ti-tle eq Bolognese", Offset: 2, Reason: ReasonBadField, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "sort=title up",
+			Output: ParseError{Key: "sort", Index: 0, Input: "title up", Offset: 6, Reason: ReasonBadDirection, Err: ErrInvalidSort},
+		},
+		{
+			Input:  "join=author&join=Ingredient",
+			Output: ParseError{Key: "join", Index: 1, Input: "Ingredient", Offset: 0, Reason: ReasonBadJoin, Err: ErrInvalidJoin},
+		},
+		{
+			Input:  "limit=ten",
+			Output: ParseError{Key: "limit", Index: 0, Input: "ten", Offset: 0, Reason: ReasonBadNumber, Err: ErrInvalidLimit},
+		},
+		{
+			Input:  "after=abc",
+			Output: ParseError{Key: "after", Index: 0, Input: "abc", Offset: 0, Reason: ReasonBadCursor, Err: ErrInvalidCursor},
+		},
+		{
+			Input:  "filter=a eq 1&filter=b eq 2&filter=c eq 3",
+			Opt:    &ReadPageOptions{Filter: &ReadFiltersOptions{MaxFilters: 2}},
+			Output: ParseError{Key: "filter", Index: 2, Input: "c eq 3", Offset: 0, Reason: ReasonTooMany, Err: ErrTooManyFilters},
+		},
+		{
+			Input:  "filter=(status eq draft or author eq 3",
+			Opt:    exprOpt,
+			Output: ParseError{Key: "filter", Index: 0, Input: "(status eq draft or author eq 3", Offset: 31, Reason: ReasonUnclosedGroup, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=title eq x&filter=status eq draft) or author eq 3",
+			Opt:    exprOpt,
+			Output: ParseError{Key: "filter", Index: 1, Input: "status eq draft) or author eq 3", Offset: 15, Reason: ReasonUnexpectedToken, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=title eq 'Bolognese",
+			Opt:    exprOpt,
+			Output: ParseError{Key: "filter", Index: 0, Input: "title eq 'Bolognese", Offset: 9, Reason: ReasonUnterminatedQuote, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=title eq x or serves ge 4",
+			Opt:    exprOpt,
+			Output: ParseError{Key: "filter", Index: 0, Input: "title eq x or serves ge 4", Offset: 21, Reason: ReasonUnknownOperator, Err: ErrInvalidFilter},
+		},
+		{
+			Input:  "filter=title eq Bolognese&filter=serves gte four",
+			Opt:    &ReadPageOptions{Schema: testSchema},
+			Output: ParseError{Key: "filter", Index: 1, Input: "serves gte four", Offset: 11, Reason: ReasonInvalidValue, Err: ErrInvalidValue},
+		},
+		{
+			Input:  "join=author&join=secret",
+			Opt:    &ReadPageOptions{Schema: testSchema},
+			Output: ParseError{Key: "join", Index: 1, Input: "secret", Offset: 0, Reason: ReasonUnknownJoin, Err: ErrUnknownJoin},
+		},
+		{
+			Input:  "join=author.secret",
+			Opt:    &ReadPageOptions{Schema: testSchema},
+			Output: ParseError{Key: "join", Index: 0, Input: "author.secret", Offset: 7, Reason: ReasonUnknownJoin, Err: ErrUnknownJoin},
+		},
+		{
+			Input:  "fields=title,serves&fields=rating,author",
+			Opt:    &ReadPageOptions{Fields: &ReadFieldsOptions{}, Schema: testSchema},
+			Output: ParseError{Key: "fields", Index: 1, Input: "rating,author", Offset: 7, Reason: ReasonSelectNotAllowed, Err: ErrSelectNotAllowed},
+		},
+		{
+			Input:  "fields=title,se-rves",
+			Opt:    &ReadPageOptions{Fields: &ReadFieldsOptions{}},
+			Output: ParseError{Key: "fields", Index: 0, Input: "title,se-rves", Offset: 8, Reason: ReasonBadField, Err: ErrInvalidFields},
+		},
+		{
+			Input:  "q=pasta&q=-rating:4",
+			Opt:    &ReadPageOptions{Search: &ReadSearchOptions{}, Schema: testSchema},
+			Output: ParseError{Key: "q", Index: 1, Input: "-rating:4", Offset: 0, Reason: ReasonOperatorNotAllowed, Err: ErrOperatorNotAllowed},
+		},
+		{
+			Input:  `q=pasta "mushroom risotto`,
+			Opt:    &ReadPageOptions{Search: &ReadSearchOptions{}},
+			Output: ParseError{Key: "q", Index: 0, Input: `pasta "mushroom risotto`, Offset: 6, Reason: ReasonUnterminatedQuote, Err: ErrInvalidSearch},
+		},
+		{
+			Input:  "join=author..profile",
+			Output: ParseError{Key: "join", Index: 0, Input: "author..profile", Offset: 7, Reason: ReasonBadJoin, Err: ErrInvalidJoin},
+		},
+		{
+			Input:  "join=author.profile.avatar",
+			Opt:    &ReadPageOptions{Join: &ReadJoinsOptions{MaxDepth: 2}},
+			Output: ParseError{Key: "join", Index: 0, Input: "author.profile.avatar", Offset: 14, Reason: ReasonTooDeep, Err: ErrInvalidJoin},
+		},
+		{
+			Input:  "filter=serves gte 4&serves[lt]=high",
+			Opt:    &ReadPageOptions{Filter: &ReadFiltersOptions{KeyStyles: []FilterKeyStyle{BracketKeyStyle}}, Schema: testSchema},
+			Output: ParseError{Key: "serves[lt]", Index: 0, Input: "high", Offset: 0, Reason: ReasonInvalidValue, Err: ErrInvalidValue},
+		},
+		{
+			Input:  "filter=serves gte 4 or serves lt 2&title__gt=Spag",
+			Opt:    &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true, KeyStyles: []FilterKeyStyle{DjangoKeyStyle}}, Schema: testSchema},
+			Output: ParseError{Key: "title__gt", Index: 0, Input: "Spag", Offset: 0, Reason: ReasonOperatorNotAllowed, Err: ErrOperatorNotAllowed},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		_, err := ReadStringPage(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Output.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Output.Err, err)
+		}
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Expected ParseError, got %T", err)
+			continue
+		}
+		if *perr != tc.Output {
+			t.Errorf("Expected %+v, got %+v", tc.Output, *perr)
+		}
+	}
+}
+
+func TestParseErrorMessage(t *testing.T) {
+	err := NewParseError("filter", 1, "serves is 4", 7, ReasonUnknownOperator, ErrInvalidFilter)
+
+	expected := "invalid filter: unknown operator in filter[1] at offset 7"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output map[string][]error
+	}
+
+	testCases := []TestCase{
+		{
+			Input: "limit=ten&page=two&filter=title is x&filter=serves gte 4&filter=vegan&sort=title up&join=Author",
+			Opt:   &ReadPageOptions{CollectErrors: true},
+			Output: map[string][]error{
+				"limit":  {ErrInvalidLimit},
+				"page":   {ErrInvalidPage},
+				"filter": {ErrInvalidFilter, ErrInvalidFilter},
+				"sort":   {ErrInvalidSort},
+				"join":   {ErrInvalidJoin},
+			},
+		},
+		{
+			Input: "filter=title eq x or&filter=serves gte 4 and&sort=title up",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}, CollectErrors: true},
+			Output: map[string][]error{
+				"filter": {ErrInvalidFilter, ErrInvalidFilter},
+				"sort":   {ErrInvalidSort},
+			},
+		},
+		{
+			Input: "filter=serves gte four&filter=secret eq 1&sort=secret asc&join=secret",
+			Opt:   &ReadPageOptions{Schema: testSchema, CollectErrors: true},
+			Output: map[string][]error{
+				"filter": {ErrInvalidValue, ErrUnknownField},
+				"sort":   {ErrUnknownField},
+				"join":   {ErrUnknownJoin},
+			},
+		},
+		{
+			Input: "filter=serves gte four&sort=title up",
+			Opt:   &ReadPageOptions{Schema: testSchema, CollectErrors: true},
+			Output: map[string][]error{
+				"filter": {ErrInvalidValue},
+				"sort":   {ErrInvalidSort},
+			},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		_, err := ReadStringPage(tc.Input, tc.Opt)
+
+		var errs Errors
+		if !errors.As(err, &errs) {
+			t.Errorf("Expected Errors, got %T", err)
+			continue
+		}
+
+		byKey := errs.ByKey()
+		if len(byKey) != len(tc.Output) {
+			t.Errorf("Expected errors for %d keys, got %d", len(tc.Output), len(byKey))
+		}
+		for key, expected := range tc.Output {
+			if len(byKey[key]) != len(expected) {
+				t.Errorf("Expected %d errors for %q, got %d", len(expected), key, len(byKey[key]))
+				continue
+			}
+			for i, err := range byKey[key] {
+				if !errors.Is(err, expected[i]) {
+					t.Errorf("Expected error %v for %s[%d], got %v", expected[i], key, i, err)
+				}
+			}
+		}
+
+		for _, expected := range tc.Output {
+			if !errors.Is(err, expected[0]) {
+				t.Errorf("Expected errors to match %v", expected[0])
+			}
+		}
+	}
+}
+
+func TestWithKey(t *testing.T) {
+	err := WithKey(NewParseError("", 0, "serves is 4", 7, ReasonUnknownOperator, ErrInvalidFilter), "filter", 1)
+
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Expected ParseError, got %T", err)
+	}
+	if perr.Key != "filter" || perr.Index != 1 {
+		t.Errorf("Expected filter[1], got %s[%d]", perr.Key, perr.Index)
+	}
+
+	if err := WithKey(ErrInvalidFilter, "filter", 1); err != ErrInvalidFilter {
+		t.Errorf("Expected %v, got %v", ErrInvalidFilter, err)
+	}
+}
@@ -0,0 +1,525 @@
+package qs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Query error.
+var (
+	ErrComplexFilter = errors.New("filter is not a conjunction")
+)
+
+// exprOperators are the words that may follow a field name in a comparison. not in and not like are written as two words.
+var exprOperators = []string{"eq", "neq", "gt", "gte", "lt", "lte", "in", "like", "co", "sw", "ew", "pr"}
+
+// FilterExpr is a node in a boolean filter expression.
+// It is implemented by Filter, And, Or and Not.
+type FilterExpr interface {
+	filterExpr()
+}
+
+// And is a filter expression that is satisfied if all of its operands are satisfied.
+type And []FilterExpr
+
+// Or is a filter expression that is satisfied if any of its operands are satisfied.
+type Or []FilterExpr
+
+// Not is a filter expression that negates its operand.
+type Not struct {
+	Expr FilterExpr
+}
+
+func (Filter) filterExpr() {}
+func (And) filterExpr()    {}
+func (Or) filterExpr()     {}
+func (Not) filterExpr()    {}
+
+// MarshalJSON encodes an And expression as an object with a single "and" property.
+func (and And) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]FilterExpr{"and": and})
+}
+
+// MarshalJSON encodes an Or expression as an object with a single "or" property.
+func (or Or) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]FilterExpr{"or": or})
+}
+
+// MarshalJSON encodes a Not expression as an object with a single "not" property.
+func (not Not) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]FilterExpr{"not": not.Expr})
+}
+
+// String returns the expression in the form read by ParseFilterExpr.
+func (and And) String() string {
+	return FormatFilterExpr(and)
+}
+
+// String returns the expression in the form read by ParseFilterExpr.
+func (or Or) String() string {
+	return FormatFilterExpr(or)
+}
+
+// String returns the expression in the form read by ParseFilterExpr.
+func (not Not) String() string {
+	return FormatFilterExpr(not)
+}
+
+// FormatFilterExpr returns a filter expression in the form read by ParseFilterExpr.
+// Values are quoted where necessary, and parentheses are only added where required by precedence.
+func FormatFilterExpr(expr FilterExpr) string {
+	switch node := expr.(type) {
+	case Filter:
+		if isUnaryFilterOperator(node.Operator) {
+			return node.Field + " " + node.Operator
+		}
+		return node.Field + " " + node.Operator + " " + quoteExprValue(node.Value)
+	case And:
+		operands := []string{}
+		for _, operand := range node {
+			if _, ok := operand.(Or); ok {
+				operands = append(operands, "("+FormatFilterExpr(operand)+")")
+			} else {
+				operands = append(operands, FormatFilterExpr(operand))
+			}
+		}
+		return strings.Join(operands, " and ")
+	case Or:
+		operands := []string{}
+		for _, operand := range node {
+			operands = append(operands, FormatFilterExpr(operand))
+		}
+		return strings.Join(operands, " or ")
+	case Not:
+		switch node.Expr.(type) {
+		case And, Or:
+			return "not (" + FormatFilterExpr(node.Expr) + ")"
+		}
+		return "not " + FormatFilterExpr(node.Expr)
+	}
+	return ""
+}
+
+// Expr returns the Filters slice as an And expression.
+// This function returns nil if the slice is empty.
+func (filters Filters) Expr() FilterExpr {
+	if len(filters) == 0 {
+		return nil
+	}
+	and := And{}
+	for _, filter := range filters {
+		and = append(and, filter)
+	}
+	return and
+}
+
+// FlattenFilters returns a flat Filters slice equivalent to a filter expression.
+// This is only possible if the expression is a filter or a conjunction of filters; if not, this function returns false.
+func FlattenFilters(expr FilterExpr) (Filters, bool) {
+	switch node := expr.(type) {
+	case nil:
+		return nil, true
+	case Filter:
+		return Filters{node}, true
+	case And:
+		filters := Filters{}
+		for _, operand := range node {
+			ff, ok := FlattenFilters(operand)
+			if !ok {
+				return nil, false
+			}
+			filters = append(filters, ff...)
+		}
+		return filters, true
+	}
+	return nil, false
+}
+
+// ParseFilterExpr parses a single boolean filter expression, such as:
+//
+//	(status eq draft or author eq 3) and serves gte 4
+//
+// Comparisons take the same form as filters read by ReadFilters, except that values containing whitespace or parentheses must be quoted with either single or double quotes.
+// Comparisons can be combined with and, or and not, and grouped with parentheses.
+// not binds most tightly, followed by and, then or.
+//
+// If the expression is invalid, this function returns a *ParseError wrapping ErrInvalidFilter.
+func ParseFilterExpr(expr string) (FilterExpr, error) {
+	tokens, err := lexFilterExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{input: expr, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(0); token != nil {
+		return nil, p.errorAt(token, ReasonUnexpectedToken)
+	}
+	return node, nil
+}
+
+// ReadFilterExpr parses URL values into a boolean filter expression.
+// Each value is parsed with ParseFilterExpr. If there are multiple values, they are combined into an And expression.
+// This function returns nil if no filters are found.
+//
+// If MaxFilters is set, it is applied to the total number of comparisons across all values.
+func ReadFilterExpr(values url.Values, opt *ReadFiltersOptions) (FilterExpr, error) {
+	expr, errs := readFilterExpr(values, initFiltersOptions(opt))
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return expr, nil
+}
+
+// readFilterExpr parses URL values into a boolean filter expression, collecting all errors.
+func readFilterExpr(values url.Values, opt *ReadFiltersOptions) (FilterExpr, Errors) {
+	styled := readStyledFilters(values, opt)
+	if !values.Has(opt.Key) && len(styled) == 0 {
+		return nil, nil
+	}
+
+	and := And{}
+	errs := Errors{}
+	count := 0
+	for i, exprStr := range values[opt.Key] {
+		node, err := ParseFilterExpr(exprStr)
+		if err != nil {
+			errs = append(errs, WithKey(err, opt.Key, i))
+			continue
+		}
+		count += CountFilters(node)
+		if opt.MaxFilters > 0 && count > opt.MaxFilters {
+			return nil, append(errs, NewParseError(opt.Key, i, exprStr, 0, ReasonTooMany, ErrTooManyFilters))
+		}
+		and = append(and, node)
+	}
+
+	errs = append(errs, validateStyledFilters(styled, count, opt)...)
+	for _, sf := range styled {
+		and = append(and, sf.Filter)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// ReadRequestFilterExpr parses a request's query string into a boolean filter expression.
+// This function returns nil if no filters are found.
+func ReadRequestFilterExpr(req *http.Request, opt *ReadFiltersOptions) (FilterExpr, error) {
+	return ReadFilterExpr(req.URL.Query(), opt)
+}
+
+// ReadStringFilterExpr parses a query string literal into a boolean filter expression.
+// This function returns nil if no filters are found.
+func ReadStringFilterExpr(qs string, opt *ReadFiltersOptions) (FilterExpr, error) {
+	values, err := url.ParseQuery(qs)
+	if err != nil {
+		return nil, err
+	}
+	return ReadFilterExpr(values, opt)
+}
+
+// canonicalFilterExpr returns an equivalent expression with nested junctions flattened and operands sorted, so that equivalent expressions are formatted identically.
+func canonicalFilterExpr(expr FilterExpr) FilterExpr {
+	switch node := expr.(type) {
+	case And:
+		and := And{}
+		for _, operand := range node {
+			operand = canonicalFilterExpr(operand)
+			if nested, ok := operand.(And); ok {
+				and = append(and, nested...)
+			} else {
+				and = append(and, operand)
+			}
+		}
+		if len(and) == 1 {
+			return and[0]
+		}
+		sortFilterExprs(and)
+		return and
+	case Or:
+		or := Or{}
+		for _, operand := range node {
+			operand = canonicalFilterExpr(operand)
+			if nested, ok := operand.(Or); ok {
+				or = append(or, nested...)
+			} else {
+				or = append(or, operand)
+			}
+		}
+		if len(or) == 1 {
+			return or[0]
+		}
+		sortFilterExprs(or)
+		return or
+	case Not:
+		return Not{Expr: canonicalFilterExpr(node.Expr)}
+	}
+	return expr
+}
+
+// CountFilters returns the number of filters in a filter expression.
+func CountFilters(expr FilterExpr) int {
+	switch node := expr.(type) {
+	case Filter:
+		return 1
+	case And:
+		n := 0
+		for _, operand := range node {
+			n += CountFilters(operand)
+		}
+		return n
+	case Or:
+		n := 0
+		for _, operand := range node {
+			n += CountFilters(operand)
+		}
+		return n
+	case Not:
+		return CountFilters(node.Expr)
+	}
+	return 0
+}
+
+type exprTokenType int
+
+const (
+	exprWord exprTokenType = iota
+	exprQuoted
+	exprOpen
+	exprClose
+)
+
+type exprToken struct {
+	Type   exprTokenType
+	Value  string
+	Offset int
+}
+
+type exprParser struct {
+	input  string
+	tokens []exprToken
+	pos    int
+}
+
+// lexFilterExpr splits a filter expression into tokens.
+// This function returns an error if the expression is empty or contains an unterminated quote.
+func lexFilterExpr(expr string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, exprToken{Type: exprOpen, Value: "(", Offset: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, exprToken{Type: exprClose, Value: ")", Offset: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			value := strings.Builder{}
+			i++
+			closed := false
+			for i < len(expr) {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					value.WriteByte(expr[i+1])
+					i += 2
+					continue
+				}
+				if expr[i] == c {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(expr[i])
+				i++
+			}
+			if !closed {
+				return nil, NewParseError("", 0, expr, start, ReasonUnterminatedQuote, ErrInvalidFilter)
+			}
+			tokens = append(tokens, exprToken{Type: exprQuoted, Value: value.String(), Offset: start})
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n\r()\"'", rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, exprToken{Type: exprWord, Value: expr[start:i], Offset: start})
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, NewParseError("", 0, expr, 0, ReasonBadField, ErrInvalidFilter)
+	}
+	return tokens, nil
+}
+
+func quoteExprValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r()\"'") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func sortFilterExprs(exprs []FilterExpr) {
+	sort.SliceStable(exprs, func(i, j int) bool {
+		return FormatFilterExpr(exprs[i]) < FormatFilterExpr(exprs[j])
+	})
+}
+
+// errorAt creates a ParseError at the offset of a token, or at the end of input if the token is nil.
+func (p *exprParser) errorAt(token *exprToken, reason Reason) error {
+	offset := len(p.input)
+	if token != nil {
+		offset = token.Offset
+	}
+	return NewParseError("", 0, p.input, offset, reason, ErrInvalidFilter)
+}
+
+func (p *exprParser) peek(n int) *exprToken {
+	if p.pos+n < len(p.tokens) {
+		return &p.tokens[p.pos+n]
+	}
+	return nil
+}
+
+func (p *exprParser) peekWord(n int, words ...string) bool {
+	token := p.peek(n)
+	if token == nil || token.Type != exprWord {
+		return false
+	}
+	for _, word := range words {
+		if token.Value == word {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (FilterExpr, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	or := Or{node}
+	for p.peekWord(0, "or") {
+		p.pos++
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, node)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *exprParser) parseAnd() (FilterExpr, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	and := And{node}
+	for p.peekWord(0, "and") {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, node)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *exprParser) parseUnary() (FilterExpr, error) {
+	token := p.peek(0)
+	if token == nil {
+		return nil, p.errorAt(nil, ReasonBadField)
+	}
+
+	// "not" is a field name if it is followed by a comparison operator
+	if p.peekWord(0, "not") && !p.peekWord(1, exprOperators...) {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: node}, nil
+	}
+
+	if token.Type == exprOpen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if token := p.peek(0); token == nil || token.Type != exprClose {
+			return nil, p.errorAt(token, ReasonUnclosedGroup)
+		}
+		p.pos++
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (FilterExpr, error) {
+	field := p.peek(0)
+	if field == nil || field.Type != exprWord || !IsField(field.Value) {
+		return nil, p.errorAt(field, ReasonBadField)
+	}
+	p.pos++
+
+	if p.peekWord(0, unaryFilterOperators...) {
+		operator := p.peek(0).Value
+		p.pos++
+		return Filter{Field: field.Value, Operator: operator}, nil
+	}
+
+	operator := ""
+	if p.peekWord(0, exprOperators...) {
+		operator = p.peek(0).Value
+		p.pos++
+	} else if p.peekWord(0, "not") && p.peekWord(1, "in", "like") {
+		operator = "not " + p.peek(1).Value
+		p.pos += 2
+	} else if token := p.peek(0); token == nil {
+		return nil, p.errorAt(nil, ReasonMissingOperator)
+	} else {
+		return nil, p.errorAt(token, ReasonUnknownOperator)
+	}
+
+	value := p.peek(0)
+	if value == nil || (value.Type != exprWord && value.Type != exprQuoted) {
+		return nil, p.errorAt(value, ReasonMissingValue)
+	}
+	p.pos++
+
+	filter := Filter{
+		Field:    field.Value,
+		Operator: operator,
+		Value:    value.Value,
+	}
+	return filter, nil
+}
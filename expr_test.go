@@ -0,0 +1,246 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseFilterExpr(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output FilterExpr
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{
+			Input:  "title eq Spaghetti",
+			Output: Filter{Field: "title", Operator: "eq", Value: "Spaghetti"},
+		},
+		{
+			Input:  "title eq 'Spaghetti Bolognese'",
+			Output: Filter{Field: "title", Operator: "eq", Value: "Spaghetti Bolognese"},
+		},
+		{
+			Input:  `title eq "Nan\"s (Famous) Pie"`,
+			Output: Filter{Field: "title", Operator: "eq", Value: `Nan"s (Famous) Pie`},
+		},
+		{
+			Input:  "author not in 1,2,3",
+			Output: Filter{Field: "author", Operator: "not in", Value: "1,2,3"},
+		},
+		{
+			Input: "status eq draft or author eq 3",
+			Output: Or{
+				Filter{Field: "status", Operator: "eq", Value: "draft"},
+				Filter{Field: "author", Operator: "eq", Value: "3"},
+			},
+		},
+		{
+			Input: "(status eq draft or author eq 3) and serves gte 4",
+			Output: And{
+				Or{
+					Filter{Field: "status", Operator: "eq", Value: "draft"},
+					Filter{Field: "author", Operator: "eq", Value: "3"},
+				},
+				Filter{Field: "serves", Operator: "gte", Value: "4"},
+			},
+		},
+		{
+			Input: "status eq draft or author eq 3 and serves gte 4",
+			Output: Or{
+				Filter{Field: "status", Operator: "eq", Value: "draft"},
+				And{
+					Filter{Field: "author", Operator: "eq", Value: "3"},
+					Filter{Field: "serves", Operator: "gte", Value: "4"},
+				},
+			},
+		},
+		{
+			Input: "not (status eq draft) and not title like %soup%",
+			Output: And{
+				Not{Expr: Filter{Field: "status", Operator: "eq", Value: "draft"}},
+				Not{Expr: Filter{Field: "title", Operator: "like", Value: "%soup%"}},
+			},
+		},
+		{
+			Input:  "not eq 1",
+			Output: Filter{Field: "not", Operator: "eq", Value: "1"},
+		},
+		{
+			Input: "not pr and title co 'a b' or not title sw x",
+			Output: Or{
+				And{
+					Filter{Field: "not", Operator: "pr"},
+					Filter{Field: "title", Operator: "co", Value: "a b"},
+				},
+				Not{Expr: Filter{Field: "title", Operator: "sw", Value: "x"}},
+			},
+		},
+
+		{Input: "", Err: ErrInvalidFilter},
+		{Input: "title", Err: ErrInvalidFilter},
+		{Input: "title eq", Err: ErrInvalidFilter},
+		{Input: "title is Spaghetti", Err: ErrInvalidFilter},
+		{Input: "title eq 'Spaghetti", Err: ErrInvalidFilter},
+		{Input: "(title eq Spaghetti", Err: ErrInvalidFilter},
+		{Input: "title eq Spaghetti)", Err: ErrInvalidFilter},
+		{Input: "title eq Spaghetti or", Err: ErrInvalidFilter},
+		{Input: "title eq Spaghetti Bolognese", Err: ErrInvalidFilter},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		expr, err := ParseFilterExpr(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
+
+func TestReadFilterExpr(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadFiltersOptions
+		Output FilterExpr
+		Flat   bool
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Flat: true},
+		{
+			Input:  "filter=title eq Bolognese",
+			Output: Filter{Field: "title", Operator: "eq", Value: "Bolognese"},
+			Flat:   true,
+		},
+		{
+			Input: "filter=title eq Bolognese&filter=serves gte 4",
+			Output: And{
+				Filter{Field: "title", Operator: "eq", Value: "Bolognese"},
+				Filter{Field: "serves", Operator: "gte", Value: "4"},
+			},
+			Flat: true,
+		},
+		{
+			Input: "filter=title eq Bolognese or title eq Carbonara&filter=serves gte 4",
+			Output: And{
+				Or{
+					Filter{Field: "title", Operator: "eq", Value: "Bolognese"},
+					Filter{Field: "title", Operator: "eq", Value: "Carbonara"},
+				},
+				Filter{Field: "serves", Operator: "gte", Value: "4"},
+			},
+		},
+		{
+			Input: "filter=title eq Bolognese or title eq Carbonara&filter=serves gte 4",
+			Opt:   &ReadFiltersOptions{MaxFilters: 2},
+			Err:   ErrTooManyFilters,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		expr, err := ReadStringFilterExpr(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+
+		if _, ok := FlattenFilters(expr); ok != tc.Flat {
+			t.Errorf("Expected flatten to return %t, got %t", tc.Flat, ok)
+		}
+	}
+}
+
+func TestFormatFilterExpr(t *testing.T) {
+	type TestCase struct {
+		Input  FilterExpr
+		Output string
+	}
+
+	testCases := []TestCase{
+		{
+			Input:  Filter{Field: "title", Operator: "eq", Value: `Nan"s Pie`},
+			Output: `title eq "Nan\"s Pie"`,
+		},
+		{
+			Input: And{
+				Or{
+					Filter{Field: "status", Operator: "eq", Value: "draft"},
+					Filter{Field: "author", Operator: "eq", Value: "3"},
+				},
+				Not{Expr: Filter{Field: "serves", Operator: "lt", Value: "4"}},
+				Not{Expr: And{
+					Filter{Field: "title", Operator: "like", Value: "%soup%"},
+					Filter{Field: "vegan", Operator: "eq", Value: ""},
+				}},
+			},
+			Output: `(status eq draft or author eq 3) and not serves lt 4 and not (title like %soup% and vegan eq "")`,
+		},
+		{
+			Input:  And{Filter{Field: "author", Operator: "pr"}, Filter{Field: "title", Operator: "ew", Value: "pie"}},
+			Output: "author pr and title ew pie",
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v", n, tc.Input)
+
+		output := FormatFilterExpr(tc.Input)
+		if output != tc.Output {
+			t.Errorf("Expected %q, got %q", tc.Output, output)
+		}
+
+		expr, err := ParseFilterExpr(output)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		} else if !reflect.DeepEqual(expr, tc.Input) {
+			t.Errorf("Expected %+v after parsing, got %+v", tc.Input, expr)
+		}
+	}
+}
+
+func TestCountFilters(t *testing.T) {
+	type TestCase struct {
+		Input  FilterExpr
+		Output int
+	}
+
+	a := Filter{Field: "title", Operator: "eq", Value: "a"}
+	b := Filter{Field: "serves", Operator: "gt", Value: "2"}
+
+	testCases := []TestCase{
+		{Input: nil, Output: 0},
+		{Input: a, Output: 1},
+		{Input: And{a, b}, Output: 2},
+		{Input: Or{a, And{a, b}}, Output: 3},
+		{Input: Not{Expr: Or{a, b}}, Output: 2},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("(%d) Testing %v", i, tc.Input)
+
+		if n := CountFilters(tc.Input); n != tc.Output {
+			t.Errorf("Expected %d, got %d", tc.Output, n)
+		}
+	}
+}
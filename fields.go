@@ -0,0 +1,200 @@
+package qs
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Query error.
+var (
+	ErrInvalidFields = errors.New("invalid fields")
+	ErrTooManyFields = errors.New("too many fields")
+)
+
+var fieldsRegexp = regexp.MustCompile("^[A-z0-9]+(,[A-z0-9]+)*$")
+
+// Fields represents a projection, or sparse fieldset, for, most likely, a database query.
+// It maps a join path to the fields to include for that entity. The root entity uses an empty path.
+//
+// If no fields are specified for an entity, all of its fields should be included.
+type Fields map[string][]string
+
+// Get returns the fields to include for an entity, identified by its join path.
+// This function returns nil if all fields should be included.
+func (fields Fields) Get(path string) []string {
+	return fields[path]
+}
+
+// Includes returns true if a field should be included for an entity, identified by its join path.
+func (fields Fields) Includes(path, field string) bool {
+	names, ok := fields[path]
+	if !ok {
+		return true
+	}
+	for _, name := range names {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the fields as URL values, using the same options as ReadFields.
+// Each entity's fields are written as a single comma-separated value, and entities are sorted by join path.
+func (fields Fields) Values(opt *ReadFieldsOptions) url.Values {
+	opt = initFieldsOptions(opt)
+
+	values := url.Values{}
+	for _, path := range fields.paths() {
+		if len(fields[path]) > 0 {
+			values.Set(fieldsKey(opt.Key, path), strings.Join(fields[path], ","))
+		}
+	}
+	return values
+}
+
+// paths returns the join paths of all entities in the projection, sorted by name.
+func (fields Fields) paths() []string {
+	paths := []string{}
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ReadFieldsOptions configures the behaviour of ReadFields.
+type ReadFieldsOptions struct {
+	Key       string // Query string key. The default value is "fields"
+	MaxFields int    // If this is > 0, a maximum number of fields is imposed for each entity
+}
+
+// ReadFields parses URL values into a projection.
+// Fields for the root entity are read from the key itself, e.g. fields=title,serves, while fields for joined entities are read from the key suffixed with the join path in brackets, e.g. fields[author]=name.
+// Fields may be split across multiple values for the same key.
+// This function returns nil if no fields are found.
+func ReadFields(values url.Values, opt *ReadFieldsOptions) (Fields, error) {
+	fields, errs := readFields(values, initFieldsOptions(opt))
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return fields, nil
+}
+
+// readFields parses URL values into a projection, collecting all errors.
+func readFields(values url.Values, opt *ReadFieldsOptions) (Fields, Errors) {
+	keys := []string{}
+	for key := range values {
+		if _, ok := fieldsPath(opt.Key, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+
+	fields := Fields{}
+	errs := Errors{}
+	for _, key := range keys {
+		path, _ := fieldsPath(opt.Key, key)
+		if path != "" && !joinRegexp.MatchString(path) {
+			errs = append(errs, NewParseError(key, 0, values.Get(key), 0, ReasonBadJoin, ErrInvalidFields))
+			continue
+		}
+
+		names := []string{}
+		for i, value := range values[key] {
+			if !fieldsRegexp.MatchString(value) {
+				errs = append(errs, NewParseError(key, i, value, diagnoseFields(value), ReasonBadField, ErrInvalidFields))
+				continue
+			}
+			for _, name := range strings.Split(value, ",") {
+				if opt.MaxFields > 0 && len(names) == opt.MaxFields {
+					errs = append(errs, NewParseError(key, i, value, 0, ReasonTooMany, ErrTooManyFields))
+					break
+				}
+				names = append(names, name)
+			}
+		}
+		fields[path] = names
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return fields, nil
+}
+
+// ReadRequestFields parses a request's query string into a projection.
+// This function returns nil if no fields are found.
+func ReadRequestFields(req *http.Request, opt *ReadFieldsOptions) (Fields, error) {
+	return ReadFields(req.URL.Query(), opt)
+}
+
+// ReadStringFields parses a query string literal into a projection.
+// This function returns nil if no fields are found.
+func ReadStringFields(qs string, opt *ReadFieldsOptions) (Fields, error) {
+	values, err := url.ParseQuery(qs)
+	if err != nil {
+		return nil, err
+	}
+	return ReadFields(values, opt)
+}
+
+// diagnoseFields returns the offset of the first invalid character in a comma-separated list of fields.
+func diagnoseFields(s string) int {
+	offset := 0
+	for {
+		n := scanField(s[offset:])
+		if n == 0 {
+			return offset
+		}
+		offset += n
+		if offset == len(s) || s[offset] != ',' {
+			return offset
+		}
+		offset++
+	}
+}
+
+// fieldsKey returns the query string key for an entity's fields.
+func fieldsKey(key, path string) string {
+	if path == "" {
+		return key
+	}
+	return key + "[" + path + "]"
+}
+
+// fieldsPath returns the join path for a query string key, if it is a fields key.
+func fieldsPath(key, s string) (string, bool) {
+	if s == key {
+		return "", true
+	}
+	if strings.HasPrefix(s, key+"[") && strings.HasSuffix(s, "]") {
+		return s[len(key)+1 : len(s)-1], true
+	}
+	return "", false
+}
+
+func initFieldsOptions(opt *ReadFieldsOptions) *ReadFieldsOptions {
+	def := &ReadFieldsOptions{
+		Key: "fields",
+	}
+
+	if opt != nil {
+		if len(opt.Key) > 0 {
+			def.Key = opt.Key
+		}
+
+		if opt.MaxFields > def.MaxFields {
+			def.MaxFields = opt.MaxFields
+		}
+	}
+
+	return def
+}
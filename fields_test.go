@@ -0,0 +1,72 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReadFields(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadFieldsOptions
+		Output Fields
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: ""},
+		{
+			Input:  "fields=title,serves",
+			Output: Fields{"": {"title", "serves"}},
+		},
+		{
+			Input:  "fields=title&fields=serves&fields[author]=name&fields[author.profile]=avatar",
+			Output: Fields{"": {"title", "serves"}, "author": {"name"}, "author.profile": {"avatar"}},
+		},
+		{
+			Input:  "select=title&fields=serves",
+			Opt:    &ReadFieldsOptions{Key: "select"},
+			Output: Fields{"": {"title"}},
+		},
+		{
+			Input:  "fields=title,serves&fields[author]=id,name",
+			Opt:    &ReadFieldsOptions{MaxFields: 2},
+			Output: Fields{"": {"title", "serves"}, "author": {"id", "name"}},
+		},
+
+		{Input: "fields=", Err: ErrInvalidFields},
+		{Input: "fields=title,", Err: ErrInvalidFields},
+		{Input: "fields=title serves", Err: ErrInvalidFields},
+		{Input: "fields[Author]=name", Err: ErrInvalidFields},
+		{Input: "fields=title,serves&fields=rating", Opt: &ReadFieldsOptions{MaxFields: 2}, Err: ErrTooManyFields},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		fields, err := ReadStringFields(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(fields, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, fields)
+		}
+	}
+}
+
+func TestFieldsIncludes(t *testing.T) {
+	fields := Fields{"": {"title", "serves"}}
+
+	if !fields.Includes("", "title") || fields.Includes("", "rating") {
+		t.Error("Expected only listed root fields to be included")
+	}
+	if !fields.Includes("author", "name") {
+		t.Error("Expected all fields to be included for an unrestricted join")
+	}
+}
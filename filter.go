@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -13,15 +14,24 @@ import (
 var (
 	ErrInvalidFilter  = errors.New("invalid filter")
 	ErrTooManyFilters = errors.New("too many filters")
+
+	// Returned by backends and formatters that cannot express a filter operator or expression.
+	ErrUnsupportedOperator = errors.New("unsupported operator")
+	ErrUnsupportedExpr     = errors.New("unsupported filter expression")
 )
 
 var (
-	filterRegexp = regexp.MustCompile("^([A-z0-9]+) (eq|neq|gt|gte|lt|lte|in|not in|like|not like) (.+)$")
+	filterOperators      = []string{"eq", "neq", "gt", "gte", "lt", "lte", "in", "not in", "like", "not like", "co", "sw", "ew"}
+	unaryFilterOperators = []string{"pr"}
+	filterRegexp         = regexp.MustCompile("^([A-z0-9]+) (?:(" + strings.Join(filterOperators, "|") + ") (.+)|(" + strings.Join(unaryFilterOperators, "|") + "))$")
 
 	sliceSeparator = ","
 )
 
 // Filter represents a filter as used in, most likely, a database query.
+//
+// The co, sw and ew operators match values that contain, start with or end with the filter value, which is matched literally.
+// The pr operator matches any value that is present, that is not null, and has no filter value.
 type Filter struct {
 	Field    string `json:"field"`    // Field to filter on.
 	Operator string `json:"operator"` // Filter operator, e.g. eq, gt...
@@ -105,12 +115,70 @@ func (filter Filter) IntValue() (int, error) {
 	return strconv.Atoi(filter.Value)
 }
 
+// String returns the filter in the form read by ReadFilters, e.g. "title eq Bolognese".
+func (filter Filter) String() string {
+	if isUnaryFilterOperator(filter.Operator) {
+		return filter.Field + " " + filter.Operator
+	}
+	return filter.Field + " " + filter.Operator + " " + filter.Value
+}
+
 // StringSlice retrieves the filter value as a slice of strings.
 func (filter Filter) StringSlice() ([]string, error) {
 	strings := strings.Split(filter.Value, sliceSeparator)
 	return strings, nil
 }
 
+// LikePattern returns the filter value as a like pattern.
+// For co, sw and ew filters, the value is escaped and wildcards are added, so co foo produces the pattern %foo%.
+// For other filters, the value is returned as-is.
+func (filter Filter) LikePattern() string {
+	switch filter.Operator {
+	case "co":
+		return "%" + EscapeLike(filter.Value) + "%"
+	case "sw":
+		return EscapeLike(filter.Value) + "%"
+	case "ew":
+		return "%" + EscapeLike(filter.Value)
+	}
+	return filter.Value
+}
+
+// LikeSegments splits the filter value into literal segments separated by wildcards, for use with like, not like, co, sw and ew operators.
+// In a like pattern, % matches any sequence of characters and \ escapes the following character.
+//
+// For example, the pattern %foo\%bar% produces the segments "", "foo%bar" and "".
+func (filter Filter) LikeSegments() []string {
+	return splitLike(filter.LikePattern())
+}
+
+// EscapeLike escapes a literal string for use in a like pattern.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%")
+
+func splitLike(pattern string) []string {
+	segments := []string{}
+	segment := strings.Builder{}
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				segment.WriteByte(pattern[i])
+			}
+		case '%':
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteByte(pattern[i])
+		}
+	}
+	return append(segments, segment.String())
+}
+
 // Filters is a slice of Filter structs.
 type Filters []Filter
 
@@ -150,10 +218,105 @@ func (filters Filters) HasField(field string) bool {
 	return false
 }
 
+// Values returns the filters as URL values, using the same options as ReadFilters.
+// If Expr is set in the options, values are quoted as required by ParseFilterExpr.
+func (filters Filters) Values(opt *ReadFiltersOptions) url.Values {
+	opt = initFiltersOptions(opt)
+
+	values := url.Values{}
+	for _, filter := range filters {
+		if opt.Expr {
+			values.Add(opt.Key, FormatFilterExpr(filter))
+		} else {
+			values.Add(opt.Key, filter.String())
+		}
+	}
+	return values
+}
+
+// FilterKeyStyle reads a filter from a query string key that encodes the field and operator, such as serves[gte]=4.
+// It returns false if the key is not in its style, in which case the key is ignored.
+type FilterKeyStyle func(key, value string) (Filter, bool)
+
+// keyStyleOperators maps operators written in filter keys to filter operators.
+var keyStyleOperators = map[string]string{
+	"eq":       "eq",
+	"neq":      "neq",
+	"gt":       "gt",
+	"gte":      "gte",
+	"lt":       "lt",
+	"lte":      "lte",
+	"in":       "in",
+	"not_in":   "not in",
+	"like":     "like",
+	"not_like": "not like",
+	"co":       "co",
+	"sw":       "sw",
+	"ew":       "ew",
+}
+
+// BracketKeyStyle reads filters from keys in the form field[operator], such as serves[gte]=4.
+// Operators containing a space are written with an underscore, such as status[not_in]=draft,deleted.
+// The pr operator cannot be written in a key, since it does not take a value.
+func BracketKeyStyle(key, value string) (Filter, bool) {
+	open := strings.LastIndexByte(key, '[')
+	if open < 1 || !strings.HasSuffix(key, "]") {
+		return Filter{}, false
+	}
+	operator, ok := keyStyleOperators[key[open+1:len(key)-1]]
+	if !ok || !isKeyStyleField(key[:open]) {
+		return Filter{}, false
+	}
+	return Filter{Field: key[:open], Operator: operator, Value: value}, true
+}
+
+// DjangoKeyStyle reads filters from keys in the form field__lookup, as used by Django, such as serves__gte=4.
+// The operators supported by BracketKeyStyle can be used as lookups, as well as exact, contains, startswith and endswith.
+// The last three are read as like filters, so their values are matched literally.
+func DjangoKeyStyle(key, value string) (Filter, bool) {
+	sep := strings.LastIndex(key, "__")
+	if sep < 1 || !isKeyStyleField(key[:sep]) {
+		return Filter{}, false
+	}
+	field, lookup := key[:sep], key[sep+2:]
+
+	pattern := ""
+	switch lookup {
+	case "exact":
+		return Filter{Field: field, Operator: "eq", Value: value}, true
+	case "contains":
+		pattern = "%" + EscapeLike(value) + "%"
+	case "startswith":
+		pattern = EscapeLike(value) + "%"
+	case "endswith":
+		pattern = "%" + EscapeLike(value)
+	default:
+		operator, ok := keyStyleOperators[lookup]
+		if !ok {
+			return Filter{}, false
+		}
+		return Filter{Field: field, Operator: operator, Value: value}, true
+	}
+	if value == "" {
+		// An empty value is reported as missing, rather than matching everything
+		pattern = ""
+	}
+	return Filter{Field: field, Operator: "like", Value: pattern}, true
+}
+
 // ReadFiltersOptions configures the behaviour of ReadFilters.
 type ReadFiltersOptions struct {
 	Key        string // Query string key. The default value is "filter"
 	MaxFilters int    // If this is > 0, a maximum number of filters is imposed
+
+	// Additional styles of keys to read filters from, such as BracketKeyStyle.
+	// Filters read from these keys follow those read from the filter key, sorted by key, and are combined with and.
+	// Key styles only apply to the page itself, not to pages scoped to a join.
+	KeyStyles []FilterKeyStyle
+
+	// If this is true, filters are parsed as boolean expressions (see ReadFilterExpr).
+	// ReadFilters returns ErrComplexFilter if the expression cannot be flattened into a Filters slice.
+	Expr bool
 }
 
 // ReadFilters parses URL values into a slice of filters.
@@ -161,19 +324,44 @@ type ReadFiltersOptions struct {
 func ReadFilters(values url.Values, opt *ReadFiltersOptions) (Filters, error) {
 	opt = initFiltersOptions(opt)
 
-	if !values.Has(opt.Key) {
+	if opt.Expr {
+		expr, err := ReadFilterExpr(values, opt)
+		if err != nil {
+			return nil, err
+		}
+		filters, ok := FlattenFilters(expr)
+		if !ok {
+			return nil, ErrComplexFilter
+		}
+		return filters, nil
+	}
+
+	filters, errs := readFilters(values, opt)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return filters, nil
+}
+
+// readFilters parses URL values into a slice of filters, collecting all errors.
+func readFilters(values url.Values, opt *ReadFiltersOptions) (Filters, Errors) {
+	styled := readStyledFilters(values, opt)
+	if !values.Has(opt.Key) && len(styled) == 0 {
 		return nil, nil
 	}
 
 	if opt.MaxFilters > 0 && len(values[opt.Key]) > opt.MaxFilters {
-		return nil, ErrTooManyFilters
+		return nil, Errors{NewParseError(opt.Key, opt.MaxFilters, values[opt.Key][opt.MaxFilters], 0, ReasonTooMany, ErrTooManyFilters)}
 	}
 
 	filters := Filters{}
-	for _, filterStr := range values[opt.Key] {
+	errs := Errors{}
+	for i, filterStr := range values[opt.Key] {
 		match := filterRegexp.FindStringSubmatch(filterStr)
 		if match == nil {
-			return nil, ErrInvalidFilter
+			offset, reason := diagnoseFilter(filterStr)
+			errs = append(errs, NewParseError(opt.Key, i, filterStr, offset, reason, ErrInvalidFilter))
+			continue
 		}
 
 		filter := Filter{
@@ -181,12 +369,83 @@ func ReadFilters(values url.Values, opt *ReadFiltersOptions) (Filters, error) {
 			Operator: match[2],
 			Value:    match[3],
 		}
+		if match[4] != "" {
+			filter.Operator = match[4]
+		}
 		filters = append(filters, filter)
 	}
 
+	styledErrs := validateStyledFilters(styled, len(values[opt.Key]), opt)
+	errs = append(errs, styledErrs...)
+	for _, sf := range styled {
+		filters = append(filters, sf.Filter)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	return filters, nil
 }
 
+// filterSource is a filter along with the query string value it was read from.
+type filterSource struct {
+	Filter Filter
+	Key    string
+	Index  int
+	Value  string
+}
+
+// readStyledFilters reads filters from keys matching any of the configured key styles, sorted by key.
+func readStyledFilters(values url.Values, opt *ReadFiltersOptions) []filterSource {
+	if len(opt.KeyStyles) == 0 {
+		return nil
+	}
+
+	keys := []string{}
+	for key := range values {
+		if key != opt.Key {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	styled := []filterSource{}
+	for _, key := range keys {
+		for i, value := range values[key] {
+			if filter, ok := opt.styledFilter(key, value); ok {
+				styled = append(styled, filterSource{Filter: filter, Key: key, Index: i, Value: value})
+			}
+		}
+	}
+	return styled
+}
+
+// styledFilter reads a filter from a key using the first matching key style.
+func (opt *ReadFiltersOptions) styledFilter(key, value string) (Filter, bool) {
+	for _, style := range opt.KeyStyles {
+		if filter, ok := style(key, value); ok {
+			return filter, true
+		}
+	}
+	return Filter{}, false
+}
+
+// validateStyledFilters returns errors for filters read using key styles, given the number of filters already read.
+func validateStyledFilters(styled []filterSource, count int, opt *ReadFiltersOptions) Errors {
+	errs := Errors{}
+	for n, sf := range styled {
+		if opt.MaxFilters > 0 && count+n >= opt.MaxFilters {
+			return append(errs, NewParseError(sf.Key, sf.Index, sf.Value, 0, ReasonTooMany, ErrTooManyFilters))
+		}
+		if sf.Filter.Value == "" {
+			errs = append(errs, NewParseError(sf.Key, sf.Index, sf.Value, 0, ReasonMissingValue, ErrInvalidFilter))
+		} else if !isFilterOperator(sf.Filter.Operator) {
+			errs = append(errs, NewParseError(sf.Key, sf.Index, sf.Value, 0, ReasonUnknownOperator, ErrInvalidFilter))
+		}
+	}
+	return errs
+}
+
 // ReadRequestFilters parses a request's query string into a slice of filters.
 // This function returns nil if no filters are found.
 func ReadRequestFilters(req *http.Request, opt *ReadFiltersOptions) (Filters, error) {
@@ -203,6 +462,74 @@ func ReadStringFilters(qs string, opt *ReadFiltersOptions) (Filters, error) {
 	return ReadFilters(values, opt)
 }
 
+// IsField returns true if s is a valid field name.
+// Field names consist of characters matching [A-z0-9].
+func IsField(s string) bool {
+	return s != "" && scanField(s) == len(s)
+}
+
+// diagnoseFilter finds the offset and reason for a filter string not matching filterRegexp.
+func diagnoseFilter(s string) (int, Reason) {
+	n := scanField(s)
+	if n == 0 || n < len(s) && s[n] != ' ' {
+		return n, ReasonBadField
+	}
+	if n+1 >= len(s) {
+		return n, ReasonMissingOperator
+	}
+
+	rest := s[n+1:]
+	for _, op := range filterOperators {
+		if rest == op || rest == op+" " {
+			return len(s), ReasonMissingValue
+		}
+	}
+	for _, op := range unaryFilterOperators {
+		if strings.HasPrefix(rest, op+" ") {
+			return n + len(op) + 2, ReasonUnexpectedToken
+		}
+	}
+	return n + 1, ReasonUnknownOperator
+}
+
+// isKeyStyleField returns true if s is a field name that can be read from a filter key.
+// Unlike other field names, these may only contain letters, digits and underscores, so that keys used for other purposes are not mistaken for filters.
+func isKeyStyleField(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// FilterOperators returns the filter operators that take a value, such as eq and not in.
+// Unary operators, such as pr, are not included.
+// This is exported for the use of readers that parse other query formats.
+func FilterOperators() []string {
+	return append([]string{}, filterOperators...)
+}
+
+func isFilterOperator(operator string) bool {
+	for _, op := range filterOperators {
+		if op == operator {
+			return true
+		}
+	}
+	return isUnaryFilterOperator(operator)
+}
+
+// isUnaryFilterOperator returns true if an operator does not take a value, such as pr.
+func isUnaryFilterOperator(operator string) bool {
+	for _, op := range unaryFilterOperators {
+		if op == operator {
+			return true
+		}
+	}
+	return false
+}
+
 func initFiltersOptions(opt *ReadFiltersOptions) *ReadFiltersOptions {
 	def := &ReadFiltersOptions{
 		Key: "filter",
@@ -216,7 +543,20 @@ func initFiltersOptions(opt *ReadFiltersOptions) *ReadFiltersOptions {
 		if opt.MaxFilters > def.MaxFilters {
 			def.MaxFilters = opt.MaxFilters
 		}
+
+		def.Expr = opt.Expr
+		def.KeyStyles = opt.KeyStyles
 	}
 
 	return def
 }
+
+// scanField returns the length of the field name at the start of a string.
+// Field names consist of characters matching [A-z0-9].
+func scanField(s string) int {
+	n := 0
+	for n < len(s) && (s[n] >= 'A' && s[n] <= 'z' || s[n] >= '0' && s[n] <= '9') {
+		n++
+	}
+	return n
+}
@@ -28,12 +28,75 @@ func TestReadFilters(t *testing.T) {
 				{Field: "serves", Operator: "gte", Value: "4"},
 			},
 		},
+		{
+			Input: "filter=title co 100%25&filter=author pr&filter=title sw Spag",
+			Output: []Filter{
+				{Field: "title", Operator: "co", Value: "100%"},
+				{Field: "author", Operator: "pr"},
+				{Field: "title", Operator: "sw", Value: "Spag"},
+			},
+		},
+		{Input: "filter=author pr anne", Err: ErrInvalidFilter},
+		{Input: "filter=title ew", Err: ErrInvalidFilter},
+		{
+			Input: "filter=title eq 'Spaghetti Bolognese' and serves gte 4",
+			Opt:   &ReadFiltersOptions{Expr: true},
+			Output: []Filter{
+				{Field: "title", Operator: "eq", Value: "Spaghetti Bolognese"},
+				{Field: "serves", Operator: "gte", Value: "4"},
+			},
+		},
+		{
+			Input: "filter=title eq Bolognese or serves gte 4",
+			Opt:   &ReadFiltersOptions{Expr: true},
+			Err:   ErrComplexFilter,
+		},
+		{
+			Input: "serves[gte]=4&status[not_in]=draft,deleted&filter=title eq Bolognese&fields[author]=name&page[limit]=10",
+			Opt:   &ReadFiltersOptions{KeyStyles: []FilterKeyStyle{BracketKeyStyle}},
+			Output: []Filter{
+				{Field: "title", Operator: "eq", Value: "Bolognese"},
+				{Field: "serves", Operator: "gte", Value: "4"},
+				{Field: "status", Operator: "not in", Value: "draft,deleted"},
+			},
+		},
+		{
+			Input: "serves__gte=4&title__contains=50%25&serves[lt]=8&sort=title asc",
+			Opt:   &ReadFiltersOptions{KeyStyles: []FilterKeyStyle{BracketKeyStyle, DjangoKeyStyle}},
+			Output: []Filter{
+				{Field: "serves", Operator: "lt", Value: "8"},
+				{Field: "serves", Operator: "gte", Value: "4"},
+				{Field: "title", Operator: "like", Value: `%50\%%`},
+			},
+		},
+		{
+			Input: "serves__gte=4&filter=title eq Bolognese",
+			Opt:   &ReadFiltersOptions{Expr: true, KeyStyles: []FilterKeyStyle{DjangoKeyStyle}},
+			Output: []Filter{
+				{Field: "title", Operator: "eq", Value: "Bolognese"},
+				{Field: "serves", Operator: "gte", Value: "4"},
+			},
+		},
+		{
+			Input:  "serves[gte]=4",
+			Output: nil,
+		},
+		{
+			Input: "serves[gte]=",
+			Opt:   &ReadFiltersOptions{KeyStyles: []FilterKeyStyle{BracketKeyStyle}},
+			Err:   ErrInvalidFilter,
+		},
+		{
+			Input: "filter=title eq Bolognese&serves[gte]=4&serves[lte]=8",
+			Opt:   &ReadFiltersOptions{MaxFilters: 2, KeyStyles: []FilterKeyStyle{BracketKeyStyle}},
+			Err:   ErrTooManyFilters,
+		},
 	}
 
 	for n, tc := range testCases {
 		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
 
-		filters, err := ReadStringFilters(tc.Input, nil)
+		filters, err := ReadStringFilters(tc.Input, tc.Opt)
 
 		if !errors.Is(err, tc.Err) {
 			t.Errorf("Expected error %v, got %v", tc.Err, err)
@@ -61,3 +124,123 @@ func TestReadFilters(t *testing.T) {
 		}
 	}
 }
+
+func TestFilterLikeSegments(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output []string
+	}
+
+	testCases := []TestCase{
+		{Input: "Bolognese", Output: []string{"Bolognese"}},
+		{Input: "%soup%", Output: []string{"", "soup", ""}},
+		{Input: `100\%%`, Output: []string{"100%", ""}},
+		{Input: EscapeLike(`50% \ 50`), Output: []string{`50% \ 50`}},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		segments := Filter{Field: "title", Operator: "like", Value: tc.Input}.LikeSegments()
+
+		if len(segments) != len(tc.Output) {
+			t.Errorf("Expected %d segments, got %d", len(tc.Output), len(segments))
+			continue
+		}
+
+		for i, segment := range tc.Output {
+			if segment != segments[i] {
+				t.Errorf("Expected %q for segment %d, got %q", segment, i, segments[i])
+			}
+		}
+	}
+}
+
+func TestFilterLikePattern(t *testing.T) {
+	type TestCase struct {
+		Input  Filter
+		Output string
+	}
+
+	testCases := []TestCase{
+		{Input: Filter{Field: "title", Operator: "like", Value: "%soup%"}, Output: "%soup%"},
+		{Input: Filter{Field: "title", Operator: "co", Value: "100%"}, Output: `%100\%%`},
+		{Input: Filter{Field: "title", Operator: "sw", Value: "Spag"}, Output: "Spag%"},
+		{Input: Filter{Field: "title", Operator: "ew", Value: `a\b`}, Output: `%a\\b`},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v", n, tc.Input)
+
+		if pattern := tc.Input.LikePattern(); pattern != tc.Output {
+			t.Errorf("Expected %q, got %q", tc.Output, pattern)
+		}
+	}
+}
+
+func TestFilterKeyStyles(t *testing.T) {
+	type TestCase struct {
+		Style  FilterKeyStyle
+		Key    string
+		Value  string
+		Output Filter
+		OK     bool
+	}
+
+	testCases := []TestCase{
+		{Style: BracketKeyStyle, Key: "serves[gte]", Value: "4", Output: Filter{Field: "serves", Operator: "gte", Value: "4"}, OK: true},
+		{Style: BracketKeyStyle, Key: "title[not_like]", Value: "Spag%", Output: Filter{Field: "title", Operator: "not like", Value: "Spag%"}, OK: true},
+		{Style: BracketKeyStyle, Key: "created_at[lt]", Value: "2024", Output: Filter{Field: "created_at", Operator: "lt", Value: "2024"}, OK: true},
+		{Style: BracketKeyStyle, Key: "fields[author]", Value: "name"},
+		{Style: BracketKeyStyle, Key: "filter[title][eq]", Value: "x"},
+		{Style: BracketKeyStyle, Key: "[eq]", Value: "x"},
+		{Style: BracketKeyStyle, Key: "serves", Value: "4"},
+		{Style: DjangoKeyStyle, Key: "serves__gte", Value: "4", Output: Filter{Field: "serves", Operator: "gte", Value: "4"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "status__not_in", Value: "a,b", Output: Filter{Field: "status", Operator: "not in", Value: "a,b"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "title__exact", Value: "Soup", Output: Filter{Field: "title", Operator: "eq", Value: "Soup"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "title__startswith", Value: "Spag_", Output: Filter{Field: "title", Operator: "like", Value: "Spag_%"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "title__endswith", Value: "ese", Output: Filter{Field: "title", Operator: "like", Value: "%ese"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "title__contains", Value: "", Output: Filter{Field: "title", Operator: "like"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "author__name__exact", Value: "Anny", Output: Filter{Field: "author__name", Operator: "eq", Value: "Anny"}, OK: true},
+		{Style: DjangoKeyStyle, Key: "title__regex", Value: "x"},
+		{Style: DjangoKeyStyle, Key: "__gte", Value: "4"},
+		{Style: DjangoKeyStyle, Key: "comments.serves__gte", Value: "4"},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q=%q", n, tc.Key, tc.Value)
+
+		filter, ok := tc.Style(tc.Key, tc.Value)
+		if ok != tc.OK {
+			t.Errorf("Expected %t, got %t", tc.OK, ok)
+		}
+		if filter != tc.Output {
+			t.Errorf("Expected %+v, got %+v", tc.Output, filter)
+		}
+	}
+}
+
+func TestIsField(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output bool
+	}
+
+	testCases := []TestCase{
+		{Input: "title", Output: true},
+		{Input: "Title2", Output: true},
+		{Input: "created_at", Output: true},
+		{Input: "", Output: false},
+		{Input: "author.name", Output: false},
+		{Input: "title eq", Output: false},
+		{Input: "-title", Output: false},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("(%d) Testing %q", i, tc.Input)
+
+		if IsField(tc.Input) != tc.Output {
+			t.Errorf("Expected %v, got %v", tc.Output, !tc.Output)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strings"
 )
 
 // Query error.
@@ -13,39 +15,136 @@ var (
 	ErrTooManyJoins = errors.New("too many joins")
 )
 
-var joinRegexp = regexp.MustCompile("^[a-z0-9]+$")
+var joinRegexp = regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9]+)*$`)
 
 // Joins represents joins as used in, most likely, a database query.
 // This is a simplified instruction that should generally be interpreted as "join Y entity onto X entity".
+//
+// Nested joins are expressed as dotted paths, such as "author.profile", meaning "join profile onto author".
+// A nested join implies each of its parents, so the map contains every prefix of a path as well as the path itself.
 type Joins map[string]bool
 
+// Add adds a join path, along with each of its parents.
+func (joins Joins) Add(path string) {
+	segments := strings.Split(path, ".")
+	for n := range segments {
+		joins[strings.Join(segments[:n+1], ".")] = true
+	}
+}
+
+// Child returns the joins nested beneath a join, with paths relative to it.
+// For example, if joins contains "author.profile.avatar", the child joins for "author" contain "profile" and "profile.avatar".
+// This function returns nil if there are no nested joins.
+func (joins Joins) Child(path string) Joins {
+	var child Joins
+	prefix := path + "."
+	for name, join := range joins {
+		if join && strings.HasPrefix(name, prefix) {
+			if child == nil {
+				child = Joins{}
+			}
+			child[name[len(prefix):]] = true
+		}
+	}
+	return child
+}
+
+// Has returns true if a join path is requested, either directly or as the parent of a nested join.
+func (joins Joins) Has(path string) bool {
+	return joins[path]
+}
+
+// names returns the paths of all requested joins, including parents, sorted by name.
+func (joins Joins) names() []string {
+	names := []string{}
+	for name, join := range joins {
+		if join {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Paths returns the paths of requested joins that are not the parent of another join, sorted by name.
+func (joins Joins) Paths() []string {
+	paths := []string{}
+	for name, join := range joins {
+		if !join || joins.Child(name) != nil {
+			continue
+		}
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Values returns the joins as URL values, using the same options as ReadJoins.
+// Parents of nested joins are implied, so only the paths returned by Joins.Paths are included.
+func (joins Joins) Values(opt *ReadJoinsOptions) url.Values {
+	opt = initJoinsOptions(opt)
+
+	values := url.Values{}
+	for _, path := range joins.Paths() {
+		values.Add(opt.Key, path)
+	}
+	return values
+}
+
 // ReadJoinsOptions configures the behaviour of ReadJoins.
 type ReadJoinsOptions struct {
 	Key      string // Query string key. The default value is "join"
 	MaxJoins int    // If this is > 0, a maximum number of joins is imposed
+	MaxDepth int    // If this is > 0, a maximum depth of nested joins is imposed. For example, "author.profile" has a depth of 2
+}
+
+// IsJoin returns true if s is a valid join path, such as "author.profile".
+// Join paths consist of lower-case names matching [a-z0-9], separated by dots.
+func IsJoin(s string) bool {
+	return joinRegexp.MatchString(s)
 }
 
 // ReadJoins parses URL values into a slice of joins.
 // This function returns nil if no joins are found.
 func ReadJoins(values url.Values, opt *ReadJoinsOptions) (Joins, error) {
-	opt = initJoinsOptions(opt)
+	joins, errs := readJoins(values, initJoinsOptions(opt))
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return joins, nil
+}
 
+// readJoins parses URL values into a Joins map, collecting all errors.
+func readJoins(values url.Values, opt *ReadJoinsOptions) (Joins, Errors) {
 	if !values.Has(opt.Key) {
 		return nil, nil
 	}
 
 	if opt.MaxJoins > 0 && len(values[opt.Key]) > opt.MaxJoins {
-		return nil, ErrTooManyJoins
+		return nil, Errors{NewParseError(opt.Key, opt.MaxJoins, values[opt.Key][opt.MaxJoins], 0, ReasonTooMany, ErrTooManyJoins)}
 	}
 
 	joins := Joins{}
-	for _, join := range values[opt.Key] {
+	errs := Errors{}
+	for i, join := range values[opt.Key] {
 		if !joinRegexp.MatchString(join) {
-			return nil, ErrInvalidJoin
+			errs = append(errs, NewParseError(opt.Key, i, join, diagnoseJoin(join), ReasonBadJoin, ErrInvalidJoin))
+			continue
+		}
+
+		segments := strings.Split(join, ".")
+		if opt.MaxDepth > 0 && len(segments) > opt.MaxDepth {
+			offset := len(strings.Join(segments[:opt.MaxDepth], "."))
+			errs = append(errs, NewParseError(opt.Key, i, join, offset, ReasonTooDeep, ErrInvalidJoin))
+			continue
 		}
-		joins[join] = true
+
+		joins.Add(join)
 	}
 
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	if len(joins) > 0 {
 		return joins, nil
 	}
@@ -81,7 +180,29 @@ func initJoinsOptions(opt *ReadJoinsOptions) *ReadJoinsOptions {
 		if opt.MaxJoins > def.MaxJoins {
 			def.MaxJoins = opt.MaxJoins
 		}
+
+		if opt.MaxDepth > def.MaxDepth {
+			def.MaxDepth = opt.MaxDepth
+		}
 	}
 
 	return def
 }
+
+// diagnoseJoin returns the offset of the first invalid character in a join path.
+func diagnoseJoin(join string) int {
+	offset := 0
+	for offset < len(join) {
+		c := join[offset]
+		if c == '.' {
+			// Dots must separate non-empty segments
+			if offset == 0 || join[offset-1] == '.' || offset == len(join)-1 {
+				return offset
+			}
+		} else if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return offset
+		}
+		offset++
+	}
+	return offset
+}
@@ -2,6 +2,7 @@ package qs
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -23,12 +24,27 @@ func TestReadJoins(t *testing.T) {
 			Input:  "join=author&join=ingredient",
 			Output: Joins{"author": true, "ingredient": true},
 		},
+		{
+			Input:  "join=author.profile&join=ingredient.supplier.address",
+			Output: Joins{"author": true, "author.profile": true, "ingredient": true, "ingredient.supplier": true, "ingredient.supplier.address": true},
+		},
+		{
+			Input:  "join=author.profile",
+			Opt:    &ReadJoinsOptions{MaxDepth: 2},
+			Output: Joins{"author": true, "author.profile": true},
+		},
+
+		{Input: "join=Author", Err: ErrInvalidJoin},
+		{Input: "join=author.", Err: ErrInvalidJoin},
+		{Input: "join=.author", Err: ErrInvalidJoin},
+		{Input: "join=author.profile.avatar", Opt: &ReadJoinsOptions{MaxDepth: 2}, Err: ErrInvalidJoin},
+		{Input: "join=author&join=ingredient", Opt: &ReadJoinsOptions{MaxJoins: 1}, Err: ErrTooManyJoins},
 	}
 
 	for n, tc := range testCases {
 		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
 
-		joins, err := ReadStringJoins(tc.Input, nil)
+		joins, err := ReadStringJoins(tc.Input, tc.Opt)
 
 		if !errors.Is(err, tc.Err) {
 			t.Errorf("Expected error %v, got %v", tc.Err, err)
@@ -53,3 +69,57 @@ func TestReadJoins(t *testing.T) {
 		}
 	}
 }
+
+func TestJoinsAdd(t *testing.T) {
+	joins := Joins{}
+	joins.Add("author.profile")
+	joins.Add("ingredient")
+
+	expected := Joins{"author": true, "author.profile": true, "ingredient": true}
+	if !reflect.DeepEqual(joins, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, joins)
+	}
+}
+
+func TestJoinsChild(t *testing.T) {
+	type TestCase struct {
+		Input  Joins
+		Path   string
+		Output Joins
+	}
+
+	joins := Joins{"author": true, "author.profile": true, "author.profile.avatar": true, "ingredient": true}
+
+	testCases := []TestCase{
+		{Input: joins, Path: "author", Output: Joins{"profile": true, "profile.avatar": true}},
+		{Input: joins, Path: "author.profile", Output: Joins{"avatar": true}},
+		{Input: joins, Path: "ingredient"},
+		{Input: joins, Path: "auth"},
+		{Input: nil, Path: "author"},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q in %+v", n, tc.Path, tc.Input)
+
+		child := tc.Input.Child(tc.Path)
+		if !reflect.DeepEqual(child, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, child)
+		}
+	}
+
+	if !joins.Has("author") || !joins.Has("author.profile") || joins.Has("profile") {
+		t.Error("Expected Has to match requested paths and their parents only")
+	}
+}
+
+func TestJoinsValues(t *testing.T) {
+	joins, err := ReadStringJoins("join=ingredient&join=author.profile&join=author", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []string{"author.profile", "ingredient"}
+	if values := joins.Values(nil); !reflect.DeepEqual(values["join"], expected) {
+		t.Errorf("Expected %v, got %v", expected, values["join"])
+	}
+}
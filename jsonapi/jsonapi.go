@@ -0,0 +1,341 @@
+// Package jsonapi reads JSON:API query parameters into qs query objects, so that handlers can serve JSON:API clients without depending on the query string format.
+//
+// The following query parameters are supported:
+//
+//	filter[title]=Bolognese               Read into Page.Filters using the eq operator
+//	filter[serves][gte]=4                 Read into Page.Filters using the given operator
+//	sort=-serves,title                    Read into Page.Sorts. A leading - sorts in descending order
+//	page[limit]=10&page[offset]=20        Read into Page.Pagination
+//	page[size]=10&page[number]=3          Read into Page.Pagination, like the page parameter read by qs.ReadPagination
+//	include=author.profile,ingredient     Read into Page.Joins
+//	fields[recipes]=title,serves          Read into Page.Fields, keyed by join path (see ReadPageOptions)
+//
+// Errors are reported as a *qs.ParseError wrapping the equivalent qs query error, such as qs.ErrInvalidFilter.
+package jsonapi
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// Query string key.
+const (
+	FilterKey  = "filter"
+	SortKey    = "sort"
+	PageKey    = "page"
+	IncludeKey = "include"
+	FieldsKey  = "fields"
+)
+
+// operators are the filter operators that may be given in a filter key.
+// Operators containing a space may also be written with an underscore, such as not_in.
+var operators = qs.FilterOperators()
+
+// ReadPageOptions configures the behaviour of ReadPage.
+type ReadPageOptions struct {
+	Type  string            // Primary resource type. Its sparse fieldset applies to the root entity
+	Types map[string]string // Maps other resource types to join paths, for sparse fieldsets. Types that are not mapped are used as join paths as-is
+
+	MaxLimit   int // If this is > 0, the limit is clamped to this maximum value
+	MaxFilters int // If this is > 0, a maximum number of filters is imposed
+	MaxSorts   int // If this is > 0, a maximum number of sorts is imposed
+	MaxJoins   int // If this is > 0, a maximum number of included paths is imposed
+
+	Schema        *qs.Schema // If set, the page is validated against this schema.
+	CollectErrors bool       // If true, all errors are returned together as qs.Errors instead of only the first error.
+}
+
+// ReadPage parses URL values containing JSON:API query parameters into a qs.Page.
+// Pagination is always set, even if no page parameters are provided.
+//
+// Filters for the same field are combined with and. Values for the in and not in operators are comma-separated.
+func ReadPage(values url.Values, opt *ReadPageOptions) (*qs.Page, error) {
+	if opt == nil {
+		opt = &ReadPageOptions{}
+	}
+
+	page := &qs.Page{}
+	errs := qs.Errors{}
+
+	pag, pagErrs := readPagination(values, opt)
+	errs = append(errs, pagErrs...)
+	page.Pagination = pag
+
+	filters, filterErrs := readFilters(values, opt)
+	errs = append(errs, filterErrs...)
+	page.Filters = filters
+
+	sorts, sortErrs := readSorts(values, opt)
+	errs = append(errs, sortErrs...)
+	page.Sorts = sorts
+
+	joins, joinErrs := readIncludes(values, opt)
+	errs = append(errs, joinErrs...)
+	page.Joins = joins
+
+	fields, fieldsErrs := readFields(values, opt)
+	errs = append(errs, fieldsErrs...)
+	page.Fields = fields
+
+	if len(errs) > 0 && !opt.CollectErrors {
+		return nil, errs[0]
+	}
+
+	if opt.Schema != nil && len(errs) == 0 {
+		if err := opt.Schema.ValidatePage(page); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return page, nil
+	}
+	if !opt.CollectErrors {
+		return nil, errs[0]
+	}
+	return nil, errs
+}
+
+// ReadRequestPage parses a request's query string containing JSON:API query parameters into a qs.Page.
+func ReadRequestPage(req *http.Request, opt *ReadPageOptions) (*qs.Page, error) {
+	return ReadPage(req.URL.Query(), opt)
+}
+
+// ReadStringPage parses a query string literal containing JSON:API query parameters into a qs.Page.
+func ReadStringPage(s string, opt *ReadPageOptions) (*qs.Page, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPage(values, opt)
+}
+
+// readPagination reads page[limit] and page[offset], or page[size] and page[number].
+// If both offset and number are provided, offset is prioritised.
+func readPagination(values url.Values, opt *ReadPageOptions) (*qs.Pagination, qs.Errors) {
+	pag := &qs.Pagination{}
+	errs := qs.Errors{}
+
+	readNumber := func(name string, target *int, err error) bool {
+		key := bracketKey(PageKey, name)
+		if !values.Has(key) {
+			return false
+		}
+		n, err := qs.ReadNumber(values, key, err)
+		if err != nil {
+			errs = append(errs, err)
+			return false
+		}
+		*target = n
+		return true
+	}
+
+	if !readNumber("limit", &pag.Limit, qs.ErrInvalidLimit) {
+		readNumber("size", &pag.Limit, qs.ErrInvalidLimit)
+	}
+	if opt.MaxLimit > 0 && pag.Limit > opt.MaxLimit {
+		pag.Limit = opt.MaxLimit
+	}
+
+	if !values.Has(bracketKey(PageKey, "offset")) && readNumber("number", &pag.Page, qs.ErrInvalidPage) && pag.Page > 0 {
+		pag.Offset = (pag.Page - 1) * pag.Limit
+	}
+	readNumber("offset", &pag.Offset, qs.ErrInvalidOffset)
+
+	return pag, errs
+}
+
+// readFilters reads filter[field] and filter[field][operator] keys, sorted by key.
+func readFilters(values url.Values, opt *ReadPageOptions) (qs.Filters, qs.Errors) {
+	keys := []string{}
+	for key := range values {
+		if strings.HasPrefix(key, FilterKey+"[") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+
+	filters := qs.Filters{}
+	errs := qs.Errors{}
+	for _, key := range keys {
+		args, ok := bracketArgs(FilterKey, key)
+		if !ok || len(args) > 2 || !qs.IsField(args[0]) {
+			errs = append(errs, qs.NewParseError(key, 0, values.Get(key), 0, qs.ReasonBadField, qs.ErrInvalidFilter))
+			continue
+		}
+		operator := "eq"
+		if len(args) == 2 {
+			operator = strings.ReplaceAll(args[1], "_", " ")
+			if !isOperator(operator) {
+				errs = append(errs, qs.NewParseError(key, 0, values.Get(key), 0, qs.ReasonUnknownOperator, qs.ErrInvalidFilter))
+				continue
+			}
+		}
+
+		for i, value := range values[key] {
+			if value == "" {
+				errs = append(errs, qs.NewParseError(key, i, value, 0, qs.ReasonMissingValue, qs.ErrInvalidFilter))
+				continue
+			}
+			if opt.MaxFilters > 0 && len(filters) == opt.MaxFilters {
+				return nil, append(errs, qs.NewParseError(key, i, value, 0, qs.ReasonTooMany, qs.ErrTooManyFilters))
+			}
+			filters = append(filters, qs.Filter{Field: args[0], Operator: operator, Value: value})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return filters, nil
+}
+
+// readSorts reads comma-separated sort fields, each optionally prefixed with - for descending order.
+func readSorts(values url.Values, opt *ReadPageOptions) (qs.Sorts, qs.Errors) {
+	if !values.Has(SortKey) {
+		return nil, nil
+	}
+
+	sorts := qs.Sorts{}
+	errs := qs.Errors{}
+	for i, value := range values[SortKey] {
+		offset := 0
+		for _, field := range strings.Split(value, ",") {
+			sort := qs.Sort{Field: field, Direction: "asc"}
+			if strings.HasPrefix(field, "-") {
+				sort = qs.Sort{Field: field[1:], Direction: "desc"}
+			}
+			if !qs.IsField(sort.Field) {
+				errs = append(errs, qs.NewParseError(SortKey, i, value, offset, qs.ReasonBadField, qs.ErrInvalidSort))
+				break
+			}
+			if opt.MaxSorts > 0 && len(sorts) == opt.MaxSorts {
+				return nil, append(errs, qs.NewParseError(SortKey, i, value, offset, qs.ReasonTooMany, qs.ErrTooManySorts))
+			}
+			sorts = append(sorts, sort)
+			offset += len(field) + 1
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return sorts, nil
+}
+
+// readIncludes reads comma-separated relationship paths into joins.
+func readIncludes(values url.Values, opt *ReadPageOptions) (qs.Joins, qs.Errors) {
+	if !values.Has(IncludeKey) {
+		return nil, nil
+	}
+
+	joins := qs.Joins{}
+	errs := qs.Errors{}
+	count := 0
+	for i, value := range values[IncludeKey] {
+		offset := 0
+		for _, path := range strings.Split(value, ",") {
+			if !qs.IsJoin(path) {
+				errs = append(errs, qs.NewParseError(IncludeKey, i, value, offset, qs.ReasonBadJoin, qs.ErrInvalidJoin))
+				break
+			}
+			count++
+			if opt.MaxJoins > 0 && count > opt.MaxJoins {
+				return nil, append(errs, qs.NewParseError(IncludeKey, i, value, offset, qs.ReasonTooMany, qs.ErrTooManyJoins))
+			}
+			joins.Add(path)
+			offset += len(path) + 1
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return joins, nil
+}
+
+// readFields reads fields[type] keys into a projection, mapping each resource type to a join path.
+func readFields(values url.Values, opt *ReadPageOptions) (qs.Fields, qs.Errors) {
+	keys := []string{}
+	for key := range values {
+		if strings.HasPrefix(key, FieldsKey+"[") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+
+	fields := qs.Fields{}
+	errs := qs.Errors{}
+	for _, key := range keys {
+		args, ok := bracketArgs(FieldsKey, key)
+		if !ok || len(args) != 1 {
+			errs = append(errs, qs.NewParseError(key, 0, values.Get(key), 0, qs.ReasonBadJoin, qs.ErrInvalidFields))
+			continue
+		}
+
+		path := args[0]
+		if path == opt.Type {
+			path = ""
+		} else if mapped, ok := opt.Types[path]; ok {
+			path = mapped
+		}
+
+		names := []string{}
+		for i, value := range values[key] {
+			offset := 0
+			for _, name := range strings.Split(value, ",") {
+				if !qs.IsField(name) {
+					errs = append(errs, qs.NewParseError(key, i, value, offset, qs.ReasonBadField, qs.ErrInvalidFields))
+					break
+				}
+				names = append(names, name)
+				offset += len(name) + 1
+			}
+		}
+		fields[path] = append(fields[path], names...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return fields, nil
+}
+
+// bracketArgs returns the bracketed arguments of a key, such as title and gte in filter[title][gte].
+// This function returns false if the key does not have the given prefix followed by at least one argument.
+func bracketArgs(prefix, key string) ([]string, bool) {
+	s := strings.TrimPrefix(key, prefix)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, false
+	}
+	args := strings.Split(s[1:len(s)-1], "][")
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, "[]") {
+			return nil, false
+		}
+	}
+	return args, true
+}
+
+func bracketKey(prefix, arg string) string {
+	return prefix + "[" + arg + "]"
+}
+
+func isOperator(s string) bool {
+	for _, op := range operators {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
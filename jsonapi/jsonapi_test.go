@@ -0,0 +1,132 @@
+package jsonapi
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestReadPage(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output *qs.Page
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{
+			Input: "filter[title]=Bolognese&filter[serves][gte]=4&sort=-serves,title&page[limit]=10&page[offset]=20",
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{Limit: 10, Offset: 20},
+				Filters:    qs.Filters{{Field: "serves", Operator: "gte", Value: "4"}, {Field: "title", Operator: "eq", Value: "Bolognese"}},
+				Sorts:      qs.Sorts{{Field: "serves", Direction: "desc"}, {Field: "title", Direction: "asc"}},
+			},
+		},
+		{
+			Input: "filter[status][not_in]=draft,deleted&filter[title][like]=Spag%25&filter[serves][gt]=2&filter[serves][lt]=6",
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				Filters: qs.Filters{
+					{Field: "serves", Operator: "gt", Value: "2"},
+					{Field: "serves", Operator: "lt", Value: "6"},
+					{Field: "status", Operator: "not in", Value: "draft,deleted"},
+					{Field: "title", Operator: "like", Value: "Spag%"},
+				},
+			},
+		},
+		{
+			Input: "filter[title][co]=Spag&filter[title][sw]=S&filter[title][ew]=i",
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				Filters: qs.Filters{
+					{Field: "title", Operator: "co", Value: "Spag"},
+					{Field: "title", Operator: "ew", Value: "i"},
+					{Field: "title", Operator: "sw", Value: "S"},
+				},
+			},
+		},
+		{
+			Input:  "page[size]=10&page[number]=3",
+			Output: &qs.Page{Pagination: &qs.Pagination{Limit: 10, Offset: 20, Page: 3}},
+		},
+		{
+			Input:  "page[size]=10&page[number]=3&page[offset]=5",
+			Output: &qs.Page{Pagination: &qs.Pagination{Limit: 10, Offset: 5}},
+		},
+		{
+			Input:  "page[limit]=500",
+			Opt:    &ReadPageOptions{MaxLimit: 100},
+			Output: &qs.Page{Pagination: &qs.Pagination{Limit: 100}},
+		},
+		{
+			Input: "include=author.profile,ingredient&fields[recipes]=title,serves&fields[people]=name&fields[ingredient]=name",
+			Opt:   &ReadPageOptions{Type: "recipes", Types: map[string]string{"people": "author"}},
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				Joins:      qs.Joins{"author": true, "author.profile": true, "ingredient": true},
+				Fields:     qs.Fields{"": {"title", "serves"}, "author": {"name"}, "ingredient": {"name"}},
+			},
+		},
+		{Input: "filter[title]=a&filter[serves]=4", Opt: &ReadPageOptions{MaxFilters: 1}, Err: qs.ErrTooManyFilters},
+		{Input: "sort=a,b", Opt: &ReadPageOptions{MaxSorts: 1}, Err: qs.ErrTooManySorts},
+		{Input: "include=a,b", Opt: &ReadPageOptions{MaxJoins: 1}, Err: qs.ErrTooManyJoins},
+		{Input: "filter[author.name]=Anny", Err: qs.ErrInvalidFilter},
+		{Input: "filter[serves][is]=4", Err: qs.ErrInvalidFilter},
+		{Input: "filter[title]=", Err: qs.ErrInvalidFilter},
+		{Input: "sort=-", Err: qs.ErrInvalidSort},
+		{Input: "page[limit]=ten", Err: qs.ErrInvalidLimit},
+		{Input: "page[offset]=-1", Err: qs.ErrInvalidOffset},
+		{Input: "page[number]=x", Err: qs.ErrInvalidPage},
+		{Input: "include=author..profile", Err: qs.ErrInvalidJoin},
+		{Input: "include=Author", Err: qs.ErrInvalidJoin},
+		{Input: "include=author_profile", Err: qs.ErrInvalidJoin},
+		{Input: "include=author.pro^file", Err: qs.ErrInvalidJoin},
+		{Input: "fields[recipes]=title,", Err: qs.ErrInvalidFields},
+		{
+			Input: "sort=rating",
+			Opt:   &ReadPageOptions{Schema: &qs.Schema{Fields: map[string]qs.Field{"rating": {Filter: true}}}},
+			Err:   qs.ErrSortNotAllowed,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := ReadStringPage(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if !reflect.DeepEqual(page, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, page)
+		}
+	}
+}
+
+func TestReadPageErrors(t *testing.T) {
+	_, err := ReadStringPage("filter[serves][is]=4&sort=title,-&page[limit]=ten", &ReadPageOptions{CollectErrors: true})
+
+	errs := qs.Errors{}
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected qs.Errors, got %v", err)
+	}
+
+	expected := []*qs.ParseError{
+		{Key: "page[limit]", Input: "ten", Reason: qs.ReasonBadNumber, Err: qs.ErrInvalidLimit},
+		{Key: "filter[serves][is]", Input: "4", Reason: qs.ReasonUnknownOperator, Err: qs.ErrInvalidFilter},
+		{Key: "sort", Input: "title,-", Offset: 6, Reason: qs.ReasonBadField, Err: qs.ErrInvalidSort},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !reflect.DeepEqual(err, expected[i]) {
+			t.Errorf("Expected %+v, got %+v", expected[i], err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package qs
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Links contains navigation URLs for a page of results.
+// Links that do not apply, such as Prev on the first page, are empty.
+type Links struct {
+	First string `json:"first,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Last  string `json:"last,omitempty"`
+}
+
+// CursorLinks creates navigation links for keyset pagination, given the cursors for the next and previous pages.
+// Either cursor may be nil, in which case the corresponding link is omitted. Last is never set.
+// See PageLinks for how URLs are constructed.
+func CursorLinks(u *url.URL, page *Page, next, prev *Cursor, opt *ReadPageOptions) (Links, error) {
+	opt = initPageOptions(opt)
+
+	values, err := linkValues(u, page, opt)
+	if err != nil {
+		return Links{}, err
+	}
+
+	limit := 0
+	if page.Pagination != nil {
+		limit = page.Pagination.Limit
+	}
+
+	link := func(cursor *Cursor) (string, error) {
+		pag := &Pagination{Limit: limit, Cursor: cursor}
+		return pageLink(u, values, pag, opt)
+	}
+
+	links := Links{}
+	if links.First, err = link(nil); err != nil {
+		return Links{}, err
+	}
+	if next != nil {
+		if links.Next, err = link(&Cursor{Values: next.Values}); err != nil {
+			return Links{}, err
+		}
+	}
+	if prev != nil {
+		if links.Prev, err = link(&Cursor{Values: prev.Values, Before: true}); err != nil {
+			return Links{}, err
+		}
+	}
+	return links, nil
+}
+
+// PageLinks creates first, previous, next and last navigation links for offset pagination, given the total number of results.
+//
+// Links are based on the request URL u, which may be relative or absolute.
+// Query string parameters not used by this package are preserved, while filters, sorts, joins, pages scoped to joins, fields and search terms are written from the page.
+// If the page was read using page numbers, links also use page numbers; otherwise, they use offsets.
+//
+// If the page has no limit, only the first link is set.
+func PageLinks(u *url.URL, page *Page, total int, opt *ReadPageOptions) (Links, error) {
+	opt = initPageOptions(opt)
+
+	values, err := linkValues(u, page, opt)
+	if err != nil {
+		return Links{}, err
+	}
+
+	pag := page.Pagination
+	if pag == nil {
+		pag = &Pagination{}
+	}
+
+	link := func(offset int) (string, error) {
+		linkPag := &Pagination{Limit: pag.Limit, Offset: offset}
+		if pag.Page > 0 && pag.Limit > 0 {
+			linkPag.Page = offset/pag.Limit + 1
+		}
+		return pageLink(u, values, linkPag, opt)
+	}
+
+	links := Links{}
+	if links.First, err = link(0); err != nil || pag.Limit <= 0 {
+		return links, err
+	}
+
+	// Errors can only arise from signing cursors, so further links cannot fail
+
+	if pag.Offset > 0 {
+		prev := pag.Offset - pag.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev, _ = link(prev)
+	}
+
+	if pag.Offset+pag.Limit < total {
+		links.Next, _ = link(pag.Offset + pag.Limit)
+	}
+
+	last := 0
+	if total > 0 {
+		last = (total - 1) / pag.Limit * pag.Limit
+	}
+	links.Last, _ = link(last)
+
+	return links, nil
+}
+
+// WriteLinkHeader writes navigation links to a response as an RFC 8288 Link header.
+// If there are no links, no header is written.
+func WriteLinkHeader(w http.ResponseWriter, links Links) {
+	if header := links.Header(); header != "" {
+		w.Header().Add("Link", header)
+	}
+}
+
+// Header returns the links formatted as an RFC 8288 Link header value.
+func (links Links) Header() string {
+	parts := []string{}
+	for _, link := range []struct{ rel, url string }{
+		{"first", links.First},
+		{"prev", links.Prev},
+		{"next", links.Next},
+		{"last", links.Last},
+	} {
+		if link.url != "" {
+			parts = append(parts, "<"+link.url+">; rel=\""+link.rel+"\"")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// linkValues returns the query string values common to all links, excluding pagination.
+func linkValues(u *url.URL, page *Page, opt *ReadPageOptions) (url.Values, error) {
+	values := u.Query()
+
+	for _, key := range append(pageKeys(opt), initJoinsOptions(opt.Join).Key) {
+		values.Del(key)
+	}
+	if opt.Search != nil {
+		values.Del(opt.Search.Key)
+	}
+	for path := range page.JoinPages {
+		for _, key := range pageKeys(joinPageOptions(opt, path)) {
+			values.Del(key)
+		}
+	}
+	filterOpt := initFiltersOptions(opt.Filter)
+	for key := range values {
+		if _, ok := fieldsPath(initFieldsOptions(opt.Fields).Key, key); ok && opt.Fields != nil {
+			values.Del(key)
+		} else if _, ok := filterOpt.styledFilter(key, values.Get(key)); ok {
+			// Filters read from other keys are written to the filter key instead
+			values.Del(key)
+		}
+	}
+
+	pageValues, err := (&Page{
+		Filters:    page.Filters,
+		FilterExpr: page.FilterExpr,
+		Sorts:      page.Sorts,
+		Joins:      page.Joins,
+		JoinPages:  page.JoinPages,
+		Fields:     page.Fields,
+		Search:     page.Search,
+	}).Values(opt)
+	if err != nil {
+		return nil, err
+	}
+	mergeValues(values, pageValues)
+	return values, nil
+}
+
+func pageLink(u *url.URL, values url.Values, pag *Pagination, opt *ReadPageOptions) (string, error) {
+	pagValues, err := pag.Values(opt.Pagination)
+	if err != nil {
+		return "", err
+	}
+
+	linkValues := url.Values{}
+	mergeValues(linkValues, values)
+	mergeValues(linkValues, pagValues)
+
+	link := *u
+	link.RawQuery = linkValues.Encode()
+	return link.String(), nil
+}
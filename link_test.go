@@ -0,0 +1,128 @@
+package qs
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPageLinks(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Total  int
+		Output Links
+	}
+
+	testCases := []TestCase{
+		{
+			Input:  "/recipes?filter=serves+gte+4",
+			Total:  100,
+			Output: Links{First: "/recipes?filter=serves+gte+4"},
+		},
+		{
+			Input: "/recipes?limit=10&offset=15&sort=title+asc&lang=en",
+			Total: 42,
+			Output: Links{
+				First: "/recipes?lang=en&limit=10&sort=title+asc",
+				Prev:  "/recipes?lang=en&limit=10&offset=5&sort=title+asc",
+				Next:  "/recipes?lang=en&limit=10&offset=25&sort=title+asc",
+				Last:  "/recipes?lang=en&limit=10&offset=40&sort=title+asc",
+			},
+		},
+		{
+			Input: "https://example.com/recipes?limit=10&page=2&join=author",
+			Total: 20,
+			Output: Links{
+				First: "https://example.com/recipes?join=author&limit=10&page=1",
+				Prev:  "https://example.com/recipes?join=author&limit=10&page=1",
+				Last:  "https://example.com/recipes?join=author&limit=10&page=2",
+			},
+		},
+		{
+			Input: "/recipes?limit=10&join=comments&comments.limit=5&comments.sort=created+desc",
+			Total: 15,
+			Output: Links{
+				First: "/recipes?comments.limit=5&comments.sort=created+desc&join=comments&limit=10",
+				Next:  "/recipes?comments.limit=5&comments.sort=created+desc&join=comments&limit=10&offset=10",
+				Last:  "/recipes?comments.limit=5&comments.sort=created+desc&join=comments&limit=10&offset=10",
+			},
+		},
+		{
+			Input: "/recipes?l=10&f=title+eq+'Spaghetti Bolognese'",
+			Opt: &ReadPageOptions{
+				Filter:     &ReadFiltersOptions{Key: "f", Expr: true},
+				Pagination: &ReadPaginationOptions{LimitKey: "l"},
+			},
+			Total: 0,
+			Output: Links{
+				First: "/recipes?f=title+eq+%22Spaghetti+Bolognese%22&l=10",
+				Last:  "/recipes?f=title+eq+%22Spaghetti+Bolognese%22&l=10",
+			},
+		},
+		{
+			Input: "/recipes?limit=10&serves[gte]=4&title__contains=pie&fields[author]=name",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{KeyStyles: []FilterKeyStyle{BracketKeyStyle, DjangoKeyStyle}}},
+			Total: 5,
+			Output: Links{
+				First: "/recipes?fields%5Bauthor%5D=name&filter=serves+gte+4&filter=title+like+%25pie%25&limit=10",
+				Last:  "/recipes?fields%5Bauthor%5D=name&filter=serves+gte+4&filter=title+like+%25pie%25&limit=10",
+			},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		u, _ := url.Parse(tc.Input)
+		page, err := ReadPage(u.Query(), tc.Opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		links, err := PageLinks(u, page, tc.Total, tc.Opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if links != tc.Output {
+			t.Errorf("Expected %+v, got %+v", tc.Output, links)
+		}
+	}
+}
+
+func TestCursorLinks(t *testing.T) {
+	u, _ := url.Parse("/recipes?limit=10&after=eyJ2Ijp7ImlkIjoiMTAifX0&sort=id+asc")
+	page, _ := ReadPage(u.Query(), nil)
+
+	next := &Cursor{Values: map[string]string{"id": "20"}}
+	prev := &Cursor{Values: map[string]string{"id": "11"}, Before: true}
+	links, err := CursorLinks(u, page, next, prev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Links{
+		First: "/recipes?limit=10&sort=id+asc",
+		Prev:  "/recipes?before=" + prev.Encode() + "&limit=10&sort=id+asc",
+		Next:  "/recipes?after=" + next.Encode() + "&limit=10&sort=id+asc",
+	}
+	if links != expected {
+		t.Errorf("Expected %+v, got %+v", expected, links)
+	}
+}
+
+func TestWriteLinkHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteLinkHeader(w, Links{First: "/recipes?limit=10", Next: "/recipes?limit=10&offset=10"})
+
+	expected := `</recipes?limit=10>; rel="first", </recipes?limit=10&offset=10>; rel="next"`
+	if header := w.Header().Get("Link"); header != expected {
+		t.Errorf("Expected %q, got %q", expected, header)
+	}
+
+	w = httptest.NewRecorder()
+	WriteLinkHeader(w, Links{})
+	if _, ok := w.Header()["Link"]; ok {
+		t.Error("Expected no Link header")
+	}
+}
@@ -0,0 +1,49 @@
+package qs
+
+import (
+	"context"
+	"net/http"
+)
+
+// ErrorWriter writes an error response for a request whose query string could not be read.
+type ErrorWriter func(w http.ResponseWriter, req *http.Request, err error)
+
+type pageContextKey struct{}
+
+// Middleware creates net/http middleware that reads a Page from each request's query string and stores it in the request context.
+// Handlers can retrieve the page using PageFromContext.
+//
+// If the query string cannot be read, the request is not passed to the next handler.
+// Instead, the error is written using writeError, or as a plain text 400 Bad Request response if writeError is nil.
+func Middleware(opt *ReadPageOptions, writeError ErrorWriter) func(http.Handler) http.Handler {
+	if writeError == nil {
+		writeError = writeBadRequest
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			page, err := ReadRequestPage(req, opt)
+			if err != nil {
+				writeError(w, req, err)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(NewContext(req.Context(), page)))
+		})
+	}
+}
+
+// NewContext returns a copy of ctx that carries a Page.
+func NewContext(ctx context.Context, page *Page) context.Context {
+	return context.WithValue(ctx, pageContextKey{}, page)
+}
+
+// PageFromContext returns the Page stored in a context by Middleware or NewContext.
+// If there is no page, it returns false.
+func PageFromContext(ctx context.Context) (*Page, bool) {
+	page, ok := ctx.Value(pageContextKey{}).(*Page)
+	return page, ok && page != nil
+}
+
+func writeBadRequest(w http.ResponseWriter, req *http.Request, err error) {
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
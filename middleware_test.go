@@ -0,0 +1,78 @@
+package qs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	type TestCase struct {
+		Input      string
+		Opt        *ReadPageOptions
+		WriteError ErrorWriter
+		Status     int
+		Output     *Page
+	}
+
+	teapot := func(w http.ResponseWriter, req *http.Request, err error) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	testCases := []TestCase{
+		{
+			Input:  "limit=10&filter=title eq Bolognese",
+			Status: http.StatusOK,
+			Output: &Page{
+				Pagination: &Pagination{Limit: 10},
+				Filters:    Filters{{Field: "title", Operator: "eq", Value: "Bolognese"}},
+			},
+		},
+		{
+			Input:  "limit=100",
+			Opt:    &ReadPageOptions{Pagination: &ReadPaginationOptions{MaxLimit: 50}},
+			Status: http.StatusOK,
+			Output: &Page{Pagination: &Pagination{Limit: 50}},
+		},
+		{Input: "limit=ten", Status: http.StatusBadRequest},
+		{Input: "limit=ten", WriteError: teapot, Status: http.StatusTeapot},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		var page *Page
+		handler := Middleware(tc.Opt, tc.WriteError)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var ok bool
+			if page, ok = PageFromContext(req.Context()); !ok {
+				t.Error("Expected page in context")
+			}
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/recipes", nil)
+		req.URL.RawQuery = tc.Input
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != tc.Status {
+			t.Errorf("Expected status %d, got %d", tc.Status, w.Code)
+		}
+		if !reflect.DeepEqual(page, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, page)
+		}
+	}
+}
+
+func TestPageFromContext(t *testing.T) {
+	if _, ok := PageFromContext(context.Background()); ok {
+		t.Error("Expected no page in empty context")
+	}
+
+	page := &Page{Pagination: &Pagination{Limit: 10}}
+	output, ok := PageFromContext(NewContext(context.Background(), page))
+	if !ok || output != page {
+		t.Errorf("Expected %+v, got %+v", page, output)
+	}
+}
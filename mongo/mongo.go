@@ -0,0 +1,265 @@
+// Package mongo compiles qs query objects into MongoDB query documents.
+//
+// Documents are built from standard library types only, so this package does not depend on a MongoDB driver.
+// Unordered documents are M, which any BSON encoder accepts as-is.
+// Ordered documents, such as sort specifications, are D. Each element converts directly to the driver's bson.E:
+//
+//	sort := bson.D{}
+//	for _, e := range query.Sort {
+//		sort = append(sort, bson.E(e))
+//	}
+package mongo
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// M is an unordered document.
+type M map[string]any
+
+// E is an element of an ordered document.
+type E struct {
+	Key   string
+	Value any
+}
+
+// D is an ordered document.
+type D []E
+
+// MarshalJSON encodes the document as a JSON object, preserving the order of elements.
+func (d D) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, e := range d {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Query is a compiled page, ready to pass to a collection's Find method and its options.
+type Query struct {
+	Filter     M     // Query filter. This is never nil.
+	Sort       D     // Sort specification. This is nil if there are no sorts.
+	Projection M     // Projection. This is nil if the page does not restrict the root entity's fields.
+	Skip       int64 // Number of documents to skip.
+	Limit      int64 // Maximum number of documents to return. This is 0 if there is no limit.
+}
+
+// Compiler compiles qs query objects into MongoDB documents.
+type Compiler struct {
+	Columns      map[string]string       // Maps field names to document keys. Fields that are not mapped are used as keys as-is.
+	Types        map[string]qs.FieldType // Maps field names to value types. Filter values are converted to these types; fields that are not mapped are compared as strings.
+	SearchFields []string                // Fields matched by search terms that do not specify a field. If this is empty, such terms are ignored.
+}
+
+// New creates a Compiler. If a schema is given, its columns and field types are used.
+func New(schema *qs.Schema) *Compiler {
+	c := &Compiler{}
+	if schema != nil {
+		c.Columns = schema.Columns()
+		c.Types = schema.Types()
+	}
+	return c
+}
+
+// Filter compiles a filter expression into a query filter document.
+// This function returns an empty document if the expression is nil.
+//
+// Like filters are compiled to anchored regular expressions, in which % matches any sequence of characters.
+func (c *Compiler) Filter(expr qs.FilterExpr) (M, error) {
+	if expr == nil {
+		return M{}, nil
+	}
+	return c.compileExpr(expr)
+}
+
+// Sort compiles sorts into a sort specification, in which 1 is ascending and -1 is descending.
+// This function returns nil if there are no sorts.
+func (c *Compiler) Sort(sorts qs.Sorts) (D, error) {
+	if len(sorts) == 0 {
+		return nil, nil
+	}
+	d := D{}
+	for _, sort := range sorts {
+		switch sort.Direction {
+		case "asc":
+			d = append(d, E{Key: c.column(sort.Field), Value: 1})
+		case "desc":
+			d = append(d, E{Key: c.column(sort.Field), Value: -1})
+		default:
+			return nil, qs.ErrInvalidSort
+		}
+	}
+	return d, nil
+}
+
+// Projection compiles the root entity's fields in a projection into a projection document.
+// This function returns nil if the projection does not restrict the root entity's fields.
+func (c *Compiler) Projection(fields qs.Fields) M {
+	names := fields.Get("")
+	if len(names) == 0 {
+		return nil
+	}
+	m := M{}
+	for _, name := range names {
+		m[c.column(name)] = 1
+	}
+	return m
+}
+
+// Page compiles a page into a query.
+//
+// If the page has a filter expression, it is used in preference to flat filters.
+// If the page has a search, it is added to the filter using like filters over SearchFields and any qualified fields (see qs.Search.Expr).
+//
+// If pagination has a cursor, a seek condition is added to the filter (see qs.Page.QueryExpr) and no documents are skipped.
+// For a cursor that reads before its position, the sort is reversed; the caller must reverse the resulting documents.
+func (c *Compiler) Page(page *qs.Page) (*Query, error) {
+	var search qs.FilterExpr
+	if page.Search != nil {
+		search = page.Search.Expr(c.SearchFields)
+	}
+	expr, sorts, err := page.QueryExpr(search)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{Projection: c.Projection(page.Fields)}
+	if pag := page.Pagination; pag != nil {
+		query.Limit = int64(pag.Limit)
+		if pag.Cursor == nil {
+			query.Skip = int64(pag.Offset)
+		}
+	}
+
+	if query.Filter, err = c.Filter(expr); err != nil {
+		return nil, err
+	}
+	if query.Sort, err = c.Sort(sorts); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+func (c *Compiler) column(field string) string {
+	if column, ok := c.Columns[field]; ok {
+		return column
+	}
+	return field
+}
+
+func (c *Compiler) compileExpr(expr qs.FilterExpr) (M, error) {
+	switch node := expr.(type) {
+	case qs.Filter:
+		return c.compileFilter(node)
+	case qs.And:
+		return c.compileJunction("$and", node)
+	case qs.Or:
+		return c.compileJunction("$or", node)
+	case qs.Not:
+		operand, err := c.compileExpr(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return M{"$nor": []M{operand}}, nil
+	}
+	return nil, qs.ErrUnsupportedExpr
+}
+
+func (c *Compiler) compileJunction(op string, operands []qs.FilterExpr) (M, error) {
+	docs := []M{}
+	for _, operand := range operands {
+		doc, err := c.compileExpr(operand)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 1 {
+		return docs[0], nil
+	}
+	if len(docs) == 0 && op == "$or" {
+		// An empty $or is not permitted, so match nothing instead
+		return M{"$expr": false}, nil
+	}
+	if len(docs) == 0 {
+		return M{}, nil
+	}
+	return M{op: docs}, nil
+}
+
+func (c *Compiler) compileFilter(filter qs.Filter) (M, error) {
+	column := c.column(filter.Field)
+
+	var cond any
+	switch filter.Operator {
+	case "eq", "neq", "gt", "gte", "lt", "lte":
+		value, err := c.Types[filter.Field].Parse(filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		cond = M{comparisonOperators[filter.Operator]: value}
+	case "in", "not in":
+		strs, _ := filter.StringSlice()
+		values := make([]any, len(strs))
+		for i, str := range strs {
+			value, err := c.Types[filter.Field].Parse(str)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		op := "$in"
+		if filter.Operator == "not in" {
+			op = "$nin"
+		}
+		cond = M{op: values}
+	case "like", "co", "sw", "ew":
+		cond = M{"$regex": likePattern(filter), "$options": "s"}
+	case "not like":
+		cond = M{"$not": M{"$regex": likePattern(filter), "$options": "s"}}
+	case "pr":
+		cond = M{"$ne": nil}
+	default:
+		return nil, qs.ErrUnsupportedOperator
+	}
+
+	return M{column: cond}, nil
+}
+
+var comparisonOperators = map[string]string{
+	"eq":  "$eq",
+	"neq": "$ne",
+	"gt":  "$gt",
+	"gte": "$gte",
+	"lt":  "$lt",
+	"lte": "$lte",
+}
+
+// likePattern converts a like pattern into an anchored regular expression.
+func likePattern(filter qs.Filter) string {
+	segments := filter.LikeSegments()
+	for i, segment := range segments {
+		segments[i] = regexp.QuoteMeta(segment)
+	}
+	return "^" + strings.Join(segments, ".*") + "$"
+}
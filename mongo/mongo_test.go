@@ -0,0 +1,177 @@
+package mongo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+var testSchema = &qs.Schema{
+	Fields: map[string]qs.Field{
+		"title":   {Filter: true, Sort: true, Select: true},
+		"serves":  {Type: qs.TypeInt, Filter: true, Sort: true, Select: true},
+		"rating":  {Type: qs.TypeFloat, Filter: true, Sort: true},
+		"vegan":   {Type: qs.TypeBool, Filter: true},
+		"created": {Filter: true, Sort: true, Select: true, Column: "createdAt"},
+	},
+}
+
+func TestCompilerPage(t *testing.T) {
+	type TestCase struct {
+		Input    string
+		Opt      *qs.ReadPageOptions
+		Compiler *Compiler
+		Query    *Query
+		Err      error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Compiler: New(nil), Query: &Query{Filter: M{}}},
+		{
+			Input:    "filter=title eq Bolognese&filter=serves gte 4",
+			Compiler: New(nil),
+			Query: &Query{Filter: M{"$and": []M{
+				{"title": M{"$eq": "Bolognese"}},
+				{"serves": M{"$gte": "4"}},
+			}}},
+		},
+		{
+			Input:    "filter=serves gte 4&filter=rating lt 4.5&filter=vegan neq false&filter=created gt 2024",
+			Compiler: New(testSchema),
+			Query: &Query{Filter: M{"$and": []M{
+				{"serves": M{"$gte": int64(4)}},
+				{"rating": M{"$lt": 4.5}},
+				{"vegan": M{"$ne": false}},
+				{"createdAt": M{"$gt": "2024"}},
+			}}},
+		},
+		{
+			Input:    "filter=serves in 2,4&filter=title not in Soup,Stew&sort=serves desc&sort=created asc",
+			Compiler: New(testSchema),
+			Query: &Query{
+				Filter: M{"$and": []M{
+					{"serves": M{"$in": []any{int64(2), int64(4)}}},
+					{"title": M{"$nin": []any{"Soup", "Stew"}}},
+				}},
+				Sort: D{{Key: "serves", Value: -1}, {Key: "createdAt", Value: 1}},
+			},
+		},
+		{
+			Input:    `filter=title like Spag%25 (v2.0)&filter=title not like %25100\%25%25`,
+			Compiler: New(nil),
+			Query: &Query{Filter: M{"$and": []M{
+				{"title": M{"$regex": `^Spag.* \(v2\.0\)$`, "$options": "s"}},
+				{"title": M{"$not": M{"$regex": `^.*100%.*$`, "$options": "s"}}},
+			}}},
+		},
+		{
+			Input:    "filter=title ew (v2.0)&filter=author pr",
+			Compiler: New(nil),
+			Query: &Query{Filter: M{"$and": []M{
+				{"title": M{"$regex": `^.*\(v2\.0\)$`, "$options": "s"}},
+				{"author": M{"$ne": nil}},
+			}}},
+		},
+		{
+			Input:    "filter=(title eq Soup or serves eq 3) and not serves lt 4",
+			Opt:      &qs.ReadPageOptions{Filter: &qs.ReadFiltersOptions{Expr: true}},
+			Compiler: New(testSchema),
+			Query: &Query{Filter: M{"$and": []M{
+				{"$or": []M{{"title": M{"$eq": "Soup"}}, {"serves": M{"$eq": int64(3)}}}},
+				{"$nor": []M{{"serves": M{"$lt": int64(4)}}}},
+			}}},
+		},
+		{
+			Input:    "filter=title eq Bolognese&limit=10&offset=20&fields=title,created",
+			Opt:      &qs.ReadPageOptions{Fields: &qs.ReadFieldsOptions{}},
+			Compiler: New(testSchema),
+			Query: &Query{
+				Filter:     M{"title": M{"$eq": "Bolognese"}},
+				Projection: M{"title": 1, "createdAt": 1},
+				Skip:       20,
+				Limit:      10,
+			},
+		},
+		{
+			Input:    "sort=serves desc&sort=id asc&limit=10&before=eyJ2Ijp7ImlkIjoiMTAiLCJzZXJ2ZXMiOiI0In19",
+			Compiler: New(nil),
+			Query: &Query{
+				Filter: M{"$or": []M{
+					{"serves": M{"$gt": "4"}},
+					{"$and": []M{{"serves": M{"$eq": "4"}}, {"id": M{"$lt": "10"}}}},
+				}},
+				Sort:  D{{Key: "serves", Value: 1}, {Key: "id", Value: -1}},
+				Limit: 10,
+			},
+		},
+		{Input: "q=pasta", Opt: &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}}, Compiler: New(nil), Query: &Query{Filter: M{}}},
+		{
+			Input:    "q=pasta -title:soup&filter=serves gte 4",
+			Opt:      &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}},
+			Compiler: &Compiler{SearchFields: []string{"title", "body"}},
+			Query: &Query{Filter: M{"$and": []M{
+				{"serves": M{"$gte": "4"}},
+				{"$or": []M{
+					{"title": M{"$regex": "^.*pasta.*$", "$options": "s"}},
+					{"body": M{"$regex": "^.*pasta.*$", "$options": "s"}},
+				}},
+				{"$nor": []M{{"title": M{"$regex": "^.*soup.*$", "$options": "s"}}}},
+			}}},
+		},
+		{
+			Input:    "filter=serves eq four",
+			Compiler: New(testSchema),
+			Err:      qs.ErrInvalidValue,
+		},
+		{
+			Input:    "sort=serves asc&after=eyJ2Ijp7fX0",
+			Compiler: New(nil),
+			Err:      qs.ErrInvalidCursor,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := qs.ReadStringPage(tc.Input, tc.Opt)
+		if err == nil {
+			var query *Query
+			query, err = tc.Compiler.Page(page)
+			if err == nil && !reflect.DeepEqual(query, tc.Query) {
+				t.Errorf("Expected %+v, got %+v", tc.Query, query)
+			}
+		}
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	c := New(nil)
+
+	if _, err := c.Filter(qs.Filter{Field: "title", Operator: "is", Value: "x"}); !errors.Is(err, qs.ErrUnsupportedOperator) {
+		t.Errorf("Expected error %v, got %v", qs.ErrUnsupportedOperator, err)
+	}
+
+	if _, err := c.Sort(qs.Sorts{{Field: "title", Direction: "up"}}); !errors.Is(err, qs.ErrInvalidSort) {
+		t.Errorf("Expected error %v, got %v", qs.ErrInvalidSort, err)
+	}
+}
+
+func TestDMarshalJSON(t *testing.T) {
+	d := D{{Key: "serves", Value: -1}, {Key: "title", Value: 1}, {Key: "author", Value: D{{Key: "name", Value: 1}}}}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"serves":-1,"title":1,"author":{"name":1}}`
+	if string(b) != expected {
+		t.Errorf("Expected %s, got %s", expected, string(b))
+	}
+}
@@ -0,0 +1,652 @@
+// Package odata reads OData query options into qs query objects, so that handlers can serve OData clients without depending on the query string format.
+//
+// The following system query options are supported:
+//
+//	$filter=Title eq 'Bolognese' and Serves ge 4    Read into Page.FilterExpr, and Page.Filters if it is a simple conjunction
+//	$orderby=Serves desc,Title                       Read into Page.Sorts
+//	$top=10&$skip=20                                 Read into Page.Pagination
+//	$expand=Author($expand=Profile),Ingredients      Read into Page.Joins
+//
+// Errors are reported as a *qs.ParseError wrapping the equivalent qs query error, such as qs.ErrInvalidFilter.
+package odata
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// Query string key.
+const (
+	FilterKey  = "$filter"
+	OrderByKey = "$orderby"
+	TopKey     = "$top"
+	SkipKey    = "$skip"
+	ExpandKey  = "$expand"
+)
+
+// operators maps OData comparison operators to qs filter operators.
+var operators = map[string]string{
+	"eq": "eq",
+	"ne": "neq",
+	"gt": "gt",
+	"ge": "gte",
+	"lt": "lt",
+	"le": "lte",
+}
+
+// ReadPageOptions configures the behaviour of ReadPage.
+type ReadPageOptions struct {
+	Properties map[string]string // Maps OData property names to field and join names. Properties that are not mapped are used as-is, and expanded properties must be valid join names
+	MaxTop     int               // If this is > 0, $top is clamped to this maximum value
+	MaxFilters int               // If this is > 0, a maximum number of comparisons is imposed
+	MaxSorts   int               // If this is > 0, a maximum number of sorts is imposed
+
+	Schema        *qs.Schema // If set, the page is validated against this schema.
+	CollectErrors bool       // If true, all errors are returned together as qs.Errors instead of only the first error.
+}
+
+// ReadPage parses URL values containing OData query options into a qs.Page.
+// Pagination is always set, even if neither $top nor $skip is provided.
+func ReadPage(values url.Values, opt *ReadPageOptions) (*qs.Page, error) {
+	if opt == nil {
+		opt = &ReadPageOptions{}
+	}
+
+	page := &qs.Page{Pagination: &qs.Pagination{}}
+	errs := qs.Errors{}
+	// Schema errors are only reported after errors in reading values
+	schemaErrs := qs.Errors{}
+
+	if values.Has(TopKey) {
+		top, err := qs.ReadNumber(values, TopKey, qs.ErrInvalidLimit)
+		if err != nil {
+			errs = append(errs, err)
+		} else if opt.MaxTop > 0 && top > opt.MaxTop {
+			top = opt.MaxTop
+		}
+		page.Pagination.Limit = top
+	}
+
+	if values.Has(SkipKey) {
+		skip, err := qs.ReadNumber(values, SkipKey, qs.ErrInvalidOffset)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		page.Pagination.Offset = skip
+	}
+
+	and := qs.And{}
+	count := 0
+	for i, s := range values[FilterKey] {
+		expr, err := parseFilter(s, opt.Properties)
+		if err != nil {
+			errs = append(errs, qs.WithKey(err, FilterKey, i))
+			continue
+		}
+		count += qs.CountFilters(expr)
+		if opt.MaxFilters > 0 && count > opt.MaxFilters {
+			errs = append(errs, qs.NewParseError(FilterKey, i, s, 0, qs.ReasonTooMany, qs.ErrTooManyFilters))
+			break
+		}
+		and = append(and, expr)
+		if opt.Schema != nil {
+			if err := opt.Schema.ValidateFilterExpr(expr); err != nil {
+				schemaErrs = append(schemaErrs, qs.NewParseError(FilterKey, i, s, 0, qs.SchemaReason(err), err))
+			}
+		}
+	}
+	if len(and) == 1 {
+		page.FilterExpr = and[0]
+	} else if len(and) > 1 {
+		page.FilterExpr = and
+	}
+	// Flat filters are only provided if the expression is a simple conjunction
+	page.Filters, _ = qs.FlattenFilters(page.FilterExpr)
+
+	for i, s := range values[OrderByKey] {
+		sorts, err := parseOrderBy(s, opt.Properties)
+		if err != nil {
+			errs = append(errs, qs.WithKey(err, OrderByKey, i))
+			continue
+		}
+		if opt.MaxSorts > 0 && len(page.Sorts)+len(sorts) > opt.MaxSorts {
+			errs = append(errs, qs.NewParseError(OrderByKey, i, s, 0, qs.ReasonTooMany, qs.ErrTooManySorts))
+			break
+		}
+		page.Sorts = append(page.Sorts, sorts...)
+		if opt.Schema != nil {
+			for _, sort := range sorts {
+				if err := opt.Schema.ValidateSort(sort); err != nil {
+					schemaErrs = append(schemaErrs, qs.NewParseError(OrderByKey, i, s, 0, qs.SchemaReason(err), err))
+					break
+				}
+			}
+		}
+	}
+
+	for i, s := range values[ExpandKey] {
+		joins, err := parseExpand(s, opt.Properties)
+		if err != nil {
+			errs = append(errs, qs.WithKey(err, ExpandKey, i))
+			continue
+		}
+		if page.Joins == nil {
+			page.Joins = qs.Joins{}
+		}
+		for path := range joins {
+			page.Joins.Add(path)
+		}
+		if opt.Schema != nil {
+			for _, path := range joins.Paths() {
+				if err := opt.Schema.ValidateJoin(path); err != nil {
+					schemaErrs = append(schemaErrs, qs.NewParseError(ExpandKey, i, s, 0, qs.SchemaReason(err), err))
+					break
+				}
+			}
+		}
+	}
+
+	if len(errs) > 0 && !opt.CollectErrors {
+		return nil, errs[0]
+	}
+	errs = append(errs, schemaErrs...)
+
+	if len(errs) == 0 {
+		return page, nil
+	}
+	if !opt.CollectErrors {
+		return nil, errs[0]
+	}
+	return nil, errs
+}
+
+// ReadRequestPage parses a request's query string containing OData query options into a qs.Page.
+func ReadRequestPage(req *http.Request, opt *ReadPageOptions) (*qs.Page, error) {
+	return ReadPage(req.URL.Query(), opt)
+}
+
+// ReadStringPage parses a query string literal containing OData query options into a qs.Page.
+func ReadStringPage(s string, opt *ReadPageOptions) (*qs.Page, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPage(values, opt)
+}
+
+// ParseFilter parses an OData $filter expression, such as:
+//
+//	(Status eq 'draft' or Author eq 3) and not contains(Title,'soup')
+//
+// Comparisons use the operators eq, ne, gt, ge, lt and le, which map to the qs operators eq, neq, gt, gte, lt and lte.
+// Membership is tested using in, such as Author in (1,2,3).
+// The string functions contains, startswith and endswith map to like filters.
+// Comparisons can be combined with and, or and not, and grouped with parentheses.
+//
+// Values may be strings in single quotes, with quotes escaped by doubling them, or numbers, dates or booleans.
+// Property paths, arithmetic and other functions are not supported.
+//
+// If the expression is invalid, this function returns a *qs.ParseError wrapping qs.ErrInvalidFilter.
+func ParseFilter(s string) (qs.FilterExpr, error) {
+	return parseFilter(s, nil)
+}
+
+// ParseOrderBy parses an OData $orderby expression, such as Serves desc,Title.
+// If a direction is not specified, the sort is ascending.
+//
+// If the expression is invalid, this function returns a *qs.ParseError wrapping qs.ErrInvalidSort.
+func ParseOrderBy(s string) (qs.Sorts, error) {
+	return parseOrderBy(s, nil)
+}
+
+// ParseExpand parses an OData $expand expression into joins.
+// Nested joins may be expressed either as property paths or using nested $expand options, so Author/Profile and Author($expand=Profile) are both read as the join author.profile.
+// Other nested options are not supported.
+//
+// If the expression is invalid, this function returns a *qs.ParseError wrapping qs.ErrInvalidJoin.
+func ParseExpand(s string) (qs.Joins, error) {
+	return parseExpand(s, nil)
+}
+
+func parseFilter(s string, properties map[string]string) (qs.FilterExpr, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{input: s, tokens: tokens, properties: properties}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(0); token != nil {
+		return nil, p.errorAt(token, qs.ReasonUnexpectedToken)
+	}
+	return expr, nil
+}
+
+func parseOrderBy(s string, properties map[string]string) (qs.Sorts, error) {
+	sorts := qs.Sorts{}
+	start := 0
+	for _, item := range strings.Split(s, ",") {
+		words := strings.Fields(item)
+		if len(words) == 0 || !isName(words[0]) {
+			offset := start + len(item) - len(strings.TrimLeft(item, " "))
+			return nil, qs.NewParseError("", 0, s, offset, qs.ReasonBadField, qs.ErrInvalidSort)
+		}
+
+		sort := qs.Sort{Field: property(properties, words[0]), Direction: "asc"}
+		if len(words) > 1 {
+			if len(words) > 2 || words[1] != "asc" && words[1] != "desc" {
+				end := strings.Index(item, words[0]) + len(words[0])
+				offset := start + end + strings.Index(item[end:], words[1])
+				return nil, qs.NewParseError("", 0, s, offset, qs.ReasonBadDirection, qs.ErrInvalidSort)
+			}
+			sort.Direction = words[1]
+		}
+		sorts = append(sorts, sort)
+		start += len(item) + 1
+	}
+	return sorts, nil
+}
+
+func parseExpand(s string, properties map[string]string) (qs.Joins, error) {
+	p := &expandParser{input: s, properties: properties}
+	joins := qs.Joins{}
+	if err := p.parseItems("", joins); err != nil {
+		return nil, err
+	}
+	if p.pos < len(s) {
+		return nil, qs.NewParseError("", 0, s, p.pos, qs.ReasonUnexpectedToken, qs.ErrInvalidJoin)
+	}
+	return joins, nil
+}
+
+type expandParser struct {
+	input      string
+	pos        int
+	properties map[string]string
+}
+
+// parseItems parses a comma-separated list of expanded properties, adding each to joins beneath a parent path.
+func (p *expandParser) parseItems(parent string, joins qs.Joins) error {
+	for {
+		path := parent
+		for {
+			n := scanName(p.input[p.pos:])
+			if n == 0 {
+				return qs.NewParseError("", 0, p.input, p.pos, qs.ReasonBadJoin, qs.ErrInvalidJoin)
+			}
+			name := property(p.properties, p.input[p.pos:p.pos+n])
+			if !qs.IsJoin(name) {
+				return qs.NewParseError("", 0, p.input, p.pos, qs.ReasonBadJoin, qs.ErrInvalidJoin)
+			}
+			if path != "" {
+				path += "."
+			}
+			path += name
+			p.pos += n
+			if !p.skip('/') {
+				break
+			}
+		}
+		joins.Add(path)
+
+		if open := p.pos; p.skip('(') {
+			for {
+				if !strings.HasPrefix(p.input[p.pos:], ExpandKey+"=") {
+					return qs.NewParseError("", 0, p.input, p.pos, qs.ReasonUnexpectedToken, qs.ErrInvalidJoin)
+				}
+				p.pos += len(ExpandKey) + 1
+				if err := p.parseItems(path, joins); err != nil {
+					return err
+				}
+				if !p.skip(';') {
+					break
+				}
+			}
+			if !p.skip(')') {
+				return qs.NewParseError("", 0, p.input, open, qs.ReasonUnclosedGroup, qs.ErrInvalidJoin)
+			}
+		}
+
+		if !p.skip(',') {
+			return nil
+		}
+	}
+}
+
+// skip advances past a character, if it is next in the input.
+func (p *expandParser) skip(c byte) bool {
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+type tokenType int
+
+const (
+	tokenWord tokenType = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+type token struct {
+	Type   tokenType
+	Value  string
+	Offset int
+}
+
+type parser struct {
+	input      string
+	tokens     []token
+	pos        int
+	properties map[string]string
+}
+
+// lex splits a filter expression into tokens.
+// This function returns an error if the expression is empty or contains an unterminated string.
+func lex(s string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{Type: tokenOpen, Value: "(", Offset: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{Type: tokenClose, Value: ")", Offset: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{Type: tokenComma, Value: ",", Offset: i})
+			i++
+		case c == '\'':
+			start := i
+			value := strings.Builder{}
+			closed := false
+			for i++; i < len(s); i++ {
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						value.WriteByte('\'')
+						i++
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, qs.NewParseError("", 0, s, start, qs.ReasonUnterminatedQuote, qs.ErrInvalidFilter)
+			}
+			tokens = append(tokens, token{Type: tokenString, Value: value.String(), Offset: start})
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r(),'", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{Type: tokenWord, Value: s[start:i], Offset: start})
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, qs.NewParseError("", 0, s, 0, qs.ReasonBadField, qs.ErrInvalidFilter)
+	}
+	return tokens, nil
+}
+
+// errorAt creates a ParseError at the offset of a token, or at the end of input if the token is nil.
+func (p *parser) errorAt(t *token, reason qs.Reason) error {
+	offset := len(p.input)
+	if t != nil {
+		offset = t.Offset
+	}
+	return qs.NewParseError("", 0, p.input, offset, reason, qs.ErrInvalidFilter)
+}
+
+func (p *parser) peek(n int) *token {
+	if p.pos+n < len(p.tokens) {
+		return &p.tokens[p.pos+n]
+	}
+	return nil
+}
+
+func (p *parser) peekType(n int, t tokenType) bool {
+	token := p.peek(n)
+	return token != nil && token.Type == t
+}
+
+func (p *parser) peekWord(n int, word string) bool {
+	token := p.peek(n)
+	return token != nil && token.Type == tokenWord && token.Value == word
+}
+
+func (p *parser) parseOr() (qs.FilterExpr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	or := qs.Or{expr}
+	for p.peekWord(0, "or") {
+		p.pos++
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (qs.FilterExpr, error) {
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	and := qs.And{expr}
+	for p.peekWord(0, "and") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseNot() (qs.FilterExpr, error) {
+	if p.peekWord(0, "not") {
+		p.pos++
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return qs.Not{Expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (qs.FilterExpr, error) {
+	t := p.peek(0)
+	if t == nil {
+		return nil, p.errorAt(nil, qs.ReasonBadField)
+	}
+
+	if t.Type == tokenOpen {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekType(0, tokenClose) {
+			return nil, p.errorAt(t, qs.ReasonUnclosedGroup)
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	if t.Type == tokenWord && p.peekType(1, tokenOpen) {
+		return p.parseFunction()
+	}
+
+	field, err := p.parseProperty()
+	if err != nil {
+		return nil, err
+	}
+
+	op := p.peek(0)
+	if op == nil || op.Type != tokenWord {
+		return nil, p.errorAt(op, qs.ReasonMissingOperator)
+	}
+	p.pos++
+
+	if op.Value == "in" {
+		return p.parseIn(field)
+	}
+
+	operator, ok := operators[op.Value]
+	if !ok {
+		return nil, p.errorAt(op, qs.ReasonUnknownOperator)
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return qs.Filter{Field: field, Operator: operator, Value: value}, nil
+}
+
+// parseFunction parses a call to contains, startswith or endswith into a like filter.
+func (p *parser) parseFunction() (qs.FilterExpr, error) {
+	fn := p.peek(0)
+	open := p.peek(1)
+	p.pos += 2
+
+	field, err := p.parseProperty()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekType(0, tokenComma) {
+		return nil, p.errorAt(p.peek(0), qs.ReasonUnexpectedToken)
+	}
+	p.pos++
+
+	t := p.peek(0)
+	if t == nil || t.Type != tokenString {
+		return nil, p.errorAt(t, qs.ReasonMissingValue)
+	}
+	p.pos++
+	if !p.peekType(0, tokenClose) {
+		return nil, p.errorAt(open, qs.ReasonUnclosedGroup)
+	}
+	p.pos++
+
+	value := qs.EscapeLike(t.Value)
+	switch fn.Value {
+	case "contains":
+		value = "%" + value + "%"
+	case "startswith":
+		value = value + "%"
+	case "endswith":
+		value = "%" + value
+	default:
+		return nil, p.errorAt(fn, qs.ReasonUnknownOperator)
+	}
+	return qs.Filter{Field: field, Operator: "like", Value: value}, nil
+}
+
+// parseIn parses a parenthesised list of values into an in filter.
+func (p *parser) parseIn(field string) (qs.FilterExpr, error) {
+	open := p.peek(0)
+	if open == nil || open.Type != tokenOpen {
+		return nil, p.errorAt(open, qs.ReasonMissingValue)
+	}
+	p.pos++
+
+	values := []string{}
+	for {
+		t := p.peek(0)
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(value, ",") {
+			// qs lists are comma-separated, so this value cannot be represented
+			return nil, p.errorAt(t, qs.ReasonInvalidValue)
+		}
+		values = append(values, value)
+		if !p.peekType(0, tokenComma) {
+			break
+		}
+		p.pos++
+	}
+
+	if !p.peekType(0, tokenClose) {
+		return nil, p.errorAt(open, qs.ReasonUnclosedGroup)
+	}
+	p.pos++
+	return qs.Filter{Field: field, Operator: "in", Value: strings.Join(values, ",")}, nil
+}
+
+func (p *parser) parseProperty() (string, error) {
+	t := p.peek(0)
+	if t == nil || t.Type != tokenWord || !isName(t.Value) {
+		return "", p.errorAt(t, qs.ReasonBadField)
+	}
+	p.pos++
+	return property(p.properties, t.Value), nil
+}
+
+// parseValue parses a literal value.
+// Strings are returned without quotes; other literals are returned as written.
+func (p *parser) parseValue() (string, error) {
+	t := p.peek(0)
+	if t == nil || t.Type != tokenString && t.Type != tokenWord {
+		return "", p.errorAt(t, qs.ReasonMissingValue)
+	}
+	if t.Type == tokenWord && t.Value != "true" && t.Value != "false" && !strings.ContainsAny(t.Value[:1], "0123456789-+.") {
+		return "", p.errorAt(t, qs.ReasonUnexpectedToken)
+	}
+	p.pos++
+	return t.Value, nil
+}
+
+func isName(s string) bool {
+	return s != "" && scanName(s) == len(s)
+}
+
+// property returns the field or join name for an OData property.
+func property(properties map[string]string, name string) string {
+	if mapped, ok := properties[name]; ok {
+		return mapped
+	}
+	return name
+}
+
+// scanName returns the length of the property name at the start of a string.
+func scanName(s string) int {
+	n := 0
+	for n < len(s) && (s[n] >= 'A' && s[n] <= 'Z' || s[n] >= 'a' && s[n] <= 'z' || s[n] == '_' || n > 0 && s[n] >= '0' && s[n] <= '9') {
+		n++
+	}
+	return n
+}
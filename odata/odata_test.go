@@ -0,0 +1,301 @@
+package odata
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestReadPage(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output *qs.Page
+		Err    error
+	}
+
+	properties := map[string]string{"Title": "title", "Serves": "serves", "Author": "author", "Profile": "profile", "Ingredients": "ingredient"}
+
+	testCases := []TestCase{
+		{Input: "", Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{
+			Input: "$filter=Title eq 'Bolognese' and Serves ge 4&$orderby=Serves desc,Title&$top=10&$skip=20",
+			Opt:   &ReadPageOptions{Properties: properties},
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{Limit: 10, Offset: 20},
+				Filters:    qs.Filters{{Field: "title", Operator: "eq", Value: "Bolognese"}, {Field: "serves", Operator: "gte", Value: "4"}},
+				FilterExpr: qs.And{qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}, qs.Filter{Field: "serves", Operator: "gte", Value: "4"}},
+				Sorts:      qs.Sorts{{Field: "serves", Direction: "desc"}, {Field: "title", Direction: "asc"}},
+			},
+		},
+		{
+			Input: "$filter=Title eq 'Soup' or Serves lt 2",
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				FilterExpr: qs.Or{qs.Filter{Field: "Title", Operator: "eq", Value: "Soup"}, qs.Filter{Field: "Serves", Operator: "lt", Value: "2"}},
+			},
+		},
+		{
+			Input: "$top=500&$expand=Author($expand=Profile),Ingredients",
+			Opt:   &ReadPageOptions{Properties: properties, MaxTop: 100},
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{Limit: 100},
+				Joins:      qs.Joins{"author": true, "author.profile": true, "ingredient": true},
+			},
+		},
+		{
+			Input: "$filter=Title eq 'Soup'&$filter=Serves gt 2&$filter=Serves lt 6",
+			Opt:   &ReadPageOptions{MaxFilters: 2},
+			Err:   qs.ErrTooManyFilters,
+		},
+		{
+			Input: "$orderby=Serves desc,Title asc",
+			Opt:   &ReadPageOptions{MaxSorts: 1},
+			Err:   qs.ErrTooManySorts,
+		},
+		{Input: "$top=ten", Err: qs.ErrInvalidLimit},
+		{Input: "$skip=-1", Err: qs.ErrInvalidOffset},
+		{Input: "$filter=Title eq Soup", Err: qs.ErrInvalidFilter},
+		{Input: "$orderby=Serves down", Err: qs.ErrInvalidSort},
+		{Input: "$expand=Author($select=Name)", Err: qs.ErrInvalidJoin},
+		{Input: "$expand=Author", Err: qs.ErrInvalidJoin},
+		{
+			Input: "$filter=title eq 'Soup'",
+			Opt:   &ReadPageOptions{Schema: &qs.Schema{Fields: map[string]qs.Field{"serves": {Filter: true}}}},
+			Err:   qs.ErrUnknownField,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := ReadStringPage(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if !reflect.DeepEqual(page, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, page)
+		}
+	}
+}
+
+func TestReadPageCollectErrors(t *testing.T) {
+	_, err := ReadStringPage("$top=ten&$filter=Title eq&$orderby=Serves down", &ReadPageOptions{CollectErrors: true})
+
+	errs := qs.Errors{}
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected qs.Errors, got %v", err)
+	}
+	keys := []string{}
+	for _, err := range errs {
+		var perr *qs.ParseError
+		if errors.As(err, &perr) {
+			keys = append(keys, perr.Key)
+		}
+	}
+	expected := []string{TopKey, FilterKey, OrderByKey}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected errors for %v, got %v", expected, keys)
+	}
+}
+
+func TestReadPageSchemaErrors(t *testing.T) {
+	schema := &qs.Schema{
+		Fields: map[string]qs.Field{
+			"title":  {Filter: true},
+			"serves": {Filter: true, Sort: true},
+		},
+		Joins: map[string]*qs.Schema{"author": {}},
+	}
+	opt := &ReadPageOptions{Schema: schema, CollectErrors: true}
+
+	_, err := ReadStringPage("$filter=title eq 'Soup'&$filter=rating gt 4&$orderby=serves,title&$expand=author,tags", opt)
+
+	errs := qs.Errors{}
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected qs.Errors, got %v", err)
+	}
+	type keyError struct {
+		Key    string
+		Index  int
+		Reason qs.Reason
+	}
+	got := []keyError{}
+	for _, err := range errs {
+		var perr *qs.ParseError
+		if errors.As(err, &perr) {
+			got = append(got, keyError{perr.Key, perr.Index, perr.Reason})
+		}
+	}
+	expected := []keyError{
+		{FilterKey, 1, qs.ReasonUnknownField},
+		{OrderByKey, 0, qs.ReasonSortNotAllowed},
+		{ExpandKey, 0, qs.ReasonUnknownJoin},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.FilterExpr
+		Err    error
+		Offset int
+		Reason qs.Reason
+	}
+
+	testCases := []TestCase{
+		{Input: "Title eq 'Bolognese'", Output: qs.Filter{Field: "Title", Operator: "eq", Value: "Bolognese"}},
+		{Input: "Title ne 'O''Brien''s pie'", Output: qs.Filter{Field: "Title", Operator: "neq", Value: "O'Brien's pie"}},
+		{Input: "Serves gt 4", Output: qs.Filter{Field: "Serves", Operator: "gt", Value: "4"}},
+		{Input: "Rating le -1.5", Output: qs.Filter{Field: "Rating", Operator: "lte", Value: "-1.5"}},
+		{Input: "Created lt 2024-01-01", Output: qs.Filter{Field: "Created", Operator: "lt", Value: "2024-01-01"}},
+		{Input: "Vegan eq true", Output: qs.Filter{Field: "Vegan", Operator: "eq", Value: "true"}},
+		{Input: "Author in (1, 2,3)", Output: qs.Filter{Field: "Author", Operator: "in", Value: "1,2,3"}},
+		{Input: "Status in ('draft','deleted')", Output: qs.Filter{Field: "Status", Operator: "in", Value: "draft,deleted"}},
+		{Input: "contains(Title,'50%')", Output: qs.Filter{Field: "Title", Operator: "like", Value: `%50\%%`}},
+		{Input: "startswith(Title, 'Spag')", Output: qs.Filter{Field: "Title", Operator: "like", Value: "Spag%"}},
+		{Input: "endswith(Title,'ese')", Output: qs.Filter{Field: "Title", Operator: "like", Value: "%ese"}},
+		{
+			Input: "(Status eq 'draft' or Author eq 3) and not contains(Title,'soup')",
+			Output: qs.And{
+				qs.Or{qs.Filter{Field: "Status", Operator: "eq", Value: "draft"}, qs.Filter{Field: "Author", Operator: "eq", Value: "3"}},
+				qs.Not{Expr: qs.Filter{Field: "Title", Operator: "like", Value: "%soup%"}},
+			},
+		},
+		{
+			Input: "A eq 1 or B eq 2 and C eq 3",
+			Output: qs.Or{
+				qs.Filter{Field: "A", Operator: "eq", Value: "1"},
+				qs.And{qs.Filter{Field: "B", Operator: "eq", Value: "2"}, qs.Filter{Field: "C", Operator: "eq", Value: "3"}},
+			},
+		},
+
+		{Input: "", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonBadField},
+		{Input: "Title eq 'Soup", Err: qs.ErrInvalidFilter, Offset: 9, Reason: qs.ReasonUnterminatedQuote},
+		{Input: "Title", Err: qs.ErrInvalidFilter, Offset: 5, Reason: qs.ReasonMissingOperator},
+		{Input: "Title is 'Soup'", Err: qs.ErrInvalidFilter, Offset: 6, Reason: qs.ReasonUnknownOperator},
+		{Input: "Title eq", Err: qs.ErrInvalidFilter, Offset: 8, Reason: qs.ReasonMissingValue},
+		{Input: "Title eq Soup", Err: qs.ErrInvalidFilter, Offset: 9, Reason: qs.ReasonUnexpectedToken},
+		{Input: "Title eq null", Err: qs.ErrInvalidFilter, Offset: 9, Reason: qs.ReasonUnexpectedToken},
+		{Input: "Author/Name eq 'Anny'", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonBadField},
+		{Input: "(Serves gt 4", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonUnclosedGroup},
+		{Input: "Serves gt 4)", Err: qs.ErrInvalidFilter, Offset: 11, Reason: qs.ReasonUnexpectedToken},
+		{Input: "tolower(Title,'x')", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonUnknownOperator},
+		{Input: "contains(Title,4)", Err: qs.ErrInvalidFilter, Offset: 15, Reason: qs.ReasonMissingValue},
+		{Input: "Status in ('a,b')", Err: qs.ErrInvalidFilter, Offset: 11, Reason: qs.ReasonInvalidValue},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		expr, err := ParseFilter(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			var perr *qs.ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("Expected *qs.ParseError, got %T", err)
+			} else if perr.Offset != tc.Offset || perr.Reason != tc.Reason {
+				t.Errorf("Expected %s at offset %d, got %s at offset %d", tc.Reason, tc.Offset, perr.Reason, perr.Offset)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.Sorts
+		Offset int
+		Reason qs.Reason
+	}
+
+	testCases := []TestCase{
+		{Input: "Serves desc,Title", Output: qs.Sorts{{Field: "Serves", Direction: "desc"}, {Field: "Title", Direction: "asc"}}},
+		{Input: "Serves desc, Title asc", Output: qs.Sorts{{Field: "Serves", Direction: "desc"}, {Field: "Title", Direction: "asc"}}},
+		{Input: "", Offset: 0, Reason: qs.ReasonBadField},
+		{Input: "Serves desc,", Offset: 12, Reason: qs.ReasonBadField},
+		{Input: "Title, Serves down", Offset: 14, Reason: qs.ReasonBadDirection},
+		{Input: "Serves desc nulls", Offset: 7, Reason: qs.ReasonBadDirection},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		sorts, err := ParseOrderBy(tc.Input)
+		if tc.Reason != "" {
+			var perr *qs.ParseError
+			if !errors.As(err, &perr) || !errors.Is(err, qs.ErrInvalidSort) {
+				t.Errorf("Expected *qs.ParseError wrapping %v, got %v", qs.ErrInvalidSort, err)
+			} else if perr.Offset != tc.Offset || perr.Reason != tc.Reason {
+				t.Errorf("Expected %s at offset %d, got %s at offset %d", tc.Reason, tc.Offset, perr.Reason, perr.Offset)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(sorts, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, sorts)
+		}
+	}
+}
+
+func TestParseExpand(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.Joins
+		Offset int
+		Reason qs.Reason
+	}
+
+	testCases := []TestCase{
+		{Input: "author", Output: qs.Joins{"author": true}},
+		{Input: "author/profile,tags", Output: qs.Joins{"author": true, "author.profile": true, "tags": true}},
+		{Input: "author($expand=profile($expand=avatar);$expand=books)", Output: qs.Joins{"author": true, "author.profile": true, "author.profile.avatar": true, "author.books": true}},
+		{Input: "", Offset: 0, Reason: qs.ReasonBadJoin},
+		{Input: "author,", Offset: 7, Reason: qs.ReasonBadJoin},
+		{Input: "Author", Offset: 0, Reason: qs.ReasonBadJoin},
+		{Input: "author/Profile", Offset: 7, Reason: qs.ReasonBadJoin},
+		{Input: "author($expand=profile", Offset: 6, Reason: qs.ReasonUnclosedGroup},
+		{Input: "author($top=1)", Offset: 7, Reason: qs.ReasonUnexpectedToken},
+		{Input: "author profile", Offset: 6, Reason: qs.ReasonUnexpectedToken},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		joins, err := ParseExpand(tc.Input)
+		if tc.Reason != "" {
+			var perr *qs.ParseError
+			if !errors.As(err, &perr) || !errors.Is(err, qs.ErrInvalidJoin) {
+				t.Errorf("Expected *qs.ParseError wrapping %v, got %v", qs.ErrInvalidJoin, err)
+			} else if perr.Offset != tc.Offset || perr.Reason != tc.Reason {
+				t.Errorf("Expected %s at offset %d, got %s at offset %d", tc.Reason, tc.Offset, perr.Reason, perr.Offset)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if !reflect.DeepEqual(joins, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, joins)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+// Package opensearch compiles qs query objects into OpenSearch query DSL.
+// The generated DSL is also compatible with Elasticsearch.
+//
+// Queries are built from standard library types only, so this package does not depend on a client library.
+// Encode them using encoding/json to produce a search request body.
+package opensearch
+
+import (
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// M is a JSON object in the query DSL.
+type M map[string]any
+
+// Compiler compiles qs query objects into OpenSearch query DSL.
+//
+// Filters and sorts match exact values, so they are compiled to term-level queries.
+// Fields that are mapped as analysed text cannot be matched exactly; list these in Text so that their keyword sub-field is used instead.
+// New lists schema fields of type qs.TypeText in Text.
+// Search terms are compiled to full-text queries, which always use the field itself.
+type Compiler struct {
+	Columns       map[string]string       // Maps field names to document fields. Fields that are not mapped are used as document fields as-is.
+	Types         map[string]qs.FieldType // Maps field names to value types. Filter values are converted to these types; fields that are not mapped are compared as strings.
+	Text          map[string]bool         // Fields mapped as text, with a keyword sub-field.
+	KeywordSuffix string                  // Suffix of the keyword sub-field of text fields. The default value is ".keyword"
+
+	SearchFields []string // Fields matched by search terms that do not specify a field. If this is empty, such terms are ignored.
+}
+
+// New creates a Compiler. If a schema is given, its columns, field types and text fields are used.
+func New(schema *qs.Schema) *Compiler {
+	c := &Compiler{}
+	if schema != nil {
+		c.Columns = schema.Columns()
+		c.Types = schema.Types()
+		c.Text = map[string]bool{}
+		for name, field := range schema.Fields {
+			if field.Type == qs.TypeText {
+				c.Text[name] = true
+			}
+		}
+	}
+	return c
+}
+
+// Filter compiles a filter expression into a query.
+// This function returns a match_all query if the expression is nil.
+//
+// Conditions are placed in a bool query's filter context, so they do not affect scoring.
+// Negated conditions, such as neq, not in and not like, are placed in must_not.
+// Like filters are compiled to wildcard queries, in which % matches any sequence of characters.
+func (c *Compiler) Filter(expr qs.FilterExpr) (M, error) {
+	if expr == nil {
+		return M{"match_all": M{}}, nil
+	}
+	return c.compileExpr(expr)
+}
+
+// Search compiles a search into a query.
+// This function returns nil if there is nothing to match.
+//
+// Terms that do not specify a field are compiled to multi_match queries over SearchFields.
+// Terms that specify a field are compiled to match queries. Phrases are matched as a whole.
+func (c *Compiler) Search(search *qs.Search) M {
+	must := []M{}
+	mustNot := []M{}
+	for _, term := range search.Terms {
+		var query M
+		if term.Field != "" {
+			kind := "match"
+			if term.Phrase {
+				kind = "match_phrase"
+			}
+			query = M{kind: M{c.column(term.Field): M{"query": term.Value}}}
+		} else if len(c.SearchFields) > 0 {
+			fields := make([]string, len(c.SearchFields))
+			for i, field := range c.SearchFields {
+				fields[i] = c.column(field)
+			}
+			query = M{"multi_match": M{"query": term.Value, "fields": fields}}
+			if term.Phrase {
+				query["multi_match"].(M)["type"] = "phrase"
+			}
+		} else {
+			continue
+		}
+
+		if term.Exclude {
+			mustNot = append(mustNot, query)
+		} else {
+			must = append(must, query)
+		}
+	}
+
+	if len(must) == 0 && len(mustNot) == 0 {
+		return nil
+	}
+	b := M{}
+	if len(must) > 0 {
+		b["must"] = must
+	}
+	if len(mustNot) > 0 {
+		b["must_not"] = mustNot
+	}
+	return M{"bool": b}
+}
+
+// Sort compiles sorts into a sort specification.
+// This function returns nil if there are no sorts.
+func (c *Compiler) Sort(sorts qs.Sorts) ([]M, error) {
+	if len(sorts) == 0 {
+		return nil, nil
+	}
+	spec := []M{}
+	for _, sort := range sorts {
+		if sort.Direction != "asc" && sort.Direction != "desc" {
+			return nil, qs.ErrInvalidSort
+		}
+		spec = append(spec, M{c.keyword(sort.Field): M{"order": sort.Direction}})
+	}
+	return spec, nil
+}
+
+// Source compiles the root entity's fields in a projection into a list of source fields.
+// This function returns nil if the projection does not restrict the root entity's fields.
+func (c *Compiler) Source(fields qs.Fields) []string {
+	names := fields.Get("")
+	if len(names) == 0 {
+		return nil
+	}
+	source := make([]string, len(names))
+	for i, name := range names {
+		source[i] = c.column(name)
+	}
+	return source
+}
+
+// Page compiles a page into a search request body.
+// Properties that are not required are omitted, so this function may return an empty body.
+//
+// If the page has a filter expression, it is used in preference to flat filters.
+// Filters and the page's search are combined in a single bool query (see Compiler.Filter and Compiler.Search).
+//
+// If pagination has a cursor, a seek condition is added to the filter (see qs.Page.QueryExpr) and no documents are skipped.
+// For a cursor that reads before its position, the sort is reversed; the caller must reverse the resulting documents.
+func (c *Compiler) Page(page *qs.Page) (M, error) {
+	expr, sorts, err := page.QueryExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	body := M{}
+	if pag := page.Pagination; pag != nil {
+		if pag.Limit > 0 {
+			body["size"] = pag.Limit
+		}
+		if pag.Cursor == nil && pag.Offset > 0 {
+			body["from"] = pag.Offset
+		}
+	}
+
+	and, ok := expr.(qs.And)
+	if !ok && expr != nil {
+		and = qs.And{expr}
+	}
+
+	b := M{}
+	if len(and) > 0 {
+		filter, mustNot, err := c.compileAnd(and)
+		if err != nil {
+			return nil, err
+		}
+		if len(filter) > 0 {
+			b["filter"] = filter
+		}
+		if len(mustNot) > 0 {
+			b["must_not"] = mustNot
+		}
+	}
+	if page.Search != nil {
+		if search := c.Search(page.Search); search != nil {
+			for key, clauses := range search["bool"].(M) {
+				existing, _ := b[key].([]M)
+				b[key] = append(existing, clauses.([]M)...)
+			}
+		}
+	}
+	if len(b) > 0 {
+		body["query"] = M{"bool": b}
+	}
+
+	sort, err := c.Sort(sorts)
+	if err != nil {
+		return nil, err
+	}
+	if sort != nil {
+		body["sort"] = sort
+	}
+
+	if source := c.Source(page.Fields); source != nil {
+		body["_source"] = source
+	}
+
+	return body, nil
+}
+
+func (c *Compiler) column(field string) string {
+	if column, ok := c.Columns[field]; ok {
+		return column
+	}
+	return field
+}
+
+// keyword returns the document field used to match a field's exact values.
+func (c *Compiler) keyword(field string) string {
+	if !c.Text[field] {
+		return c.column(field)
+	}
+	if c.KeywordSuffix != "" {
+		return c.column(field) + c.KeywordSuffix
+	}
+	return c.column(field) + ".keyword"
+}
+
+func (c *Compiler) compileExpr(expr qs.FilterExpr) (M, error) {
+	switch node := expr.(type) {
+	case qs.Filter:
+		query, negated, err := c.compileFilter(node)
+		if err != nil {
+			return nil, err
+		}
+		if negated {
+			return M{"bool": M{"must_not": []M{query}}}, nil
+		}
+		return query, nil
+	case qs.And:
+		if len(node) == 1 {
+			return c.compileExpr(node[0])
+		}
+		filter, mustNot, err := c.compileAnd(node)
+		if err != nil {
+			return nil, err
+		}
+		b := M{}
+		if len(filter) > 0 {
+			b["filter"] = filter
+		}
+		if len(mustNot) > 0 {
+			b["must_not"] = mustNot
+		}
+		if len(b) == 0 {
+			return M{"match_all": M{}}, nil
+		}
+		return M{"bool": b}, nil
+	case qs.Or:
+		if len(node) == 1 {
+			return c.compileExpr(node[0])
+		}
+		if len(node) == 0 {
+			return M{"match_none": M{}}, nil
+		}
+		should := []M{}
+		for _, operand := range node {
+			query, err := c.compileExpr(operand)
+			if err != nil {
+				return nil, err
+			}
+			should = append(should, query)
+		}
+		return M{"bool": M{"should": should, "minimum_should_match": 1}}, nil
+	case qs.Not:
+		query, err := c.compileExpr(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return M{"bool": M{"must_not": []M{query}}}, nil
+	}
+	return nil, qs.ErrUnsupportedExpr
+}
+
+// compileAnd compiles the operands of an And into the filter and must_not clauses of a bool query.
+func (c *Compiler) compileAnd(operands qs.And) ([]M, []M, error) {
+	filter := []M{}
+	mustNot := []M{}
+	for _, operand := range operands {
+		switch node := operand.(type) {
+		case qs.Filter:
+			query, negated, err := c.compileFilter(node)
+			if err != nil {
+				return nil, nil, err
+			}
+			if negated {
+				mustNot = append(mustNot, query)
+			} else {
+				filter = append(filter, query)
+			}
+		case qs.Not:
+			query, err := c.compileExpr(node.Expr)
+			if err != nil {
+				return nil, nil, err
+			}
+			mustNot = append(mustNot, query)
+		default:
+			query, err := c.compileExpr(node)
+			if err != nil {
+				return nil, nil, err
+			}
+			filter = append(filter, query)
+		}
+	}
+	return filter, mustNot, nil
+}
+
+// compileFilter compiles a filter into a term-level query.
+// If the filter is negated, the query matches its opposite and must be placed in must_not.
+func (c *Compiler) compileFilter(filter qs.Filter) (M, bool, error) {
+	field := c.keyword(filter.Field)
+
+	switch filter.Operator {
+	case "eq", "neq":
+		value, err := c.Types[filter.Field].Parse(filter.Value)
+		if err != nil {
+			return nil, false, err
+		}
+		return M{"term": M{field: value}}, filter.Operator == "neq", nil
+	case "gt", "gte", "lt", "lte":
+		value, err := c.Types[filter.Field].Parse(filter.Value)
+		if err != nil {
+			return nil, false, err
+		}
+		return M{"range": M{field: M{filter.Operator: value}}}, false, nil
+	case "in", "not in":
+		strs, _ := filter.StringSlice()
+		values := make([]any, len(strs))
+		for i, str := range strs {
+			value, err := c.Types[filter.Field].Parse(str)
+			if err != nil {
+				return nil, false, err
+			}
+			values[i] = value
+		}
+		return M{"terms": M{field: values}}, filter.Operator == "not in", nil
+	case "like", "not like", "co", "sw", "ew":
+		return M{"wildcard": M{field: M{"value": wildcardPattern(filter)}}}, filter.Operator == "not like", nil
+	case "pr":
+		return M{"exists": M{"field": field}}, false, nil
+	}
+	return nil, false, qs.ErrUnsupportedOperator
+}
+
+var wildcardEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`)
+
+// wildcardPattern converts a qs like pattern into a wildcard query pattern.
+func wildcardPattern(filter qs.Filter) string {
+	segments := filter.LikeSegments()
+	for i, segment := range segments {
+		segments[i] = wildcardEscaper.Replace(segment)
+	}
+	return strings.Join(segments, "*")
+}
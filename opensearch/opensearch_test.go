@@ -0,0 +1,133 @@
+package opensearch
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+var testSchema = &qs.Schema{
+	Fields: map[string]qs.Field{
+		"title":   {Type: qs.TypeText, Filter: true, Sort: true, Select: true},
+		"serves":  {Type: qs.TypeInt, Filter: true, Sort: true, Select: true},
+		"rating":  {Type: qs.TypeFloat, Filter: true, Sort: true},
+		"vegan":   {Type: qs.TypeBool, Filter: true},
+		"created": {Filter: true, Sort: true, Select: true, Column: "created_at"},
+	},
+}
+
+func TestCompilerPage(t *testing.T) {
+	type TestCase struct {
+		Input    string
+		Opt      *qs.ReadPageOptions
+		Compiler *Compiler
+		JSON     string
+		Err      error
+	}
+
+	text := New(testSchema)
+	text.SearchFields = []string{"title", "body"}
+
+	testCases := []TestCase{
+		{Input: "", Compiler: New(nil), JSON: `{}`},
+		{
+			Input:    "filter=title eq Bolognese&filter=serves gte 4",
+			Compiler: New(nil),
+			JSON:     `{"query":{"bool":{"filter":[{"term":{"title":"Bolognese"}},{"range":{"serves":{"gte":"4"}}}]}}}`,
+		},
+		{
+			Input:    "filter=serves gte 4&filter=rating lt 4.5&filter=vegan neq false&filter=created gt 2024",
+			Compiler: New(testSchema),
+			JSON:     `{"query":{"bool":{"filter":[{"range":{"serves":{"gte":4}}},{"range":{"rating":{"lt":4.5}}},{"range":{"created_at":{"gt":"2024"}}}],"must_not":[{"term":{"vegan":false}}]}}}`,
+		},
+		{
+			Input:    "filter=serves in 2,4&filter=title not in Soup,Stew&sort=serves desc&sort=title asc",
+			Compiler: text,
+			JSON:     `{"query":{"bool":{"filter":[{"terms":{"serves":[2,4]}}],"must_not":[{"terms":{"title.keyword":["Soup","Stew"]}}]}},"sort":[{"serves":{"order":"desc"}},{"title.keyword":{"order":"asc"}}]}`,
+		},
+		{
+			Input:    `filter=title like Spag%25?*&filter=title not like %25100\%25%25`,
+			Compiler: &Compiler{Text: map[string]bool{"title": true}, KeywordSuffix: ".raw"},
+			JSON:     `{"query":{"bool":{"filter":[{"wildcard":{"title.raw":{"value":"Spag*\\?\\*"}}}],"must_not":[{"wildcard":{"title.raw":{"value":"*100%*"}}}]}}}`,
+		},
+		{
+			Input:    "filter=title co a*b&filter=author pr",
+			Compiler: New(nil),
+			JSON:     `{"query":{"bool":{"filter":[{"wildcard":{"title":{"value":"*a\\*b*"}}},{"exists":{"field":"author"}}]}}}`,
+		},
+		{
+			Input:    "filter=title neq Soup",
+			Compiler: New(nil),
+			JSON:     `{"query":{"bool":{"must_not":[{"term":{"title":"Soup"}}]}}}`,
+		},
+		{
+			Input:    "filter=(title eq Soup or serves eq 3) and not serves lt 4",
+			Opt:      &qs.ReadPageOptions{Filter: &qs.ReadFiltersOptions{Expr: true}},
+			Compiler: New(testSchema),
+			JSON:     `{"query":{"bool":{"filter":[{"bool":{"minimum_should_match":1,"should":[{"term":{"title.keyword":"Soup"}},{"term":{"serves":3}}]}}],"must_not":[{"range":{"serves":{"lt":4}}}]}}}`,
+		},
+		{
+			Input:    "filter=title eq Soup or title neq Stew",
+			Opt:      &qs.ReadPageOptions{Filter: &qs.ReadFiltersOptions{Expr: true}},
+			Compiler: New(nil),
+			JSON:     `{"query":{"bool":{"filter":[{"bool":{"minimum_should_match":1,"should":[{"term":{"title":"Soup"}},{"bool":{"must_not":[{"term":{"title":"Stew"}}]}}]}}]}}}`,
+		},
+		{
+			Input:    "filter=title eq Bolognese&limit=10&offset=20&fields=title,created",
+			Opt:      &qs.ReadPageOptions{Fields: &qs.ReadFieldsOptions{}},
+			Compiler: New(testSchema),
+			JSON:     `{"_source":["title","created_at"],"from":20,"query":{"bool":{"filter":[{"term":{"title.keyword":"Bolognese"}}]}},"size":10}`,
+		},
+		{
+			Input:    "sort=serves desc&sort=id asc&limit=10&before=eyJ2Ijp7ImlkIjoiMTAiLCJzZXJ2ZXMiOiI0In19",
+			Compiler: New(testSchema),
+			JSON:     `{"query":{"bool":{"filter":[{"bool":{"minimum_should_match":1,"should":[{"range":{"serves":{"gt":4}}},{"bool":{"filter":[{"term":{"serves":4}},{"range":{"id":{"lt":"10"}}}]}}]}}]}},"size":10,"sort":[{"serves":{"order":"asc"}},{"id":{"order":"desc"}}]}`,
+		},
+		{Input: "q=pasta", Opt: &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}}, Compiler: New(nil), JSON: `{}`},
+		{
+			Input:    `q=pasta -"tomato soup" title:"ragu alla" -body:mushroom&filter=serves gte 4`,
+			Opt:      &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}},
+			Compiler: text,
+			JSON:     `{"query":{"bool":{"filter":[{"range":{"serves":{"gte":4}}}],"must":[{"multi_match":{"fields":["title","body"],"query":"pasta"}},{"match_phrase":{"title":{"query":"ragu alla"}}}],"must_not":[{"multi_match":{"fields":["title","body"],"query":"tomato soup","type":"phrase"}},{"match":{"body":{"query":"mushroom"}}}]}}}`,
+		},
+		{
+			Input:    "filter=serves eq four",
+			Compiler: New(testSchema),
+			Err:      qs.ErrInvalidValue,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := qs.ReadStringPage(tc.Input, tc.Opt)
+		if err == nil {
+			var body M
+			body, err = tc.Compiler.Page(page)
+			if err == nil {
+				b, _ := json.Marshal(body)
+				if string(b) != tc.JSON {
+					t.Errorf("Expected %s, got %s", tc.JSON, string(b))
+				}
+			}
+		}
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	c := New(nil)
+
+	if _, err := c.Filter(qs.Filter{Field: "title", Operator: "is", Value: "x"}); !errors.Is(err, qs.ErrUnsupportedOperator) {
+		t.Errorf("Expected error %v, got %v", qs.ErrUnsupportedOperator, err)
+	}
+
+	if _, err := c.Sort(qs.Sorts{{Field: "title", Direction: "up"}}); !errors.Is(err, qs.ErrInvalidSort) {
+		t.Errorf("Expected error %v, got %v", qs.ErrInvalidSort, err)
+	}
+}
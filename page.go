@@ -3,14 +3,167 @@ package qs
 import (
 	"net/http"
 	"net/url"
+	"sort"
 )
 
 // Page represents a combination of pagination, filter and sort parameters for, most likely, a database query.
 type Page struct {
-	Pagination *Pagination `json:"pagination"`
-	Filters    Filters     `json:"filters,omitempty"`
-	Sorts      Sorts       `json:"sorts,omitempty"`
-	Joins      Joins       `json:"joins,omitempty"`
+	Pagination *Pagination      `json:"pagination"`
+	Filters    Filters          `json:"filters,omitempty"`
+	FilterExpr FilterExpr       `json:"filterExpr,omitempty"` // Only set if filters are read as expressions.
+	Sorts      Sorts            `json:"sorts,omitempty"`
+	Joins      Joins            `json:"joins,omitempty"`
+	JoinPages  map[string]*Page `json:"joinPages,omitempty"` // Pagination, filters and sorts scoped to a join, keyed by join path.
+	Fields     Fields           `json:"fields,omitempty"`
+	Search     *Search          `json:"search,omitempty"`
+}
+
+// Encode returns the page as a canonical query string, using the same options as ReadPage.
+// Equivalent pages produce identical query strings, which is useful for cache keys and ETags:
+// keys are sorted, filters and joins are sorted, page numbers are converted to offsets, and filter expressions are normalised.
+// The order of sorts is preserved, since it is significant.
+//
+// Cursors are always written unsigned, even if a CursorSigner is configured, so that the query string does not change as signatures expire or keys are rotated.
+// The result should therefore be used as a key rather than given to clients as a link.
+func (page *Page) Encode(opt *ReadPageOptions) (string, error) {
+	if opt != nil && opt.Pagination != nil && opt.Pagination.CursorSigner != nil {
+		pagOpt := *opt.Pagination
+		pagOpt.CursorSigner = nil
+		canonicalOpt := *opt
+		canonicalOpt.Pagination = &pagOpt
+		opt = &canonicalOpt
+	}
+
+	values, err := page.canonical().Values(opt)
+	if err != nil {
+		return "", err
+	}
+	return values.Encode(), nil
+}
+
+// canonical returns an equivalent page in canonical form, as described for Encode.
+func (page *Page) canonical() *Page {
+	canonical := &Page{
+		Sorts:  page.Sorts,
+		Joins:  page.Joins,
+		Search: page.Search,
+	}
+
+	if len(page.Fields) > 0 {
+		canonical.Fields = Fields{}
+		for path, names := range page.Fields {
+			canonical.Fields[path] = append([]string{}, names...)
+			sort.Strings(canonical.Fields[path])
+		}
+	}
+
+	if page.Pagination != nil {
+		canonical.Pagination = &Pagination{
+			Limit:  page.Pagination.Limit,
+			Offset: page.Pagination.Offset,
+			Cursor: page.Pagination.Cursor,
+		}
+	}
+
+	if page.FilterExpr != nil {
+		canonical.FilterExpr = canonicalFilterExpr(page.FilterExpr)
+	} else if len(page.Filters) > 0 {
+		canonical.Filters = append(Filters{}, page.Filters...)
+		sort.SliceStable(canonical.Filters, func(i, j int) bool {
+			return canonical.Filters[i].String() < canonical.Filters[j].String()
+		})
+	}
+
+	if len(page.JoinPages) > 0 {
+		canonical.JoinPages = map[string]*Page{}
+		for path, joinPage := range page.JoinPages {
+			canonical.JoinPages[path] = joinPage.canonical()
+		}
+	}
+
+	return canonical
+}
+
+// QueryExpr returns the filter expression and sorts with which to query the page, for use by query backends.
+//
+// The filter expression is used in preference to flat filters, and is combined with any extra expressions, such as a search, and the cursor's seek condition (see Cursor.Seek).
+// Nested conjunctions are flattened, and nil expressions are ignored. The result is nil if there is nothing to filter.
+// For a cursor that reads before its position, the sorts are reversed; the caller must reverse the results.
+func (page *Page) QueryExpr(extra ...FilterExpr) (FilterExpr, Sorts, error) {
+	operands := []FilterExpr{page.FilterExpr}
+	if page.FilterExpr == nil {
+		operands[0] = page.Filters.Expr()
+	}
+	operands = append(operands, extra...)
+
+	sorts := page.Sorts
+	if page.Pagination != nil && page.Pagination.Cursor != nil {
+		seek, err := page.Pagination.Cursor.Seek(sorts)
+		if err != nil {
+			return nil, nil, err
+		}
+		operands = append(operands, seek)
+		if page.Pagination.Cursor.Before {
+			sorts = sorts.Reverse()
+		}
+	}
+
+	and := And{}
+	for _, operand := range operands {
+		switch node := operand.(type) {
+		case nil:
+		case And:
+			and = append(and, node...)
+		default:
+			and = append(and, node)
+		}
+	}
+
+	switch len(and) {
+	case 0:
+		return nil, sorts, nil
+	case 1:
+		return and[0], sorts, nil
+	}
+	return and, sorts, nil
+}
+
+// Values returns the page as URL values, using the same options as ReadPage.
+// Pages scoped to joins are written using keys prefixed with the join path.
+// If the page has a filter expression, it is written as a single filter value in preference to flat filters.
+// It can only be read back if filters are read as expressions.
+func (page *Page) Values(opt *ReadPageOptions) (url.Values, error) {
+	opt = initPageOptions(opt)
+
+	values, err := page.Pagination.Values(opt.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.FilterExpr != nil {
+		values.Set(initFiltersOptions(opt.Filter).Key, FormatFilterExpr(page.FilterExpr))
+	} else {
+		mergeValues(values, page.Filters.Values(opt.Filter))
+	}
+	mergeValues(values, page.Sorts.Values(opt.Sort))
+	mergeValues(values, page.Joins.Values(opt.Join))
+	mergeValues(values, page.Fields.Values(opt.Fields))
+	mergeValues(values, page.Search.Values(opt.Search))
+
+	paths := []string{}
+	for path := range page.JoinPages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		joinValues, err := page.JoinPages[path].Values(joinPageOptions(opt, path))
+		if err != nil {
+			return nil, err
+		}
+		mergeValues(values, joinValues)
+	}
+
+	return values, nil
 }
 
 // ReadPageOptions configures the behaviour of ReadPage.
@@ -19,39 +172,114 @@ type ReadPageOptions struct {
 	Filter     *ReadFiltersOptions
 	Sort       *ReadSortsOptions
 	Join       *ReadJoinsOptions
+	Fields     *ReadFieldsOptions // If set, sparse fieldsets are read. Otherwise, the fields key is ignored
+	Search     *ReadSearchOptions // If set, search terms are read. Otherwise, the search key is ignored
+
+	Schema        *Schema // If set, the page is validated against this schema.
+	CollectErrors bool    // If true, all errors are returned together as Errors instead of only the first error.
 }
 
 // ReadPage parses URL values into a convenient Page struct.
+//
+// If a schema is provided, the page is validated against it after reading.
+//
+// If filters are read as expressions, Page.FilterExpr is always set and Page.Filters is only set if the expression is a simple conjunction.
+//
+// Sparse fieldsets and search terms are only read if ReadPageOptions.Fields or ReadPageOptions.Search is set, so that handlers can continue to use their keys for their own purposes.
+//
+// Each join can be scoped with its own pagination, filters and sorts by prefixing their keys with the join path, such as comments.filter or author.profile.limit.
+// These are read into Page.JoinPages using the same options as the page itself. Prefixed keys for joins that are not requested are ignored.
+//
+// By default, ReadPage returns the first error it encounters.
+// If CollectErrors is set, it continues reading and returns every error as Errors, which can be grouped by query string key using Errors.ByKey.
 func ReadPage(values url.Values, opt *ReadPageOptions) (*Page, error) {
 	opt = initPageOptions(opt)
 
-	pag, err := ReadPagination(values, opt.Pagination)
-	if err != nil {
-		return nil, err
+	page, errs := readPage(values, opt)
+
+	joins, joinErrs := readJoins(values, initJoinsOptions(opt.Join))
+	errs = append(errs, joinErrs...)
+	page.Joins = joins
+
+	if opt.Fields != nil {
+		fields, fieldsErrs := readFields(values, opt.Fields)
+		errs = append(errs, fieldsErrs...)
+		page.Fields = fields
 	}
 
-	filters, err := ReadFilters(values, opt.Filter)
-	if err != nil {
-		return nil, err
+	if opt.Search != nil {
+		search, searchErrs := readSearch(values, opt.Search)
+		errs = append(errs, searchErrs...)
+		page.Search = search
 	}
 
-	sorts, err := ReadSorts(values, opt.Sort)
-	if err != nil {
-		return nil, err
+	for _, path := range joins.names() {
+		joinOpt := joinPageOptions(opt, path)
+		if !hasPageValues(values, joinOpt) {
+			continue
+		}
+		joinPage, joinErrs := readPage(values, joinOpt)
+		errs = append(errs, joinErrs...)
+		if page.JoinPages == nil {
+			page.JoinPages = map[string]*Page{}
+		}
+		page.JoinPages[path] = joinPage
 	}
 
-	joins, err := ReadJoins(values, opt.Join)
-	if err != nil {
-		return nil, err
+	if len(errs) > 0 && !opt.CollectErrors {
+		return nil, errs[0]
+	}
+
+	if opt.Schema != nil {
+		// Values that could not be read are not validated
+		errs = append(errs, opt.Schema.validateValues(page, values, opt)...)
+		for _, path := range joins.names() {
+			if joinPage := page.JoinPages[path]; joinPage != nil {
+				errs = append(errs, opt.Schema.joinSchema(path).validateValues(joinPage, values, joinPageOptions(opt, path))...)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return page, nil
+	}
+	if !opt.CollectErrors {
+		return nil, errs[0]
 	}
+	return nil, errs
+}
+
+// readPage reads pagination, filters and sorts, collecting all errors.
+// Joins are read separately by ReadPage, since they cannot be scoped to a join.
+func readPage(values url.Values, opt *ReadPageOptions) (*Page, Errors) {
+	errs := Errors{}
+
+	pag, pagErrs := readPagination(values, initPaginationOptions(opt.Pagination))
+	errs = append(errs, pagErrs...)
+
+	var filters Filters
+	var filterExpr FilterExpr
+	var filterErrs Errors
+	filterOpt := initFiltersOptions(opt.Filter)
+	if filterOpt.Expr {
+		filterExpr, filterErrs = readFilterExpr(values, filterOpt)
+		// Flat filters are only provided if the expression is a simple conjunction
+		filters, _ = FlattenFilters(filterExpr)
+	} else {
+		filters, filterErrs = readFilters(values, filterOpt)
+	}
+	errs = append(errs, filterErrs...)
+
+	sorts, sortErrs := readSorts(values, initSortsOptions(opt.Sort))
+	errs = append(errs, sortErrs...)
 
 	page := &Page{
 		Pagination: pag,
 		Filters:    filters,
+		FilterExpr: filterExpr,
 		Sorts:      sorts,
-		Joins:      joins,
 	}
-	return page, nil
+	return page, errs
 }
 
 // ReadRequestPage parses a request's query string into a convenient Page struct.
@@ -70,12 +298,74 @@ func ReadStringPage(qs string, opt *ReadPageOptions) (*Page, error) {
 	return ReadPage(values, opt)
 }
 
+func mergeValues(dst, src url.Values) {
+	for key, values := range src {
+		dst[key] = append(dst[key], values...)
+	}
+}
+
+// hasPageValues returns true if the values contain any pagination, filter or sort keys.
+func hasPageValues(values url.Values, opt *ReadPageOptions) bool {
+	for _, key := range pageKeys(opt) {
+		if values.Has(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinPageOptions returns options for reading the page scoped to a join, with keys prefixed by the join path.
+func joinPageOptions(opt *ReadPageOptions, path string) *ReadPageOptions {
+	prefix := path + "."
+
+	pagOpt := *initPaginationOptions(opt.Pagination)
+	pagOpt.LimitKey = prefix + pagOpt.LimitKey
+	pagOpt.OffsetKey = prefix + pagOpt.OffsetKey
+	pagOpt.PageKey = prefix + pagOpt.PageKey
+	pagOpt.CursorKey = prefix + pagOpt.CursorKey
+	pagOpt.AfterKey = prefix + pagOpt.AfterKey
+	pagOpt.BeforeKey = prefix + pagOpt.BeforeKey
+
+	filterOpt := *initFiltersOptions(opt.Filter)
+	filterOpt.Key = prefix + filterOpt.Key
+	filterOpt.KeyStyles = nil
+
+	sortOpt := *initSortsOptions(opt.Sort)
+	sortOpt.Key = prefix + sortOpt.Key
+
+	return &ReadPageOptions{
+		Pagination:    &pagOpt,
+		Filter:        &filterOpt,
+		Sort:          &sortOpt,
+		Join:          initJoinsOptions(opt.Join),
+		CollectErrors: opt.CollectErrors,
+	}
+}
+
+// pageKeys returns the query string keys used for pagination, filters and sorts.
+func pageKeys(opt *ReadPageOptions) []string {
+	pagOpt := initPaginationOptions(opt.Pagination)
+	return []string{
+		pagOpt.LimitKey, pagOpt.OffsetKey, pagOpt.PageKey, pagOpt.CursorKey, pagOpt.AfterKey, pagOpt.BeforeKey,
+		initFiltersOptions(opt.Filter).Key, initSortsOptions(opt.Sort).Key,
+	}
+}
+
 func initPageOptions(opt *ReadPageOptions) *ReadPageOptions {
 	def := &ReadPageOptions{}
 	if opt != nil {
 		def.Pagination = initPaginationOptions(opt.Pagination)
 		def.Filter = initFiltersOptions(opt.Filter)
 		def.Sort = initSortsOptions(opt.Sort)
+		def.Join = initJoinsOptions(opt.Join)
+		if opt.Fields != nil {
+			def.Fields = initFieldsOptions(opt.Fields)
+		}
+		if opt.Search != nil {
+			def.Search = initSearchOptions(opt.Search)
+		}
+		def.Schema = opt.Schema
+		def.CollectErrors = opt.CollectErrors
 	}
 	return def
 }
@@ -2,7 +2,9 @@ package qs
 
 import (
 	"errors"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestReadPage(t *testing.T) {
@@ -60,6 +62,17 @@ func TestReadPage(t *testing.T) {
 				Joins: Joins{"author": true},
 			},
 		},
+		{
+			Input: "filter=title eq Spaghetti or serves gte 4",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			Output: &Page{
+				Pagination: &Pagination{},
+				FilterExpr: Or{
+					Filter{Field: "title", Operator: "eq", Value: "Spaghetti"},
+					Filter{Field: "serves", Operator: "gte", Value: "4"},
+				},
+			},
+		},
 	}
 
 	for n, tc := range testCases {
@@ -102,6 +115,11 @@ func TestReadPage(t *testing.T) {
 			}
 		}
 
+		// Compare filter expression (see expr_test.go)
+		if !reflect.DeepEqual(page.FilterExpr, tc.Output.FilterExpr) {
+			t.Errorf("Expected %+v for filter expression, got %+v", tc.Output.FilterExpr, page.FilterExpr)
+		}
+
 		// Compare sorts (see sort_test.go)
 		if tc.Output.Sorts == nil && page.Sorts != nil {
 			t.Error("Expected nil sorts")
@@ -136,3 +154,251 @@ func TestReadPage(t *testing.T) {
 		}
 	}
 }
+
+func TestPageEncode(t *testing.T) {
+	type TestCase struct {
+		Inputs []string
+		Opt    *ReadPageOptions
+		Output string
+	}
+
+	testCases := []TestCase{
+		{Inputs: []string{""}, Output: ""},
+		{
+			Inputs: []string{
+				"limit=10&page=3&filter=title eq Spaghetti Bolognese&filter=serves gte 4&sort=serves desc&sort=title asc&join=author&join=ingredient",
+				"join=ingredient&join=author&sort=serves desc&sort=title asc&filter=serves gte 4&filter=title eq Spaghetti Bolognese&offset=20&limit=10",
+			},
+			Output: "filter=serves+gte+4&filter=title+eq+Spaghetti+Bolognese&join=author&join=ingredient&limit=10&offset=20&sort=serves+desc&sort=title+asc",
+		},
+		{
+			Inputs: []string{
+				"filter=(status eq draft or author eq 3) and serves gte 4",
+				"filter=serves gte 4&filter=author eq 3 or status eq draft",
+			},
+			Opt:    &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			Output: "filter=%28author+eq+3+or+status+eq+draft%29+and+serves+gte+4",
+		},
+		{
+			Inputs: []string{"filter=title eq 'Spaghetti Bolognese'&f=title eq Carbonara&l=5&o=5"},
+			Opt: &ReadPageOptions{
+				Filter:     &ReadFiltersOptions{Key: "f", Expr: true},
+				Pagination: &ReadPaginationOptions{LimitKey: "l", OffsetKey: "o"},
+			},
+			Output: "f=title+eq+Carbonara&l=5&o=5",
+		},
+		{
+			Inputs: []string{"limit=5&after=eyJ2Ijp7ImlkIjoiMTAifX0"},
+			Output: "after=eyJ2Ijp7ImlkIjoiMTAifX0&limit=5",
+		},
+		{
+			Inputs: []string{
+				"join=comments&comments.limit=5&comments.page=2&comments.filter=approved eq true&comments.filter=author eq 3&comments.sort=created desc",
+				"comments.sort=created desc&comments.filter=author eq 3&comments.filter=approved eq true&comments.offset=5&comments.limit=5&join=comments",
+			},
+			Output: "comments.filter=approved+eq+true&comments.filter=author+eq+3&comments.limit=5&comments.offset=5&comments.sort=created+desc&join=comments",
+		},
+		{
+			Inputs: []string{
+				"fields=title,serves&fields[author]=name",
+				"fields[author]=name&fields=serves&fields=title",
+			},
+			Opt:    &ReadPageOptions{Fields: &ReadFieldsOptions{}},
+			Output: "fields=serves%2Ctitle&fields%5Bauthor%5D=name",
+		},
+		{
+			Inputs: []string{`q=pasta  -title:"mushroom risotto"`, `q=pasta&q=-title:"mushroom risotto"`},
+			Opt:    &ReadPageOptions{Search: &ReadSearchOptions{}},
+			Output: "q=pasta+-title%3A%22mushroom+risotto%22",
+		},
+	}
+
+	for n, tc := range testCases {
+		for _, input := range tc.Inputs {
+			t.Logf("(%d) Testing %q with options %+v", n, input, tc.Opt)
+
+			page, err := ReadStringPage(input, tc.Opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			encoded, err := page.Encode(tc.Opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoded != tc.Output {
+				t.Errorf("Expected %q, got %q", tc.Output, encoded)
+			}
+
+			// Reading the encoded page back must produce the same encoding
+			reread, err := ReadStringPage(encoded, tc.Opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reencoded, _ := reread.Encode(tc.Opt); reencoded != encoded {
+				t.Errorf("Expected %q after reading back, got %q", encoded, reencoded)
+			}
+		}
+	}
+}
+
+func TestPageEncodeSignedCursor(t *testing.T) {
+	now := time.Date(2024, 7, 19, 12, 0, 0, 0, time.UTC)
+	signer := &CursorSigner{Keys: map[string][]byte{"k1": []byte("secret")}, KeyID: "k1", TTL: time.Hour, Now: func() time.Time { return now }}
+	opt := &ReadPageOptions{Pagination: &ReadPaginationOptions{CursorSigner: signer}}
+	page := &Page{Pagination: &Pagination{Limit: 5, Cursor: &Cursor{Values: map[string]string{"id": "10"}}}}
+
+	encoded, err := page.Encode(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if reencoded, _ := page.Encode(opt); reencoded != encoded {
+		t.Errorf("Expected %q after 30 minutes, got %q", encoded, reencoded)
+	}
+	if opt.Pagination.CursorSigner != signer {
+		t.Error("Expected options to be unchanged")
+	}
+
+	// The cursor is unsigned, so it can only be read back without a signer
+	if _, err := ReadStringPage(encoded, opt); !errors.Is(err, ErrTamperedCursor) {
+		t.Errorf("Expected error %v, got %v", ErrTamperedCursor, err)
+	}
+	reread, err := ReadStringPage(encoded, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reread.Pagination.Cursor, page.Pagination.Cursor) {
+		t.Errorf("Expected cursor %+v, got %+v", page.Pagination.Cursor, reread.Pagination.Cursor)
+	}
+}
+
+func TestPageQueryExpr(t *testing.T) {
+	type TestCase struct {
+		Input  *Page
+		Extra  []FilterExpr
+		Output FilterExpr
+		Sorts  Sorts
+		Err    error
+	}
+
+	sorts := Sorts{{Field: "id", Direction: "asc"}}
+	title := Filter{Field: "title", Operator: "eq", Value: "Soup"}
+	serves := Filter{Field: "serves", Operator: "gte", Value: "4"}
+
+	testCases := []TestCase{
+		{Input: &Page{}},
+		{Input: &Page{Filters: Filters{title}}, Output: title},
+		{Input: &Page{Filters: Filters{serves}, FilterExpr: title}, Extra: []FilterExpr{nil}, Output: title},
+		{Input: &Page{Filters: Filters{title, serves}}, Extra: []FilterExpr{Not{Expr: serves}}, Output: And{title, serves, Not{Expr: serves}}},
+		{
+			Input:  &Page{FilterExpr: And{title, serves}, Sorts: sorts, Pagination: &Pagination{Cursor: &Cursor{Values: map[string]string{"id": "10"}}}},
+			Output: And{title, serves, Filter{Field: "id", Operator: "gt", Value: "10"}},
+			Sorts:  sorts,
+		},
+		{
+			Input:  &Page{Sorts: sorts, Pagination: &Pagination{Cursor: &Cursor{Values: map[string]string{"id": "10"}, Before: true}}},
+			Output: Filter{Field: "id", Operator: "lt", Value: "10"},
+			Sorts:  Sorts{{Field: "id", Direction: "desc"}},
+		},
+		{Input: &Page{Pagination: &Pagination{Cursor: &Cursor{Values: map[string]string{"id": "10"}}}}, Err: ErrInvalidCursor},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v with %+v", n, tc.Input, tc.Extra)
+
+		expr, sorts, err := tc.Input.QueryExpr(tc.Extra...)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+		if !reflect.DeepEqual(sorts, tc.Sorts) {
+			t.Errorf("Expected sorts %+v, got %+v", tc.Sorts, sorts)
+		}
+	}
+}
+
+func TestPageValues(t *testing.T) {
+	page, _ := ReadStringPage("limit=10&page=3&filter=title eq Bolognese&sort=serves desc", nil)
+
+	values, err := page.Values(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "filter=title+eq+Bolognese&limit=10&page=3&sort=serves+desc"
+	if values.Encode() != expected {
+		t.Errorf("Expected %q, got %q", expected, values.Encode())
+	}
+}
+
+func TestReadPageJoinPages(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output map[string]*Page
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: "join=comments"},
+		{Input: "comments.limit=5"},
+		{
+			Input: "join=comments&comments.filter=approved eq true&comments.sort=created desc&comments.limit=5",
+			Output: map[string]*Page{
+				"comments": {
+					Pagination: &Pagination{Limit: 5},
+					Filters:    Filters{{Field: "approved", Operator: "eq", Value: "true"}},
+					Sorts:      Sorts{{Field: "created", Direction: "desc"}},
+				},
+			},
+		},
+		{
+			Input: "join=author.recipes&author.limit=1&author.recipes.limit=100",
+			Opt:   &ReadPageOptions{Pagination: &ReadPaginationOptions{MaxLimit: 10}},
+			Output: map[string]*Page{
+				"author":         {Pagination: &Pagination{Limit: 1}},
+				"author.recipes": {Pagination: &Pagination{Limit: 10}},
+			},
+		},
+		{
+			Input: "join=comments&comments.f=approved eq true",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Key: "f"}},
+			Output: map[string]*Page{
+				"comments": {
+					Pagination: &Pagination{},
+					Filters:    Filters{{Field: "approved", Operator: "eq", Value: "true"}},
+				},
+			},
+		},
+
+		{Input: "join=comments&comments.limit=five", Err: ErrInvalidLimit},
+		{Input: "join=comments&comments.sort=created up", Err: ErrInvalidSort},
+		{
+			Input: "join=comments&comments.sort=a asc&comments.sort=b asc",
+			Opt:   &ReadPageOptions{Sort: &ReadSortsOptions{MaxSorts: 1}},
+			Err:   ErrTooManySorts,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := ReadStringPage(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(page.JoinPages, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, page.JoinPages)
+		}
+	}
+}
@@ -19,6 +19,50 @@ type Pagination struct {
 	Limit  int `json:"limit"`          // Maximum number of results in the page.
 	Offset int `json:"offset"`         // Results offset.
 	Page   int `json:"page,omitempty"` // Page number. This is 0 if the query specifies Offset directly.
+
+	Cursor *Cursor `json:"cursor,omitempty"` // Keyset pagination cursor. If this is set, Offset and Page are always 0.
+}
+
+// Values returns the pagination as URL values, using the same options as ReadPagination.
+// Zero values are omitted.
+// If Page is set, it is used instead of Offset.
+//
+// If a cursor is set, it is encoded with the configured CursorSigner, if any.
+func (pag *Pagination) Values(opt *ReadPaginationOptions) (url.Values, error) {
+	opt = initPaginationOptions(opt)
+
+	values := url.Values{}
+	if pag == nil {
+		return values, nil
+	}
+
+	if pag.Limit > 0 {
+		values.Set(opt.LimitKey, strconv.Itoa(pag.Limit))
+	}
+
+	if pag.Cursor != nil {
+		token := ""
+		if opt.CursorSigner != nil {
+			var err error
+			token, err = opt.CursorSigner.Encode(pag.Cursor)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			token = pag.Cursor.Encode()
+		}
+		if pag.Cursor.Before {
+			values.Set(opt.BeforeKey, token)
+		} else {
+			values.Set(opt.AfterKey, token)
+		}
+	} else if pag.Page > 0 {
+		values.Set(opt.PageKey, strconv.Itoa(pag.Page))
+	} else if pag.Offset > 0 {
+		values.Set(opt.OffsetKey, strconv.Itoa(pag.Offset))
+	}
+
+	return values, nil
 }
 
 // ReadPaginationOptions configures the behaviour of ReadPagination.
@@ -27,6 +71,12 @@ type ReadPaginationOptions struct {
 	OffsetKey string // Query string key for offset. The default value is "offset"
 	PageKey   string // Query string key for page. The default value is "page"
 
+	CursorKey string // Query string key for a cursor to read after. The default value is "cursor"
+	AfterKey  string // Query string key for a cursor to read after. The default value is "after"
+	BeforeKey string // Query string key for a cursor to read before. The default value is "before"
+
+	CursorSigner *CursorSigner // If set, cursors must be signed and are verified by this signer
+
 	MaxLimit int // If this is > 0, the limit is clamped to this maximum value
 	MinLimit int // The limit is clamped to this minimum value
 }
@@ -35,18 +85,30 @@ type ReadPaginationOptions struct {
 // This function offers support for both Page and Offset values.
 // If both are provided, Offset is always prioritised.
 // If only Page is provided, Offset is calculated based on Limit.
+//
+// If a cursor is provided, keyset pagination is used instead and both Page and Offset are ignored.
+// Only one cursor may be provided.
+// If a CursorSigner is configured, the cursor is verified, returning ErrTamperedCursor or ErrExpiredCursor if it is not valid.
 func ReadPagination(values url.Values, opt *ReadPaginationOptions) (*Pagination, error) {
-	opt = initPaginationOptions(opt)
+	pag, errs := readPagination(values, initPaginationOptions(opt))
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return pag, nil
+}
 
+// readPagination parses URL values into pagination, collecting all errors.
+func readPagination(values url.Values, opt *ReadPaginationOptions) (*Pagination, Errors) {
 	limit := 0
 	offset := 0
 	page := 0
+	errs := Errors{}
 	var err error = nil
 
 	if values.Has(opt.LimitKey) {
 		limit, err = strconv.Atoi(values.Get(opt.LimitKey))
 		if err != nil {
-			return nil, ErrInvalidLimit
+			errs = append(errs, NewParseError(opt.LimitKey, 0, values.Get(opt.LimitKey), 0, ReasonBadNumber, ErrInvalidLimit))
 		}
 	}
 
@@ -56,19 +118,37 @@ func ReadPagination(values url.Values, opt *ReadPaginationOptions) (*Pagination,
 		limit = opt.MinLimit
 	}
 
+	cursor, err := readCursor(values, opt)
+	if err != nil {
+		return nil, append(errs, err)
+	}
+	if cursor != nil {
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		pag := &Pagination{
+			Limit:  limit,
+			Cursor: cursor,
+		}
+		return pag, nil
+	}
+
 	if values.Has(opt.OffsetKey) {
 		offset, err = strconv.Atoi(values.Get(opt.OffsetKey))
 		if err != nil {
-			return nil, ErrInvalidOffset
+			errs = append(errs, NewParseError(opt.OffsetKey, 0, values.Get(opt.OffsetKey), 0, ReasonBadNumber, ErrInvalidOffset))
 		}
 	} else if values.Has(opt.PageKey) {
 		page, err = strconv.Atoi(values.Get(opt.PageKey))
 		if err != nil {
-			return nil, ErrInvalidPage
+			errs = append(errs, NewParseError(opt.PageKey, 0, values.Get(opt.PageKey), 0, ReasonBadNumber, ErrInvalidPage))
 		}
 		offset = (page - 1) * limit
 	}
 
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	pag := &Pagination{
 		Limit:  limit,
 		Offset: offset,
@@ -93,11 +173,50 @@ func ReadStringPagination(qs string, opt *ReadPaginationOptions) (*Pagination, e
 	return ReadPagination(values, opt)
 }
 
+// ReadNumber parses a non-negative integer from URL values.
+// If the value is not a non-negative integer, a ParseError wrapping err is returned.
+// This is exported for the use of readers that parse other query formats.
+func ReadNumber(values url.Values, key string, err error) (int, error) {
+	n, perr := strconv.Atoi(values.Get(key))
+	if perr != nil || n < 0 {
+		return 0, NewParseError(key, 0, values.Get(key), 0, ReasonBadNumber, err)
+	}
+	return n, nil
+}
+
+func readCursor(values url.Values, opt *ReadPaginationOptions) (*Cursor, error) {
+	var cursor *Cursor
+	for _, key := range []string{opt.CursorKey, opt.AfterKey, opt.BeforeKey} {
+		if !values.Has(key) {
+			continue
+		}
+		if cursor != nil || len(values[key]) > 1 {
+			index := len(values[key]) - 1
+			return nil, NewParseError(key, index, values[key][index], 0, ReasonTooMany, ErrInvalidCursor)
+		}
+
+		var err error
+		if opt.CursorSigner != nil {
+			cursor, err = opt.CursorSigner.Decode(values.Get(key))
+		} else {
+			cursor, err = DecodeCursor(values.Get(key))
+		}
+		if err != nil {
+			return nil, NewParseError(key, 0, values.Get(key), 0, ReasonBadCursor, err)
+		}
+		cursor.Before = key == opt.BeforeKey
+	}
+	return cursor, nil
+}
+
 func initPaginationOptions(opt *ReadPaginationOptions) *ReadPaginationOptions {
 	def := &ReadPaginationOptions{
 		LimitKey:  "limit",
 		OffsetKey: "offset",
 		PageKey:   "page",
+		CursorKey: "cursor",
+		AfterKey:  "after",
+		BeforeKey: "before",
 	}
 
 	if opt != nil {
@@ -110,6 +229,17 @@ func initPaginationOptions(opt *ReadPaginationOptions) *ReadPaginationOptions {
 		if len(opt.PageKey) > 0 {
 			def.PageKey = opt.PageKey
 		}
+		if len(opt.CursorKey) > 0 {
+			def.CursorKey = opt.CursorKey
+		}
+		if len(opt.AfterKey) > 0 {
+			def.AfterKey = opt.AfterKey
+		}
+		if len(opt.BeforeKey) > 0 {
+			def.BeforeKey = opt.BeforeKey
+		}
+
+		def.CursorSigner = opt.CursorSigner
 
 		if opt.MaxLimit > def.MaxLimit {
 			def.MaxLimit = opt.MaxLimit
@@ -2,9 +2,16 @@ package qs
 
 import (
 	"errors"
+	"net/url"
+	"reflect"
 	"testing"
 )
 
+var (
+	testCursor       = &Cursor{Values: map[string]string{"id": "10"}}
+	testCursorBefore = &Cursor{Values: map[string]string{"id": "10"}, Before: true}
+)
+
 func TestReadPagination(t *testing.T) {
 	type TestCase struct {
 		Input  string
@@ -24,12 +31,18 @@ func TestReadPagination(t *testing.T) {
 		{Input: "limit=3", Opt: &ReadPaginationOptions{MinLimit: 5, MaxLimit: 10}, Output: &Pagination{Limit: 5}},
 		{Input: "limit=20", Opt: &ReadPaginationOptions{MinLimit: 5, MaxLimit: 10}, Output: &Pagination{Limit: 10}},
 
+		{Input: "limit=10&offset=5&after=eyJ2Ijp7ImlkIjoiMTAifX0", Output: &Pagination{Limit: 10, Cursor: testCursor}},
+		{Input: "limit=10&cursor=eyJ2Ijp7ImlkIjoiMTAifX0", Output: &Pagination{Limit: 10, Cursor: testCursor}},
+		{Input: "limit=10&before=eyJ2Ijp7ImlkIjoiMTAifX0", Output: &Pagination{Limit: 10, Cursor: testCursorBefore}},
+
 		{Input: "limit=abc", Err: ErrInvalidLimit},
 		{Input: "offset=def", Err: ErrInvalidOffset},
 		{Input: "page=ghi", Err: ErrInvalidPage},
 		{Input: "limit=abc&offset=5", Err: ErrInvalidLimit},
 		{Input: "limit=5&offset=def", Err: ErrInvalidOffset},
 		{Input: "limit=5&page=ghi", Err: ErrInvalidPage},
+		{Input: "after=abc", Err: ErrInvalidCursor},
+		{Input: "after=eyJ2Ijp7ImlkIjoiMTAifX0&before=eyJ2Ijp7ImlkIjoiMTAifX0", Err: ErrInvalidCursor},
 	}
 
 	for n, tc := range testCases {
@@ -46,8 +59,44 @@ func TestReadPagination(t *testing.T) {
 			continue
 		}
 
-		if *pag != *tc.Output {
+		if !reflect.DeepEqual(pag, tc.Output) {
 			t.Errorf("Expected %+v, got %+v", tc.Output, pag)
 		}
 	}
 }
+
+func TestReadNumber(t *testing.T) {
+	type TestCase struct {
+		Input  url.Values
+		Output int
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: url.Values{"top": {"10"}}, Output: 10},
+		{Input: url.Values{"top": {"0"}}, Output: 0},
+		{Input: url.Values{"top": {"-1"}}, Err: ErrInvalidLimit},
+		{Input: url.Values{"top": {"ten"}}, Err: ErrInvalidLimit},
+		{Input: url.Values{}, Err: ErrInvalidLimit},
+	}
+
+	for i, tc := range testCases {
+		t.Logf("(%d) Testing %v", i, tc.Input)
+
+		n, err := ReadNumber(tc.Input, "top", ErrInvalidLimit)
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+			continue
+		}
+		if err != nil {
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Key != "top" || perr.Reason != ReasonBadNumber {
+				t.Errorf("Expected ParseError for top with reason %q, got %v", ReasonBadNumber, err)
+			}
+			continue
+		}
+		if n != tc.Output {
+			t.Errorf("Expected %d, got %d", tc.Output, n)
+		}
+	}
+}
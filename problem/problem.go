@@ -0,0 +1,143 @@
+// Package problem renders qs query errors as RFC 7807 problem details.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/annybs/go-qs"
+)
+
+// ContentType is the media type of a problem details document.
+const ContentType = "application/problem+json"
+
+// TypeInvalidQuery identifies problems caused by an invalid query string.
+// The client should correct the parameters listed in Problem.InvalidParams and retry the request.
+const TypeInvalidQuery = "https://pkg.go.dev/github.com/annybs/go-qs/problem#TypeInvalidQuery"
+
+// queryErrors are the qs errors caused by a client's query string.
+var queryErrors = []error{
+	qs.ErrInvalidFilter,
+	qs.ErrTooManyFilters,
+	qs.ErrUnsupportedOperator,
+	qs.ErrUnsupportedExpr,
+	qs.ErrComplexFilter,
+	qs.ErrInvalidSort,
+	qs.ErrTooManySorts,
+	qs.ErrInvalidJoin,
+	qs.ErrTooManyJoins,
+	qs.ErrInvalidFields,
+	qs.ErrTooManyFields,
+	qs.ErrInvalidSearch,
+	qs.ErrTooManyTerms,
+	qs.ErrUnsupportedSearch,
+	qs.ErrInvalidLimit,
+	qs.ErrInvalidOffset,
+	qs.ErrInvalidPage,
+	qs.ErrInvalidCursor,
+	qs.ErrTamperedCursor,
+	qs.ErrExpiredCursor,
+	qs.ErrUnknownField,
+	qs.ErrOperatorNotAllowed,
+	qs.ErrInvalidValue,
+	qs.ErrSortNotAllowed,
+	qs.ErrSelectNotAllowed,
+	qs.ErrUnknownJoin,
+}
+
+// Problem is an RFC 7807 problem details document.
+type Problem struct {
+	Type          string         `json:"type"`                     // Problem type URI.
+	Title         string         `json:"title"`                    // Short summary of the problem type.
+	Status        int            `json:"status"`                   // HTTP status code.
+	Detail        string         `json:"detail,omitempty"`         // Explanation specific to this occurrence of the problem.
+	Instance      string         `json:"instance,omitempty"`       // URI identifying this occurrence of the problem.
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"` // Query string parameters that caused the problem.
+}
+
+// InvalidParam describes a query string parameter that caused a problem.
+type InvalidParam struct {
+	Name   string    `json:"name"`             // Query string key.
+	Value  string    `json:"value"`            // Raw value.
+	Reason string    `json:"reason"`           // Human-readable reason.
+	Code   qs.Reason `json:"code,omitempty"`   // Machine-readable reason.
+	Offset int       `json:"offset,omitempty"` // Byte offset within the value at which the problem was found.
+}
+
+// IsQueryError reports whether an error was caused by a client's query string, rather than by the server.
+func IsQueryError(err error) bool {
+	var perr *qs.ParseError
+	if errors.As(err, &perr) {
+		return true
+	}
+	for _, target := range queryErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates a problem from an error returned by this module.
+//
+// If the error was caused by the query string, the problem has status 400 and lists each offending parameter, including all errors collected by qs.ReadPage when CollectErrors is set.
+// Any other error is treated as a server error with status 500, and its message is not disclosed.
+func New(err error) *Problem {
+	if !IsQueryError(err) {
+		return &Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusInternalServerError),
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	p := &Problem{
+		Type:   TypeInvalidQuery,
+		Title:  "Invalid query",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	}
+
+	errs := qs.Errors{err}
+	errors.As(err, &errs)
+	for _, err := range errs {
+		var perr *qs.ParseError
+		if !errors.As(err, &perr) {
+			continue
+		}
+		p.InvalidParams = append(p.InvalidParams, InvalidParam{
+			Name:   perr.Key,
+			Value:  perr.Input,
+			Reason: perr.Error(),
+			Code:   perr.Reason,
+			Offset: perr.Offset,
+		})
+	}
+
+	if len(errs) > 1 {
+		p.Detail = fmt.Sprintf("The query string has %d problems.", len(errs))
+	}
+
+	return p
+}
+
+// Write writes the problem to an HTTP response.
+func (p *Problem) Write(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
+
+// Write writes an error to an HTTP response as a problem details document.
+// See New for how errors are mapped to problems.
+func Write(w http.ResponseWriter, err error) error {
+	return New(err).Write(w)
+}
+
+// WriteError writes an error to an HTTP response as a problem details document.
+// It can be passed to qs.Middleware as a qs.ErrorWriter.
+func WriteError(w http.ResponseWriter, req *http.Request, err error) {
+	Write(w, err)
+}
@@ -0,0 +1,143 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestNew(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *qs.ReadPageOptions
+		Status int
+		Params []InvalidParam
+	}
+
+	testCases := []TestCase{
+		{
+			Input:  "filter=serves is 4",
+			Status: http.StatusBadRequest,
+			Params: []InvalidParam{
+				{Name: "filter", Value: "serves is 4", Reason: "invalid filter: unknown operator in filter[0] at offset 7", Code: qs.ReasonUnknownOperator, Offset: 7},
+			},
+		},
+		{
+			Input:  "sort=a asc&sort=b asc",
+			Opt:    &qs.ReadPageOptions{Sort: &qs.ReadSortsOptions{MaxSorts: 1}},
+			Status: http.StatusBadRequest,
+			Params: []InvalidParam{
+				{Name: "sort", Value: "b asc", Reason: "too many sorts: too many in sort[1] at offset 0", Code: qs.ReasonTooMany},
+			},
+		},
+		{
+			Input:  "limit=ten&sort=title up",
+			Opt:    &qs.ReadPageOptions{CollectErrors: true},
+			Status: http.StatusBadRequest,
+			Params: []InvalidParam{
+				{Name: "limit", Value: "ten", Reason: "invalid limit: bad number in limit[0] at offset 0", Code: qs.ReasonBadNumber},
+				{Name: "sort", Value: "title up", Reason: "invalid sort: bad direction in sort[0] at offset 6", Code: qs.ReasonBadDirection, Offset: 6},
+			},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		_, err := qs.ReadStringPage(tc.Input, tc.Opt)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+			continue
+		}
+
+		p := New(err)
+		if p.Status != tc.Status {
+			t.Errorf("Expected status %d, got %d", tc.Status, p.Status)
+		}
+		if p.Type != TypeInvalidQuery {
+			t.Errorf("Expected type %q, got %q", TypeInvalidQuery, p.Type)
+		}
+		if !reflect.DeepEqual(p.InvalidParams, tc.Params) {
+			t.Errorf("Expected params %+v, got %+v", tc.Params, p.InvalidParams)
+		}
+	}
+}
+
+func TestIsQueryError(t *testing.T) {
+	for n, err := range []error{qs.ErrInvalidFilter, qs.ErrUnsupportedOperator, fmt.Errorf("compile: %w", qs.ErrUnsupportedExpr)} {
+		t.Logf("(%d) Testing %v", n, err)
+
+		if !IsQueryError(err) {
+			t.Errorf("Expected query error")
+		}
+	}
+
+	if IsQueryError(errors.New("database unavailable")) {
+		t.Errorf("Expected server error")
+	}
+}
+
+func TestNewServerError(t *testing.T) {
+	for n, err := range []error{errors.New("database unavailable"), qs.ErrCursorKeyNotFound} {
+		t.Logf("(%d) Testing %v", n, err)
+
+		p := New(err)
+		if p.Status != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, p.Status)
+		}
+		if p.Detail != "" {
+			t.Errorf("Expected no detail, got %q", p.Detail)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := Write(w, qs.ErrInvalidLimit); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, ct)
+	}
+
+	doc := map[string]any{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	expected := map[string]any{
+		"type":   TypeInvalidQuery,
+		"title":  "Invalid query",
+		"status": float64(http.StatusBadRequest),
+		"detail": "invalid limit",
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, doc)
+	}
+}
+
+func TestWriteErrorMiddleware(t *testing.T) {
+	handler := qs.Middleware(nil, WriteError)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Error("Expected handler not to be called")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/recipes?sort=title+up", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, ct)
+	}
+}
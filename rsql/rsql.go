@@ -0,0 +1,484 @@
+// Package rsql reads and writes filter expressions in RSQL, a query language based on FIQL, such as:
+//
+//	title==Bolognese;serves=ge=4,author=in=(1,2)
+//
+// Expressions are read into the same filter tree as qs.ParseFilterExpr, so handlers can accept RSQL without depending on the format.
+package rsql
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// operators maps RSQL and FIQL comparison operators to qs filter operators.
+var operators = map[string]string{
+	"==":    "eq",
+	"!=":    "neq",
+	"=gt=":  "gt",
+	">":     "gt",
+	"=ge=":  "gte",
+	">=":    "gte",
+	"=lt=":  "lt",
+	"<":     "lt",
+	"=le=":  "lte",
+	"<=":    "lte",
+	"=in=":  "in",
+	"=out=": "not in",
+}
+
+// formatOperators maps qs filter operators to RSQL comparison operators.
+var formatOperators = map[string]string{
+	"eq":       "==",
+	"neq":      "!=",
+	"gt":       "=gt=",
+	"gte":      "=ge=",
+	"lt":       "=lt=",
+	"lte":      "=le=",
+	"in":       "=in=",
+	"not in":   "=out=",
+	"like":     "==",
+	"not like": "!=",
+}
+
+// negatedOperators maps each qs filter operator to its opposite.
+var negatedOperators = map[string]string{
+	"eq":       "neq",
+	"neq":      "eq",
+	"gt":       "lte",
+	"gte":      "lt",
+	"lt":       "gte",
+	"lte":      "gt",
+	"in":       "not in",
+	"not in":   "in",
+	"like":     "not like",
+	"not like": "like",
+}
+
+// Parse parses an RSQL expression into a filter expression.
+//
+// Comparisons take the form selector, operator, argument. The following operators are supported:
+//
+//	==          eq, or like if the argument contains a * wildcard
+//	!=          neq, or not like if the argument contains a * wildcard
+//	=gt= >      gt
+//	=ge= >=     gte
+//	=lt= <      lt
+//	=le= <=     lte
+//	=in=        in, with arguments in parentheses, such as author=in=(1,2)
+//	=out=       not in
+//
+// Comparisons are combined with ; (and) and , (or), and grouped with parentheses. ; binds more tightly than ,.
+// Arguments containing reserved characters must be quoted with either single or double quotes.
+// Within quotes, \ escapes the following character, so \* matches a literal asterisk.
+//
+// If the expression is invalid, this function returns a *qs.ParseError wrapping qs.ErrInvalidFilter.
+func Parse(s string) (qs.FilterExpr, error) {
+	p := &parser{input: s}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(s) {
+		return nil, p.errorAt(p.pos, qs.ReasonUnexpectedToken)
+	}
+	return expr, nil
+}
+
+// Format returns a filter expression in RSQL.
+//
+// RSQL has no negation, so Not expressions are written by inverting their operands' operators, for example not (a eq 1 or b lt 2) is written as a!=1;b=ge=2.
+// If the expression uses an operator that cannot be written, this function returns qs.ErrUnsupportedOperator.
+func Format(expr qs.FilterExpr) (string, error) {
+	return format(expr, false)
+}
+
+// FormatFilters returns filters in RSQL, combined with ;.
+// This function returns an empty string if there are no filters.
+func FormatFilters(filters qs.Filters) (string, error) {
+	if len(filters) == 0 {
+		return "", nil
+	}
+	return Format(filters.Expr())
+}
+
+// ReadFiltersOptions configures the behaviour of ReadFilterExpr.
+type ReadFiltersOptions struct {
+	Key        string // Query string key. The default value is "filter"
+	MaxFilters int    // If this is > 0, a maximum number of comparisons is imposed
+}
+
+// ReadFilterExpr parses URL values containing RSQL into a filter expression.
+// If there are multiple values, they are combined into an And expression.
+// This function returns nil if no filters are found.
+func ReadFilterExpr(values url.Values, opt *ReadFiltersOptions) (qs.FilterExpr, error) {
+	opt = initFiltersOptions(opt)
+
+	and := qs.And{}
+	count := 0
+	for i, s := range values[opt.Key] {
+		expr, err := Parse(s)
+		if err != nil {
+			return nil, qs.WithKey(err, opt.Key, i)
+		}
+		count += qs.CountFilters(expr)
+		if opt.MaxFilters > 0 && count > opt.MaxFilters {
+			return nil, qs.NewParseError(opt.Key, i, s, 0, qs.ReasonTooMany, qs.ErrTooManyFilters)
+		}
+		and = append(and, expr)
+	}
+
+	switch len(and) {
+	case 0:
+		return nil, nil
+	case 1:
+		return and[0], nil
+	}
+	return and, nil
+}
+
+// ReadRequestFilterExpr parses a request's query string containing RSQL into a filter expression.
+// This function returns nil if no filters are found.
+func ReadRequestFilterExpr(req *http.Request, opt *ReadFiltersOptions) (qs.FilterExpr, error) {
+	return ReadFilterExpr(req.URL.Query(), opt)
+}
+
+// ReadStringFilterExpr parses a query string literal containing RSQL into a filter expression.
+// This function returns nil if no filters are found.
+func ReadStringFilterExpr(s string, opt *ReadFiltersOptions) (qs.FilterExpr, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	return ReadFilterExpr(values, opt)
+}
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) errorAt(offset int, reason qs.Reason) error {
+	return qs.NewParseError("", 0, p.input, offset, reason, qs.ErrInvalidFilter)
+}
+
+// skip advances past a character, if it is next in the input.
+func (p *parser) skip(c byte) bool {
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (qs.FilterExpr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	or := qs.Or{expr}
+	for p.skip(',') {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (qs.FilterExpr, error) {
+	expr, err := p.parseConstraint()
+	if err != nil {
+		return nil, err
+	}
+
+	and := qs.And{expr}
+	for p.skip(';') {
+		expr, err := p.parseConstraint()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseConstraint() (qs.FilterExpr, error) {
+	if open := p.pos; p.skip('(') {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.skip(')') {
+			return nil, p.errorAt(open, qs.ReasonUnclosedGroup)
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (qs.FilterExpr, error) {
+	start := p.pos
+	n := scanUnreserved(p.input[p.pos:])
+	field := p.input[p.pos : p.pos+n]
+	if n == 0 || !qs.IsField(field) {
+		return nil, p.errorAt(start, qs.ReasonBadField)
+	}
+	p.pos += n
+
+	opStart := p.pos
+	op := scanOperator(p.input[p.pos:])
+	if op == "" {
+		return nil, p.errorAt(opStart, qs.ReasonMissingOperator)
+	}
+	operator, ok := operators[op]
+	if !ok {
+		return nil, p.errorAt(opStart, qs.ReasonUnknownOperator)
+	}
+	p.pos += len(op)
+
+	if operator == "in" || operator == "not in" {
+		open := p.pos
+		if !p.skip('(') {
+			return nil, p.errorAt(open, qs.ReasonMissingValue)
+		}
+		values := []string{}
+		for {
+			valueStart := p.pos
+			value, _, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			if strings.Contains(value, ",") {
+				// qs lists are comma-separated, so this value cannot be represented
+				return nil, p.errorAt(valueStart, qs.ReasonInvalidValue)
+			}
+			values = append(values, value)
+			if !p.skip(',') {
+				break
+			}
+		}
+		if !p.skip(')') {
+			return nil, p.errorAt(open, qs.ReasonUnclosedGroup)
+		}
+		return qs.Filter{Field: field, Operator: operator, Value: strings.Join(values, ",")}, nil
+	}
+
+	value, pattern, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if pattern != "" && operator == "eq" {
+		return qs.Filter{Field: field, Operator: "like", Value: pattern}, nil
+	}
+	if pattern != "" && operator == "neq" {
+		return qs.Filter{Field: field, Operator: "not like", Value: pattern}, nil
+	}
+	return qs.Filter{Field: field, Operator: operator, Value: value}, nil
+}
+
+// parseValue parses a quoted or unquoted argument.
+// If the argument contains a wildcard, it is also returned as a like pattern; otherwise, the pattern is empty.
+func (p *parser) parseValue() (string, string, error) {
+	value := strings.Builder{}
+	pattern := strings.Builder{}
+	wildcard := false
+
+	if p.pos < len(p.input) && (p.input[p.pos] == '"' || p.input[p.pos] == '\'') {
+		quote := p.input[p.pos]
+		start := p.pos
+		for p.pos++; p.pos < len(p.input) && p.input[p.pos] != quote; p.pos++ {
+			c := p.input[p.pos]
+			if c == '\\' && p.pos+1 < len(p.input) {
+				p.pos++
+				c = p.input[p.pos]
+				value.WriteByte(c)
+				pattern.WriteString(qs.EscapeLike(string(c)))
+				continue
+			}
+			value.WriteByte(c)
+			if c == '*' {
+				pattern.WriteByte('%')
+				wildcard = true
+			} else {
+				pattern.WriteString(qs.EscapeLike(string(c)))
+			}
+		}
+		if !p.skip(quote) {
+			return "", "", p.errorAt(start, qs.ReasonUnterminatedQuote)
+		}
+	} else {
+		n := scanUnreserved(p.input[p.pos:])
+		if n == 0 {
+			return "", "", p.errorAt(p.pos, qs.ReasonMissingValue)
+		}
+		for _, c := range []byte(p.input[p.pos : p.pos+n]) {
+			value.WriteByte(c)
+			if c == '*' {
+				pattern.WriteByte('%')
+				wildcard = true
+			} else {
+				pattern.WriteString(qs.EscapeLike(string(c)))
+			}
+		}
+		p.pos += n
+	}
+
+	if !wildcard {
+		return value.String(), "", nil
+	}
+	return value.String(), pattern.String(), nil
+}
+
+func format(expr qs.FilterExpr, negate bool) (string, error) {
+	switch node := expr.(type) {
+	case qs.Filter:
+		switch node.Operator {
+		case "co", "sw", "ew":
+			// Substring operators are written as wildcard arguments
+			node = qs.Filter{Field: node.Field, Operator: "like", Value: node.LikePattern()}
+		}
+		if negate {
+			operator, ok := negatedOperators[node.Operator]
+			if !ok {
+				return "", qs.ErrUnsupportedOperator
+			}
+			node.Operator = operator
+		}
+		return formatFilter(node)
+	case qs.And:
+		// Negating a junction inverts it, following De Morgan's laws
+		return formatJunction(node, !negate, negate)
+	case qs.Or:
+		return formatJunction(node, negate, negate)
+	case qs.Not:
+		return format(node.Expr, !negate)
+	}
+	return "", qs.ErrUnsupportedExpr
+}
+
+// formatJunction writes the operands of an And or Or, joined with ; if and is true or , otherwise.
+func formatJunction(operands []qs.FilterExpr, and, negate bool) (string, error) {
+	strs := []string{}
+	for _, operand := range operands {
+		s, err := format(operand, negate)
+		if err != nil {
+			return "", err
+		}
+		if and && isOr(operand, negate) {
+			s = "(" + s + ")"
+		}
+		strs = append(strs, s)
+	}
+	if and {
+		return strings.Join(strs, ";"), nil
+	}
+	return strings.Join(strs, ","), nil
+}
+
+func formatFilter(filter qs.Filter) (string, error) {
+	op, ok := formatOperators[filter.Operator]
+	if !ok {
+		return "", qs.ErrUnsupportedOperator
+	}
+
+	switch filter.Operator {
+	case "in", "not in":
+		values, _ := filter.StringSlice()
+		for i, value := range values {
+			values[i] = quoteValue(value)
+		}
+		return filter.Field + op + "(" + strings.Join(values, ",") + ")", nil
+	case "like", "not like":
+		segments := filter.LikeSegments()
+		for i, segment := range segments {
+			segments[i] = valueEscaper.Replace(segment)
+		}
+		return filter.Field + op + `"` + strings.Join(segments, "*") + `"`, nil
+	}
+	return filter.Field + op + quoteValue(filter.Value), nil
+}
+
+// isOr returns true if an expression is written as an or, after negation.
+func isOr(expr qs.FilterExpr, negate bool) bool {
+	switch node := expr.(type) {
+	case qs.Or:
+		return !negate && len(node) > 1
+	case qs.And:
+		return negate && len(node) > 1
+	case qs.Not:
+		return isOr(node.Expr, !negate)
+	}
+	return false
+}
+
+var valueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "*", `\*`)
+
+// quoteValue quotes an argument if it is empty or contains reserved characters or wildcards.
+func quoteValue(value string) string {
+	if value != "" && scanUnreserved(value) == len(value) && !strings.ContainsAny(value, `*\`) {
+		return value
+	}
+	return `"` + valueEscaper.Replace(value) + `"`
+}
+
+// scanOperator returns the comparison operator at the start of a string, or an empty string if there is none.
+func scanOperator(s string) string {
+	for _, op := range []string{"==", "!=", ">=", "<="} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	if strings.HasPrefix(s, ">") || strings.HasPrefix(s, "<") {
+		return s[:1]
+	}
+	if strings.HasPrefix(s, "=") {
+		n := 1
+		for n < len(s) && (s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z') {
+			n++
+		}
+		if n > 1 && n < len(s) && s[n] == '=' {
+			return s[:n+1]
+		}
+	}
+	return ""
+}
+
+// scanUnreserved returns the length of the run of unreserved characters at the start of a string.
+func scanUnreserved(s string) int {
+	n := 0
+	for n < len(s) && !strings.ContainsRune("\"'();,=!~<> \t\n\r", rune(s[n])) {
+		n++
+	}
+	return n
+}
+
+func initFiltersOptions(opt *ReadFiltersOptions) *ReadFiltersOptions {
+	def := &ReadFiltersOptions{
+		Key: "filter",
+	}
+
+	if opt != nil {
+		if len(opt.Key) > 0 {
+			def.Key = opt.Key
+		}
+
+		if opt.MaxFilters > def.MaxFilters {
+			def.MaxFilters = opt.MaxFilters
+		}
+	}
+
+	return def
+}
@@ -0,0 +1,216 @@
+package rsql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestParse(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.FilterExpr
+		Err    error
+		Offset int
+		Reason qs.Reason
+	}
+
+	testCases := []TestCase{
+		{Input: "title==Bolognese", Output: qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}},
+		{Input: "title!='Spaghetti Bolognese'", Output: qs.Filter{Field: "title", Operator: "neq", Value: "Spaghetti Bolognese"}},
+		{Input: `title=="say \"hi\""`, Output: qs.Filter{Field: "title", Operator: "eq", Value: `say "hi"`}},
+		{Input: "serves=gt=4", Output: qs.Filter{Field: "serves", Operator: "gt", Value: "4"}},
+		{Input: "serves>=4", Output: qs.Filter{Field: "serves", Operator: "gte", Value: "4"}},
+		{Input: "serves=lt=4", Output: qs.Filter{Field: "serves", Operator: "lt", Value: "4"}},
+		{Input: "serves<=4", Output: qs.Filter{Field: "serves", Operator: "lte", Value: "4"}},
+		{Input: "author=in=(1,2,3)", Output: qs.Filter{Field: "author", Operator: "in", Value: "1,2,3"}},
+		{Input: "status=out=(draft,'in review')", Output: qs.Filter{Field: "status", Operator: "not in", Value: "draft,in review"}},
+		{Input: "title==Spag*", Output: qs.Filter{Field: "title", Operator: "like", Value: "Spag%"}},
+		{Input: "title!=*100%*", Output: qs.Filter{Field: "title", Operator: "not like", Value: `%100\%%`}},
+		{Input: `title=="a\*b"`, Output: qs.Filter{Field: "title", Operator: "eq", Value: "a*b"}},
+		{Input: `title=="*a\*b"`, Output: qs.Filter{Field: "title", Operator: "like", Value: "%a*b"}},
+		{
+			Input: "title==Bolognese;serves=ge=4,author=in=(1,2)",
+			Output: qs.Or{
+				qs.And{qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}, qs.Filter{Field: "serves", Operator: "gte", Value: "4"}},
+				qs.Filter{Field: "author", Operator: "in", Value: "1,2"},
+			},
+		},
+		{
+			Input: "(status==draft,author==3);serves=lt=4",
+			Output: qs.And{
+				qs.Or{qs.Filter{Field: "status", Operator: "eq", Value: "draft"}, qs.Filter{Field: "author", Operator: "eq", Value: "3"}},
+				qs.Filter{Field: "serves", Operator: "lt", Value: "4"},
+			},
+		},
+
+		{Input: "", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonBadField},
+		{Input: "author.name==Anny", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonBadField},
+		{Input: "title", Err: qs.ErrInvalidFilter, Offset: 5, Reason: qs.ReasonMissingOperator},
+		{Input: "title=is=Soup", Err: qs.ErrInvalidFilter, Offset: 5, Reason: qs.ReasonUnknownOperator},
+		{Input: "title==", Err: qs.ErrInvalidFilter, Offset: 7, Reason: qs.ReasonMissingValue},
+		{Input: "title=='Soup", Err: qs.ErrInvalidFilter, Offset: 7, Reason: qs.ReasonUnterminatedQuote},
+		{Input: "author=in=1", Err: qs.ErrInvalidFilter, Offset: 10, Reason: qs.ReasonMissingValue},
+		{Input: "author=in=(1,2", Err: qs.ErrInvalidFilter, Offset: 10, Reason: qs.ReasonUnclosedGroup},
+		{Input: "status=in=('a,b')", Err: qs.ErrInvalidFilter, Offset: 11, Reason: qs.ReasonInvalidValue},
+		{Input: "(title==Soup", Err: qs.ErrInvalidFilter, Offset: 0, Reason: qs.ReasonUnclosedGroup},
+		{Input: "title==Soup)", Err: qs.ErrInvalidFilter, Offset: 11, Reason: qs.ReasonUnexpectedToken},
+		{Input: "title==Soup;", Err: qs.ErrInvalidFilter, Offset: 12, Reason: qs.ReasonBadField},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		expr, err := Parse(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			var perr *qs.ParseError
+			if !errors.As(err, &perr) {
+				t.Errorf("Expected *qs.ParseError, got %T", err)
+			} else if perr.Offset != tc.Offset || perr.Reason != tc.Reason {
+				t.Errorf("Expected %s at offset %d, got %s at offset %d", tc.Reason, tc.Offset, perr.Reason, perr.Offset)
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	type TestCase struct {
+		Input  qs.FilterExpr
+		Output string
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: "Bolognese"}, Output: "title==Bolognese"},
+		{Input: qs.Filter{Field: "title", Operator: "neq", Value: "Spaghetti Bolognese"}, Output: `title!="Spaghetti Bolognese"`},
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: "a*b"}, Output: `title=="a\*b"`},
+		{Input: qs.Filter{Field: "title", Operator: "eq", Value: ""}, Output: `title==""`},
+		{Input: qs.Filter{Field: "serves", Operator: "gte", Value: "4"}, Output: "serves=ge=4"},
+		{Input: qs.Filter{Field: "status", Operator: "not in", Value: "draft,in review"}, Output: `status=out=(draft,"in review")`},
+		{Input: qs.Filter{Field: "title", Operator: "like", Value: `%100\%%`}, Output: `title=="*100%*"`},
+		{Input: qs.Filter{Field: "title", Operator: "not like", Value: "a*b%"}, Output: `title!="a\*b*"`},
+		{
+			Input: qs.And{
+				qs.Or{qs.Filter{Field: "status", Operator: "eq", Value: "draft"}, qs.Filter{Field: "author", Operator: "eq", Value: "3"}},
+				qs.Filter{Field: "serves", Operator: "lt", Value: "4"},
+			},
+			Output: "(status==draft,author==3);serves=lt=4",
+		},
+		{
+			Input:  qs.Not{Expr: qs.Or{qs.Filter{Field: "a", Operator: "eq", Value: "1"}, qs.Filter{Field: "b", Operator: "lt", Value: "2"}}},
+			Output: "a!=1;b=ge=2",
+		},
+		{
+			Input: qs.And{
+				qs.Filter{Field: "a", Operator: "in", Value: "1,2"},
+				qs.Not{Expr: qs.And{qs.Filter{Field: "b", Operator: "like", Value: "x%"}, qs.Filter{Field: "c", Operator: "gt", Value: "3"}}},
+			},
+			Output: `a=in=(1,2);(b!="x*",c=le=3)`,
+		},
+		{Input: qs.Filter{Field: "title", Operator: "co", Value: "a*b"}, Output: `title=="*a\*b*"`},
+		{Input: qs.Not{Expr: qs.Filter{Field: "title", Operator: "ew", Value: "pie"}}, Output: `title!="*pie"`},
+		{Input: qs.Filter{Field: "rating", Operator: "pr"}, Err: qs.ErrUnsupportedOperator},
+		{Input: qs.Filter{Field: "title", Operator: "is", Value: "x"}, Err: qs.ErrUnsupportedOperator},
+		{Input: qs.Not{Expr: qs.Filter{Field: "title", Operator: "is", Value: "x"}}, Err: qs.ErrUnsupportedOperator},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v", n, tc.Input)
+
+		s, err := Format(tc.Input)
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if s != tc.Output {
+			t.Errorf("Expected %q, got %q", tc.Output, s)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	inputs := []string{
+		"title==Bolognese;serves=ge=4,author=in=(1,2)",
+		`(status==draft,title=="*pie*");serves=lt=4`,
+		`title!="O'Brien\\\*s"`,
+	}
+
+	for n, input := range inputs {
+		t.Logf("(%d) Testing %q", n, input)
+
+		expr, err := Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := Format(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reparsed, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expr, reparsed) {
+			t.Errorf("Expected %+v, got %+v from %q", expr, reparsed, s)
+		}
+	}
+}
+
+func TestFormatFilters(t *testing.T) {
+	filters := qs.Filters{{Field: "title", Operator: "eq", Value: "Bolognese"}, {Field: "serves", Operator: "gte", Value: "4"}}
+
+	s, err := FormatFilters(filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "title==Bolognese;serves=ge=4" {
+		t.Errorf("Expected %q, got %q", "title==Bolognese;serves=ge=4", s)
+	}
+
+	if s, _ := FormatFilters(nil); s != "" {
+		t.Errorf("Expected empty string, got %q", s)
+	}
+}
+
+func TestReadFilterExpr(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadFiltersOptions
+		Output qs.FilterExpr
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: ""},
+		{Input: "filter=title==Soup", Output: qs.Filter{Field: "title", Operator: "eq", Value: "Soup"}},
+		{
+			Input:  "search=title==Soup&search=serves=gt=2",
+			Opt:    &ReadFiltersOptions{Key: "search"},
+			Output: qs.And{qs.Filter{Field: "title", Operator: "eq", Value: "Soup"}, qs.Filter{Field: "serves", Operator: "gt", Value: "2"}},
+		},
+		{Input: "filter=title==Soup,title==Stew&filter=serves=gt=2", Opt: &ReadFiltersOptions{MaxFilters: 2}, Err: qs.ErrTooManyFilters},
+		{Input: "filter=title", Err: qs.ErrInvalidFilter},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		expr, err := ReadStringFilterExpr(tc.Input, tc.Opt)
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package qs
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Query error.
+var (
+	ErrUnknownField       = errors.New("unknown field")
+	ErrOperatorNotAllowed = errors.New("operator not allowed")
+	ErrInvalidValue       = errors.New("invalid value")
+	ErrSortNotAllowed     = errors.New("sort not allowed")
+	ErrSelectNotAllowed   = errors.New("select not allowed")
+	ErrUnknownJoin        = errors.New("unknown join")
+)
+
+// FieldType describes the type of a field's values.
+type FieldType int
+
+// Field type.
+const (
+	TypeString FieldType = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeText // A string that is analysed for full-text search, such as a description. Values are compared as strings
+)
+
+// Parse converts a filter value to a value of the field type: an int64, float64, bool or string.
+// Values of TypeText fields are returned as strings.
+// This function returns ErrInvalidValue if the value cannot be parsed.
+func (fieldType FieldType) Parse(value string) (any, error) {
+	var v any
+	var err error
+	switch fieldType {
+	case TypeInt:
+		v, err = strconv.ParseInt(value, 10, 64)
+	case TypeFloat:
+		v, err = strconv.ParseFloat(value, 64)
+	case TypeBool:
+		v, err = strconv.ParseBool(value)
+	default:
+		v = value
+	}
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return v, nil
+}
+
+// Field describes a field that can be queried.
+type Field struct {
+	Type      FieldType // Type of the field's values. Filter values are validated against this type.
+	Filter    bool      // If this is true, the field can be filtered
+	Operators []string  // Operators permitted for filtering. If this is empty, all operators are permitted
+	Sort      bool      // If this is true, the field can be sorted
+	Select    bool      // If this is true, the field can be included in a projection
+	Column    string    // Internal column name, if it differs from the field name
+}
+
+// Schema describes the fields and joins that can be queried for an entity.
+// Anything not declared in the schema is rejected.
+type Schema struct {
+	Fields map[string]Field   // Permitted fields.
+	Joins  map[string]*Schema // Permitted joins. The value may be nil, or a schema describing the joined entity.
+}
+
+// AllowsOperator returns true if the field can be filtered using the specified operator.
+func (field Field) AllowsOperator(operator string) bool {
+	if !field.Filter {
+		return false
+	}
+	if len(field.Operators) == 0 {
+		return true
+	}
+	for _, op := range field.Operators {
+		if op == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateValue returns ErrInvalidValue if a filter value is not valid for the field type.
+// Values for in and not in operators are validated individually, while like patterns and values for co, sw and ew operators are not validated.
+func (field Field) ValidateValue(filter Filter) error {
+	values := []string{filter.Value}
+	switch filter.Operator {
+	case "like", "not like", "co", "sw", "ew", "pr":
+		return nil
+	case "in", "not in":
+		values, _ = filter.StringSlice()
+	}
+
+	for _, value := range values {
+		if _, err := field.Type.Parse(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Column returns the internal column name for a field.
+// If the field does not specify a column, the field name is returned.
+func (schema *Schema) Column(name string) string {
+	if field, ok := schema.Fields[name]; ok && field.Column != "" {
+		return field.Column
+	}
+	return name
+}
+
+// Columns returns a map of field names to internal column names, for fields that specify a column.
+func (schema *Schema) Columns() map[string]string {
+	columns := map[string]string{}
+	for name, field := range schema.Fields {
+		if field.Column != "" {
+			columns[name] = field.Column
+		}
+	}
+	return columns
+}
+
+// Types returns a map of field names to value types.
+func (schema *Schema) Types() map[string]FieldType {
+	types := map[string]FieldType{}
+	for name, field := range schema.Fields {
+		types[name] = field.Type
+	}
+	return types
+}
+
+// ValidateFields returns an error if any field in a projection is not permitted by the schema.
+// Fields for joined entities are validated against the schema of the joined entity.
+func (schema *Schema) ValidateFields(fields Fields) error {
+	for _, path := range fields.paths() {
+		entity := schema
+		if path != "" {
+			if err := schema.ValidateJoin(path); err != nil {
+				return err
+			}
+			entity = schema.joinSchema(path)
+		}
+		for _, name := range fields[path] {
+			if err := entity.ValidateSelect(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateSearch returns an error if any field-qualified term in a search is not permitted by the schema.
+// Since terms are matched using like filters, the field must permit the like operator.
+// Terms without a field are not validated.
+func (schema *Schema) ValidateSearch(search *Search) error {
+	if search == nil {
+		return nil
+	}
+	for _, term := range search.Terms {
+		if term.Field == "" {
+			continue
+		}
+		if err := schema.ValidateFilter(Filter{Field: term.Field, Operator: "like", Value: term.Value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateFilter returns an error if a filter is not permitted by the schema.
+func (schema *Schema) ValidateFilter(filter Filter) error {
+	field, ok := schema.Fields[filter.Field]
+	if !ok {
+		return ErrUnknownField
+	}
+	if !field.AllowsOperator(filter.Operator) {
+		return ErrOperatorNotAllowed
+	}
+	return field.ValidateValue(filter)
+}
+
+// ValidateFilterExpr returns an error if any filter in an expression is not permitted by the schema.
+func (schema *Schema) ValidateFilterExpr(expr FilterExpr) error {
+	switch node := expr.(type) {
+	case Filter:
+		return schema.ValidateFilter(node)
+	case And:
+		for _, operand := range node {
+			if err := schema.ValidateFilterExpr(operand); err != nil {
+				return err
+			}
+		}
+	case Or:
+		for _, operand := range node {
+			if err := schema.ValidateFilterExpr(operand); err != nil {
+				return err
+			}
+		}
+	case Not:
+		return schema.ValidateFilterExpr(node.Expr)
+	}
+	return nil
+}
+
+// ValidateJoin returns ErrUnknownJoin if a join is not permitted by the schema.
+// For a nested join path, such as "author.profile", each segment must be a join declared in the schema of its parent.
+func (schema *Schema) ValidateJoin(path string) error {
+	_, err := schema.validateJoin(path)
+	return err
+}
+
+// ValidatePage returns an error if any filter, sort or join in a page is not permitted by the schema.
+// Pages scoped to joins are validated against the schema of the joined entity.
+func (schema *Schema) ValidatePage(page *Page) error {
+	for _, filter := range page.Filters {
+		if err := schema.ValidateFilter(filter); err != nil {
+			return err
+		}
+	}
+
+	if err := schema.ValidateFilterExpr(page.FilterExpr); err != nil {
+		return err
+	}
+
+	for _, sort := range page.Sorts {
+		if err := schema.ValidateSort(sort); err != nil {
+			return err
+		}
+	}
+
+	for name := range page.Joins {
+		if err := schema.ValidateJoin(name); err != nil {
+			return err
+		}
+	}
+
+	if err := schema.ValidateFields(page.Fields); err != nil {
+		return err
+	}
+
+	if err := schema.ValidateSearch(page.Search); err != nil {
+		return err
+	}
+
+	for path, joinPage := range page.JoinPages {
+		if err := schema.joinSchema(path).ValidatePage(joinPage); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateSort returns an error if a sort is not permitted by the schema.
+func (schema *Schema) ValidateSort(sort Sort) error {
+	field, ok := schema.Fields[sort.Field]
+	if !ok {
+		return ErrUnknownField
+	}
+	if !field.Sort {
+		return ErrSortNotAllowed
+	}
+	return nil
+}
+
+// ValidateSelect returns an error if a field cannot be included in a projection.
+func (schema *Schema) ValidateSelect(name string) error {
+	field, ok := schema.Fields[name]
+	if !ok {
+		return ErrUnknownField
+	}
+	if !field.Select {
+		return ErrSelectNotAllowed
+	}
+	return nil
+}
+
+// validateValues validates a page against the schema in the same way as ValidatePage.
+// Each error is returned as a *ParseError locating the problem in the URL values the page was read from.
+func (schema *Schema) validateValues(page *Page, values url.Values, opt *ReadPageOptions) Errors {
+	errs := Errors{}
+	filterOpt := initFiltersOptions(opt.Filter)
+	sources := []filterSource{}
+	for i, value := range values[filterOpt.Key] {
+		sources = append(sources, filterSource{Key: filterOpt.Key, Index: i, Value: value})
+	}
+	sources = append(sources, readStyledFilters(values, filterOpt)...)
+
+	if page.FilterExpr != nil {
+		exprs := []FilterExpr{page.FilterExpr}
+		if len(sources) > 1 {
+			// ReadFilterExpr combines multiple values into an And expression with one operand per value
+			exprs = page.FilterExpr.(And)
+		}
+		for i, expr := range exprs {
+			if err := schema.ValidateFilterExpr(expr); err != nil {
+				errs = append(errs, NewParseError(sources[i].Key, sources[i].Index, sources[i].Value, 0, SchemaReason(err), err))
+			}
+		}
+	} else {
+		for i, filter := range page.Filters {
+			if err := schema.ValidateFilter(filter); err != nil {
+				offset := 0
+				if sources[i].Key == filterOpt.Key {
+					switch err {
+					case ErrOperatorNotAllowed:
+						offset = len(filter.Field) + 1
+					case ErrInvalidValue:
+						offset = len(filter.Field) + len(filter.Operator) + 2
+					}
+				}
+				errs = append(errs, NewParseError(sources[i].Key, sources[i].Index, sources[i].Value, offset, SchemaReason(err), err))
+			}
+		}
+	}
+
+	sortKey := initSortsOptions(opt.Sort).Key
+	for i, sort := range page.Sorts {
+		if err := schema.ValidateSort(sort); err != nil {
+			errs = append(errs, NewParseError(sortKey, i, values[sortKey][i], 0, SchemaReason(err), err))
+		}
+	}
+
+	joinKey := initJoinsOptions(opt.Join).Key
+	if page.Joins != nil {
+		for i, name := range values[joinKey] {
+			if offset, err := schema.validateJoin(name); err != nil {
+				errs = append(errs, NewParseError(joinKey, i, name, offset, SchemaReason(err), err))
+			}
+		}
+	}
+
+	if page.Search != nil {
+		searchKey := initSearchOptions(opt.Search).Key
+		for i, value := range values[searchKey] {
+			// Terms are parsed again to find the value they came from
+			search, _ := ParseSearch(value)
+			if err := schema.ValidateSearch(search); err != nil {
+				errs = append(errs, NewParseError(searchKey, i, value, 0, SchemaReason(err), err))
+			}
+		}
+	}
+
+	fieldsOpt := initFieldsOptions(opt.Fields)
+	for _, path := range page.Fields.paths() {
+		key := fieldsKey(fieldsOpt.Key, path)
+		entity := schema
+		if path != "" {
+			if _, err := schema.validateJoin(path); err != nil {
+				errs = append(errs, NewParseError(key, 0, values.Get(key), 0, SchemaReason(err), err))
+				continue
+			}
+			entity = schema.joinSchema(path)
+		}
+		for i, value := range values[key] {
+			offset := 0
+			for _, name := range strings.Split(value, ",") {
+				if err := entity.ValidateSelect(name); err != nil {
+					errs = append(errs, NewParseError(key, i, value, offset, SchemaReason(err), err))
+				}
+				offset += len(name) + 1
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// joinSchema returns the schema describing the entity at the end of a join path.
+// If the join is not declared or has no schema, it returns an empty schema, which permits nothing.
+func (schema *Schema) joinSchema(path string) *Schema {
+	for _, name := range strings.Split(path, ".") {
+		if schema = schema.Joins[name]; schema == nil {
+			return &Schema{}
+		}
+	}
+	return schema
+}
+
+// validateJoin validates a join path, returning the offset of the first segment that is not permitted.
+func (schema *Schema) validateJoin(path string) (int, error) {
+	offset := 0
+	for _, name := range strings.Split(path, ".") {
+		if schema == nil {
+			return offset, ErrUnknownJoin
+		}
+		join, ok := schema.Joins[name]
+		if !ok {
+			return offset, ErrUnknownJoin
+		}
+		schema = join
+		offset += len(name) + 1
+	}
+	return 0, nil
+}
+
+// SchemaReason returns the reason for a schema validation error, such as ReasonUnknownField for ErrUnknownField.
+// This is exported for the use of readers that validate pages against a schema.
+func SchemaReason(err error) Reason {
+	switch err {
+	case ErrUnknownField:
+		return ReasonUnknownField
+	case ErrOperatorNotAllowed:
+		return ReasonOperatorNotAllowed
+	case ErrInvalidValue:
+		return ReasonInvalidValue
+	case ErrSortNotAllowed:
+		return ReasonSortNotAllowed
+	case ErrSelectNotAllowed:
+		return ReasonSelectNotAllowed
+	case ErrUnknownJoin:
+		return ReasonUnknownJoin
+	}
+	return ""
+}
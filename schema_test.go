@@ -0,0 +1,126 @@
+package qs
+
+import (
+	"errors"
+	"testing"
+)
+
+var testSchema = &Schema{
+	Fields: map[string]Field{
+		"title":  {Filter: true, Operators: []string{"eq", "like"}, Sort: true, Select: true},
+		"serves": {Type: TypeInt, Filter: true, Sort: true, Select: true},
+		"author": {Type: TypeInt, Filter: true},
+		"rating": {Type: TypeFloat, Sort: true, Select: true},
+	},
+	Joins: map[string]*Schema{
+		"author": {
+			Fields: map[string]Field{
+				"title": {Filter: true, Sort: true, Select: true},
+			},
+			Joins: map[string]*Schema{"profile": nil},
+		},
+		"ingredient": nil,
+	},
+}
+
+func TestSchemaValidatePage(t *testing.T) {
+	type TestCase struct {
+		Input string
+		Opt   *ReadPageOptions
+		Err   error
+	}
+
+	testCases := []TestCase{
+		{Input: ""},
+		{Input: "filter=title eq Bolognese&filter=serves gte 4&sort=rating desc&join=author"},
+		{Input: "filter=title like %25soup%25&filter=author in 1,2,3"},
+		{Input: "join=author.profile&join=ingredient"},
+		{Input: "join=author&author.filter=title eq Bolognese&author.sort=title asc"},
+		{Input: "fields=title,rating&fields[author]=title", Opt: &ReadPageOptions{Fields: &ReadFieldsOptions{}}},
+		{Input: "fields=secret"},
+		{Input: "q=pasta title:soup -title:mushroom", Opt: &ReadPageOptions{Search: &ReadSearchOptions{}}},
+		{Input: "q=secret:x"},
+		{
+			Input: "filter=title eq Bolognese or serves gte 4",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+		},
+
+		{Input: "filter=secret eq 1", Err: ErrUnknownField},
+		{Input: "filter=title neq Bolognese", Err: ErrOperatorNotAllowed},
+		{Input: "filter=rating gt 4", Err: ErrOperatorNotAllowed},
+		{Input: "filter=serves gte four", Err: ErrInvalidValue},
+		{Input: "filter=author in 1,two", Err: ErrInvalidValue},
+		{Input: "sort=author asc", Err: ErrSortNotAllowed},
+		{Input: "sort=secret asc", Err: ErrUnknownField},
+		{Input: "join=secret", Err: ErrUnknownJoin},
+		{Input: "join=author.secret", Err: ErrUnknownJoin},
+		{Input: "join=ingredient.supplier", Err: ErrUnknownJoin},
+		{Input: "join=author&author.filter=secret eq 1", Err: ErrUnknownField},
+		{Input: "join=ingredient&ingredient.sort=title asc", Err: ErrUnknownField},
+		{Input: "fields=title,author", Opt: &ReadPageOptions{Fields: &ReadFieldsOptions{}}, Err: ErrSelectNotAllowed},
+		{Input: "fields=secret", Opt: &ReadPageOptions{Fields: &ReadFieldsOptions{}}, Err: ErrUnknownField},
+		{Input: "q=pasta rating:4", Opt: &ReadPageOptions{Search: &ReadSearchOptions{}}, Err: ErrOperatorNotAllowed},
+		{Input: "q=secret:x", Opt: &ReadPageOptions{Search: &ReadSearchOptions{}}, Err: ErrUnknownField},
+		{Input: "fields[secret]=title", Opt: &ReadPageOptions{Fields: &ReadFieldsOptions{}}, Err: ErrUnknownJoin},
+		{Input: "fields[author.profile]=name", Opt: &ReadPageOptions{Fields: &ReadFieldsOptions{}}, Err: ErrUnknownField},
+		{
+			Input: "filter=title eq Bolognese or not secret eq 1",
+			Opt:   &ReadPageOptions{Filter: &ReadFiltersOptions{Expr: true}},
+			Err:   ErrUnknownField,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		opt := tc.Opt
+		if opt == nil {
+			opt = &ReadPageOptions{}
+		}
+		opt.Schema = testSchema
+
+		page, err := ReadStringPage(tc.Input, opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			if page != nil {
+				t.Error("Expected nil page")
+			}
+			continue
+		}
+	}
+}
+
+func TestFieldTypeParse(t *testing.T) {
+	type TestCase struct {
+		Type   FieldType
+		Input  string
+		Output any
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Type: TypeString, Input: "4", Output: "4"},
+		{Type: TypeInt, Input: "-4", Output: int64(-4)},
+		{Type: TypeFloat, Input: "4.5", Output: 4.5},
+		{Type: TypeBool, Input: "true", Output: true},
+		{Type: TypeInt, Input: "4.5", Err: ErrInvalidValue},
+		{Type: TypeFloat, Input: "four", Err: ErrInvalidValue},
+		{Type: TypeBool, Input: "yes", Err: ErrInvalidValue},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with type %d", n, tc.Input, tc.Type)
+
+		value, err := tc.Type.Parse(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if value != tc.Output {
+			t.Errorf("Expected %#v, got %#v", tc.Output, value)
+		}
+	}
+}
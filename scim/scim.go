@@ -0,0 +1,679 @@
+// Package scim reads and writes SCIM (RFC 7644) query parameters, so that handlers can serve SCIM provisioning clients without depending on the query string format.
+//
+// The following query parameters are supported:
+//
+//	filter=userName eq "bjensen" and title pr    Read into Page.FilterExpr, and Page.Filters if it is a simple conjunction
+//	sortBy=userName&sortOrder=descending         Read into Page.Sorts
+//	startIndex=11&count=10                       Read into Page.Pagination. startIndex is 1-based, and count=0 requests only a count (see CountOnly)
+//
+// Errors are reported as a *qs.ParseError wrapping the equivalent qs query error, such as qs.ErrInvalidFilter.
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// Query string key.
+const (
+	FilterKey     = "filter"
+	SortByKey     = "sortBy"
+	SortOrderKey  = "sortOrder"
+	StartIndexKey = "startIndex"
+	CountKey      = "count"
+)
+
+// operators maps SCIM attribute operators to qs filter operators.
+var operators = map[string]string{
+	"eq": "eq",
+	"ne": "neq",
+	"co": "co",
+	"sw": "sw",
+	"ew": "ew",
+	"gt": "gt",
+	"ge": "gte",
+	"lt": "lt",
+	"le": "lte",
+	"pr": "pr",
+}
+
+// formatOperators maps qs filter operators to SCIM attribute operators.
+var formatOperators = map[string]string{
+	"eq":  "eq",
+	"neq": "ne",
+	"co":  "co",
+	"sw":  "sw",
+	"ew":  "ew",
+	"gt":  "gt",
+	"gte": "ge",
+	"lt":  "lt",
+	"lte": "le",
+	"pr":  "pr",
+}
+
+// ReadPageOptions configures the behaviour of ReadPage.
+type ReadPageOptions struct {
+	Attributes   map[string]string // Maps SCIM attribute paths, such as name.familyName, to field names. Matching is case-insensitive. Attributes that are not mapped are used as-is
+	DefaultCount int               // Page size used if count is not provided
+	MaxCount     int               // If this is > 0, count is clamped to this maximum value
+	MaxFilters   int               // If this is > 0, a maximum number of comparisons is imposed
+
+	Schema        *qs.Schema // If set, the page is validated against this schema.
+	CollectErrors bool       // If true, all errors are returned together as qs.Errors instead of only the first error.
+}
+
+// ReadPage parses URL values containing SCIM query parameters into a qs.Page.
+// Pagination is always set, even if neither startIndex nor count is provided.
+//
+// As specified by RFC 7644, a startIndex less than 1 is read as 1, and sortOrder is ignored unless sortBy is provided.
+// A count of 0 requests only the total number of results, and negative counts are read as 0.
+// Since a zero limit means no limit in qs, the page's limit is then 0; use CountOnly to find whether results should be returned at all.
+func ReadPage(values url.Values, opt *ReadPageOptions) (*qs.Page, error) {
+	if opt == nil {
+		opt = &ReadPageOptions{}
+	}
+
+	page := &qs.Page{Pagination: &qs.Pagination{Limit: opt.DefaultCount}}
+	errs := qs.Errors{}
+
+	if values.Has(StartIndexKey) {
+		start, err := strconv.Atoi(values.Get(StartIndexKey))
+		if err != nil {
+			errs = append(errs, qs.NewParseError(StartIndexKey, 0, values.Get(StartIndexKey), 0, qs.ReasonBadNumber, qs.ErrInvalidOffset))
+		} else if start > 1 {
+			page.Pagination.Offset = start - 1
+		}
+	}
+
+	if values.Has(CountKey) {
+		count, err := strconv.Atoi(values.Get(CountKey))
+		if err != nil {
+			errs = append(errs, qs.NewParseError(CountKey, 0, values.Get(CountKey), 0, qs.ReasonBadNumber, qs.ErrInvalidLimit))
+		} else if count > 0 {
+			page.Pagination.Limit = count
+		} else {
+			page.Pagination.Limit = 0
+		}
+	}
+	if opt.MaxCount > 0 && page.Pagination.Limit > opt.MaxCount {
+		page.Pagination.Limit = opt.MaxCount
+	}
+
+	and := qs.And{}
+	count := 0
+	for i, s := range values[FilterKey] {
+		expr, err := parseFilter(s, opt.Attributes)
+		if err != nil {
+			errs = append(errs, qs.WithKey(err, FilterKey, i))
+			continue
+		}
+		count += qs.CountFilters(expr)
+		if opt.MaxFilters > 0 && count > opt.MaxFilters {
+			errs = append(errs, qs.NewParseError(FilterKey, i, s, 0, qs.ReasonTooMany, qs.ErrTooManyFilters))
+			break
+		}
+		and = append(and, expr)
+	}
+	if len(and) == 1 {
+		page.FilterExpr = and[0]
+	} else if len(and) > 1 {
+		page.FilterExpr = and
+	}
+	// Flat filters are only provided if the expression is a simple conjunction
+	page.Filters, _ = qs.FlattenFilters(page.FilterExpr)
+
+	if values.Has(SortByKey) {
+		sort, err := readSort(values, opt.Attributes)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			page.Sorts = qs.Sorts{sort}
+		}
+	}
+
+	if len(errs) > 0 && !opt.CollectErrors {
+		return nil, errs[0]
+	}
+
+	if opt.Schema != nil && len(errs) == 0 {
+		if err := opt.Schema.ValidatePage(page); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return page, nil
+	}
+	if !opt.CollectErrors {
+		return nil, errs[0]
+	}
+	return nil, errs
+}
+
+// CountOnly returns true if URL values request only the total number of results, without any resources.
+// As specified by RFC 7644, this is the case if count is 0 or negative.
+// The handler should then respond with totalResults alone, rather than querying the page it read, which has no limit.
+func CountOnly(values url.Values) bool {
+	count, err := strconv.Atoi(values.Get(CountKey))
+	return err == nil && count < 1
+}
+
+// ReadRequestPage parses a request's query string containing SCIM query parameters into a qs.Page.
+func ReadRequestPage(req *http.Request, opt *ReadPageOptions) (*qs.Page, error) {
+	return ReadPage(req.URL.Query(), opt)
+}
+
+// ReadStringPage parses a query string literal containing SCIM query parameters into a qs.Page.
+func ReadStringPage(s string, opt *ReadPageOptions) (*qs.Page, error) {
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPage(values, opt)
+}
+
+// Values returns a page as URL values containing SCIM query parameters.
+// The filter expression is written in preference to flat filters, and the offset is written as a 1-based startIndex.
+// Zero values are omitted. Joins, fields and search are not written.
+//
+// Fields are written as the attribute paths they are mapped from in opt.Attributes, so that the values can be read using the same options.
+// If several attributes map to a field, the first in alphabetical order is written.
+//
+// SCIM only supports sorting by a single attribute, so this function returns qs.ErrTooManySorts if the page has more than one sort.
+func Values(page *qs.Page, opt *ReadPageOptions) (url.Values, error) {
+	if opt == nil {
+		opt = &ReadPageOptions{}
+	}
+
+	values := url.Values{}
+
+	expr := page.FilterExpr
+	if expr == nil {
+		expr = page.Filters.Expr()
+	}
+	if expr != nil {
+		filter, err := Format(attributeExpr(opt.Attributes, expr))
+		if err != nil {
+			return nil, err
+		}
+		values.Set(FilterKey, filter)
+	}
+
+	if len(page.Sorts) > 1 {
+		return nil, qs.ErrTooManySorts
+	}
+	if len(page.Sorts) == 1 {
+		values.Set(SortByKey, attributePath(opt.Attributes, page.Sorts[0].Field))
+		if page.Sorts[0].Direction == "desc" {
+			values.Set(SortOrderKey, "descending")
+		}
+	}
+
+	if page.Pagination != nil {
+		if page.Pagination.Offset > 0 {
+			values.Set(StartIndexKey, strconv.Itoa(page.Pagination.Offset+1))
+		}
+		if page.Pagination.Limit > 0 {
+			values.Set(CountKey, strconv.Itoa(page.Pagination.Limit))
+		}
+	}
+
+	return values, nil
+}
+
+// ParseFilter parses a SCIM filter expression, such as:
+//
+//	userName eq "bjensen" and (emails co "example.com" or title pr)
+//
+// Attribute operators map to the qs operators of the same name, except ne, ge and le, which map to neq, gte and lte.
+// Filters are combined with and, or and not, and grouped with parentheses. not must be followed by a parenthesised filter.
+// Operators and logical operators are case-insensitive.
+//
+// Values may be JSON strings, numbers, true or false. null and complex attribute filters, such as emails[type eq "work"], are not supported.
+// Attribute paths, such as name.familyName, must be mapped to fields (see ReadPageOptions); otherwise, they are reported as invalid fields.
+//
+// If the expression is invalid, this function returns a *qs.ParseError wrapping qs.ErrInvalidFilter.
+func ParseFilter(s string) (qs.FilterExpr, error) {
+	return parseFilter(s, nil)
+}
+
+// Format returns a filter expression as a SCIM filter.
+//
+// in and not in filters are written as a group of eq comparisons, and like and not like filters are written using eq, co, sw or ew where possible.
+// String values are quoted, while numbers and booleans are written as-is.
+// If the expression uses an operator or like pattern that cannot be written, this function returns qs.ErrUnsupportedOperator.
+func Format(expr qs.FilterExpr) (string, error) {
+	switch node := expr.(type) {
+	case qs.Filter:
+		return formatFilter(node)
+	case qs.And:
+		return formatJunction(node, " and ")
+	case qs.Or:
+		return formatJunction(node, " or ")
+	case qs.Not:
+		s, err := Format(node.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "not (" + s + ")", nil
+	}
+	return "", qs.ErrUnsupportedExpr
+}
+
+func parseFilter(s string, attributes map[string]string) (qs.FilterExpr, error) {
+	p := &parser{input: s, attributes: attributes}
+	p.skipSpace()
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(s) {
+		return nil, p.errorAt(p.pos, qs.ReasonUnexpectedToken)
+	}
+	return expr, nil
+}
+
+type parser struct {
+	input      string
+	pos        int
+	attributes map[string]string
+}
+
+func (p *parser) errorAt(offset int, reason qs.Reason) error {
+	return qs.NewParseError("", 0, p.input, offset, reason, qs.ErrInvalidFilter)
+}
+
+// keyword advances past a case-insensitive keyword, such as and, if it is next in the input and followed by whitespace or a parenthesis.
+func (p *parser) keyword(word string) bool {
+	end := p.pos + len(word)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], word) {
+		return false
+	}
+	if end < len(p.input) && !isSpace(p.input[end]) && p.input[end] != '(' {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// skip advances past a character, if it is next in the input.
+func (p *parser) skip(c byte) bool {
+	if p.pos < len(p.input) && p.input[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && isSpace(p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) parseOr() (qs.FilterExpr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	or := qs.Or{expr}
+	for {
+		pos := p.pos
+		p.skipSpace()
+		if !p.keyword("or") {
+			p.pos = pos
+			break
+		}
+		p.skipSpace()
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (qs.FilterExpr, error) {
+	expr, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	and := qs.And{expr}
+	for {
+		pos := p.pos
+		p.skipSpace()
+		if !p.keyword("and") {
+			p.pos = pos
+			break
+		}
+		p.skipSpace()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseNot() (qs.FilterExpr, error) {
+	// "not" is an attribute name unless it is followed by a parenthesis
+	pos := p.pos
+	if p.keyword("not") {
+		p.skipSpace()
+		if p.pos < len(p.input) && p.input[p.pos] == '(' {
+			expr, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			return qs.Not{Expr: expr}, nil
+		}
+		p.pos = pos
+	}
+
+	if p.pos < len(p.input) && p.input[p.pos] == '(' {
+		return p.parseGroup()
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseGroup() (qs.FilterExpr, error) {
+	open := p.pos
+	p.pos++
+	p.skipSpace()
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.skip(')') {
+		return nil, p.errorAt(open, qs.ReasonUnclosedGroup)
+	}
+	return expr, nil
+}
+
+func (p *parser) parseComparison() (qs.FilterExpr, error) {
+	start := p.pos
+	n := scanAttribute(p.input[p.pos:])
+	field := attribute(p.attributes, p.input[p.pos:p.pos+n])
+	if !qs.IsField(field) {
+		return nil, p.errorAt(start, qs.ReasonBadField)
+	}
+	p.pos += n
+	if p.pos < len(p.input) && p.input[p.pos] == '[' {
+		// Complex attribute filters cannot be represented as a qs filter
+		return nil, p.errorAt(p.pos, qs.ReasonUnexpectedToken)
+	}
+	p.skipSpace()
+
+	opStart := p.pos
+	word := scanWord(p.input[p.pos:])
+	if word == 0 {
+		return nil, p.errorAt(opStart, qs.ReasonMissingOperator)
+	}
+	operator, ok := operators[strings.ToLower(p.input[p.pos:p.pos+word])]
+	if !ok {
+		return nil, p.errorAt(opStart, qs.ReasonUnknownOperator)
+	}
+	p.pos += word
+	if operator == "pr" {
+		return qs.Filter{Field: field, Operator: operator}, nil
+	}
+
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return qs.Filter{Field: field, Operator: operator, Value: value}, nil
+}
+
+// parseValue parses a JSON string, number or boolean.
+// Strings are returned without quotes; other values are returned as written, except that booleans are lower-cased.
+func (p *parser) parseValue() (string, error) {
+	start := p.pos
+	if p.skip('"') {
+		for p.pos < len(p.input) && p.input[p.pos] != '"' {
+			if p.input[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if !p.skip('"') {
+			return "", p.errorAt(start, qs.ReasonUnterminatedQuote)
+		}
+		value := ""
+		if err := json.Unmarshal([]byte(p.input[start:p.pos]), &value); err != nil {
+			return "", p.errorAt(start, qs.ReasonInvalidValue)
+		}
+		return value, nil
+	}
+
+	n := scanWord(p.input[p.pos:])
+	if n == 0 {
+		return "", p.errorAt(start, qs.ReasonMissingValue)
+	}
+	value := p.input[p.pos : p.pos+n]
+	switch {
+	case strings.EqualFold(value, "true") || strings.EqualFold(value, "false"):
+		value = strings.ToLower(value)
+	case strings.EqualFold(value, "null"):
+		// Null comparisons cannot be represented as a qs filter; pr can be used instead
+		return "", p.errorAt(start, qs.ReasonInvalidValue)
+	case !isNumber(value):
+		return "", p.errorAt(start, qs.ReasonUnexpectedToken)
+	}
+	p.pos += n
+	return value, nil
+}
+
+// formatJunction writes the operands of an And or Or, joined with a separator.
+// Or operands of an And are grouped with parentheses, since and binds more tightly than or.
+func formatJunction(operands []qs.FilterExpr, sep string) (string, error) {
+	if len(operands) == 0 {
+		// An empty junction is always true or false, which cannot be written
+		return "", qs.ErrUnsupportedExpr
+	}
+
+	strs := []string{}
+	for _, operand := range operands {
+		s, err := Format(operand)
+		if err != nil {
+			return "", err
+		}
+		if or, ok := operand.(qs.Or); ok && sep == " and " && len(or) > 1 {
+			s = "(" + s + ")"
+		}
+		strs = append(strs, s)
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func formatFilter(filter qs.Filter) (string, error) {
+	switch filter.Operator {
+	case "in", "not in":
+		values, _ := filter.StringSlice()
+		for i, value := range values {
+			values[i] = filter.Field + " eq " + formatValue(value)
+		}
+		s := strings.Join(values, " or ")
+		if filter.Operator == "not in" {
+			return "not (" + s + ")", nil
+		}
+		if len(values) > 1 {
+			return "(" + s + ")", nil
+		}
+		return s, nil
+	case "like", "not like":
+		op, value, ok := likeOperator(filter.LikeSegments())
+		if !ok {
+			return "", qs.ErrUnsupportedOperator
+		}
+		s := filter.Field + " " + op + " " + formatValue(value)
+		if filter.Operator == "not like" {
+			return "not (" + s + ")", nil
+		}
+		return s, nil
+	}
+
+	op, ok := formatOperators[filter.Operator]
+	if !ok {
+		return "", qs.ErrUnsupportedOperator
+	}
+	if op == "pr" {
+		return filter.Field + " pr", nil
+	}
+	return filter.Field + " " + op + " " + formatValue(filter.Value), nil
+}
+
+// likeOperator returns the SCIM operator and value equivalent to the segments of a like pattern.
+// This function returns false if the pattern has wildcards other than at its start and end.
+func likeOperator(segments []string) (string, string, bool) {
+	switch {
+	case len(segments) == 1:
+		return "eq", segments[0], true
+	case len(segments) == 2 && segments[0] == "":
+		return "ew", segments[1], true
+	case len(segments) == 2 && segments[1] == "":
+		return "sw", segments[0], true
+	case len(segments) == 3 && segments[0] == "" && segments[2] == "":
+		return "co", segments[1], true
+	}
+	return "", "", false
+}
+
+// formatValue writes a filter value as a JSON string, unless it is a number or boolean.
+func formatValue(value string) string {
+	if value == "true" || value == "false" || isNumber(value) {
+		return value
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// attribute returns the field name for a SCIM attribute path.
+func attribute(attributes map[string]string, name string) string {
+	if mapped, ok := attributes[name]; ok {
+		return mapped
+	}
+	for path, mapped := range attributes {
+		if strings.EqualFold(path, name) {
+			return mapped
+		}
+	}
+	return name
+}
+
+// attributeExpr returns a filter expression with each field replaced by its SCIM attribute path (see attributePath).
+func attributeExpr(attributes map[string]string, expr qs.FilterExpr) qs.FilterExpr {
+	if len(attributes) == 0 {
+		return expr
+	}
+	switch node := expr.(type) {
+	case qs.Filter:
+		node.Field = attributePath(attributes, node.Field)
+		return node
+	case qs.And:
+		and := make(qs.And, len(node))
+		for i, operand := range node {
+			and[i] = attributeExpr(attributes, operand)
+		}
+		return and
+	case qs.Or:
+		or := make(qs.Or, len(node))
+		for i, operand := range node {
+			or[i] = attributeExpr(attributes, operand)
+		}
+		return or
+	case qs.Not:
+		return qs.Not{Expr: attributeExpr(attributes, node.Expr)}
+	}
+	return expr
+}
+
+// attributePath returns the SCIM attribute path for a field name, reversing attribute.
+// If several attributes are mapped to the field, the first in alphabetical order is returned.
+func attributePath(attributes map[string]string, field string) string {
+	path := ""
+	for name, mapped := range attributes {
+		if mapped == field && (path == "" || name < path) {
+			path = name
+		}
+	}
+	if path == "" {
+		return field
+	}
+	return path
+}
+
+// isNumber returns true if s is a JSON number.
+func isNumber(s string) bool {
+	if s == "" || !strings.ContainsAny(s[:1], "-0123456789") {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && json.Valid([]byte(s))
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// readSort reads sortBy and sortOrder into a sort.
+func readSort(values url.Values, attributes map[string]string) (qs.Sort, error) {
+	sortBy := values.Get(SortByKey)
+	sort := qs.Sort{Field: attribute(attributes, sortBy), Direction: "asc"}
+	if !qs.IsField(sort.Field) {
+		return sort, qs.NewParseError(SortByKey, 0, sortBy, 0, qs.ReasonBadField, qs.ErrInvalidSort)
+	}
+
+	if values.Has(SortOrderKey) {
+		switch order := values.Get(SortOrderKey); strings.ToLower(order) {
+		case "ascending":
+		case "descending":
+			sort.Direction = "desc"
+		default:
+			return sort, qs.NewParseError(SortOrderKey, 0, order, 0, qs.ReasonBadDirection, qs.ErrInvalidSort)
+		}
+	}
+	return sort, nil
+}
+
+// scanAttribute returns the length of the attribute path at the start of a string, including any URN prefix and sub-attribute.
+func scanAttribute(s string) int {
+	n := 0
+	for n < len(s) && (s[n] >= 'A' && s[n] <= 'Z' || s[n] >= 'a' && s[n] <= 'z' || s[n] >= '0' && s[n] <= '9' || strings.IndexByte("_-$.:", s[n]) >= 0) {
+		n++
+	}
+	return n
+}
+
+// scanWord returns the length of the unquoted word at the start of a string, which ends at whitespace or a parenthesis.
+func scanWord(s string) int {
+	n := 0
+	for n < len(s) && !isSpace(s[n]) && s[n] != '(' && s[n] != ')' {
+		n++
+	}
+	return n
+}
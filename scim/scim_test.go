@@ -0,0 +1,315 @@
+package scim
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestReadPage(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadPageOptions
+		Output *qs.Page
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{
+			Input: "filter=" + url.QueryEscape(`userName eq "bjensen" and title pr`) + "&sortBy=userName&sortOrder=descending&startIndex=11&count=10",
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{Limit: 10, Offset: 10},
+				Filters:    qs.Filters{{Field: "userName", Operator: "eq", Value: "bjensen"}, {Field: "title", Operator: "pr"}},
+				FilterExpr: qs.And{
+					qs.Filter{Field: "userName", Operator: "eq", Value: "bjensen"},
+					qs.Filter{Field: "title", Operator: "pr"},
+				},
+				Sorts: qs.Sorts{{Field: "userName", Direction: "desc"}},
+			},
+		},
+		{
+			Input: "filter=" + url.QueryEscape(`name.familyName sw "J" and meta.lastModified gt "2011-05-13T04:42:34Z"`) + "&sortBy=Meta.LastModified",
+			Opt:   &ReadPageOptions{Attributes: map[string]string{"name.familyName": "familyName", "meta.lastModified": "modified"}},
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				Filters:    qs.Filters{{Field: "familyName", Operator: "sw", Value: "J"}, {Field: "modified", Operator: "gt", Value: "2011-05-13T04:42:34Z"}},
+				FilterExpr: qs.And{
+					qs.Filter{Field: "familyName", Operator: "sw", Value: "J"},
+					qs.Filter{Field: "modified", Operator: "gt", Value: "2011-05-13T04:42:34Z"},
+				},
+				Sorts: qs.Sorts{{Field: "modified", Direction: "asc"}},
+			},
+		},
+		{
+			Input: "filter=" + url.QueryEscape(`emails co "example.com" or title pr`),
+			Output: &qs.Page{
+				Pagination: &qs.Pagination{},
+				FilterExpr: qs.Or{
+					qs.Filter{Field: "emails", Operator: "co", Value: "example.com"},
+					qs.Filter{Field: "title", Operator: "pr"},
+				},
+			},
+		},
+		{Input: "startIndex=0&count=500", Opt: &ReadPageOptions{MaxCount: 100}, Output: &qs.Page{Pagination: &qs.Pagination{Limit: 100}}},
+		{Input: "startIndex=1", Opt: &ReadPageOptions{DefaultCount: 20}, Output: &qs.Page{Pagination: &qs.Pagination{Limit: 20}}},
+		{Input: "sortOrder=descending", Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{Input: "count=0", Opt: &ReadPageOptions{DefaultCount: 20}, Output: &qs.Page{Pagination: &qs.Pagination{}}},
+		{Input: "startIndex=11&count=-5", Output: &qs.Page{Pagination: &qs.Pagination{Offset: 10}}},
+		{Input: "filter=" + url.QueryEscape(`a eq 1 and (b eq 2 or c eq 3)`), Opt: &ReadPageOptions{MaxFilters: 2}, Err: qs.ErrTooManyFilters},
+		{Input: "filter=" + url.QueryEscape(`emails[type eq "work"]`), Err: qs.ErrInvalidFilter},
+		{Input: "filter=" + url.QueryEscape(`title eq null`), Err: qs.ErrInvalidFilter},
+		{Input: "sortBy=name.familyName", Err: qs.ErrInvalidSort},
+		{Input: "sortBy=userName&sortOrder=up", Err: qs.ErrInvalidSort},
+		{Input: "startIndex=x", Err: qs.ErrInvalidOffset},
+		{Input: "count=ten", Err: qs.ErrInvalidLimit},
+		{
+			Input: "sortBy=rating",
+			Opt:   &ReadPageOptions{Schema: &qs.Schema{Fields: map[string]qs.Field{"rating": {Filter: true}}}},
+			Err:   qs.ErrSortNotAllowed,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := ReadStringPage(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if !reflect.DeepEqual(page, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, page)
+		}
+	}
+}
+
+func TestReadPageErrors(t *testing.T) {
+	_, err := ReadStringPage("filter=title+xx+1&sortBy=title&sortOrder=up&count=ten", &ReadPageOptions{CollectErrors: true})
+
+	errs := qs.Errors{}
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected qs.Errors, got %v", err)
+	}
+
+	expected := []*qs.ParseError{
+		{Key: "count", Input: "ten", Reason: qs.ReasonBadNumber, Err: qs.ErrInvalidLimit},
+		{Key: "filter", Input: "title xx 1", Offset: 6, Reason: qs.ReasonUnknownOperator, Err: qs.ErrInvalidFilter},
+		{Key: "sortOrder", Input: "up", Reason: qs.ReasonBadDirection, Err: qs.ErrInvalidSort},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, err := range errs {
+		if !reflect.DeepEqual(err, expected[i]) {
+			t.Errorf("Expected %+v, got %+v", expected[i], err)
+		}
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output bool
+	}
+
+	testCases := []TestCase{
+		{Input: "", Output: false},
+		{Input: "count=10", Output: false},
+		{Input: "count=ten", Output: false},
+		{Input: "count=0", Output: true},
+		{Input: "startIndex=11&count=-5", Output: true},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		values, _ := url.ParseQuery(tc.Input)
+		if CountOnly(values) != tc.Output {
+			t.Errorf("Expected %v, got %v", tc.Output, !tc.Output)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output qs.FilterExpr
+		Err    error
+		Reason qs.Reason
+	}
+
+	testCases := []TestCase{
+		{Input: `userName eq "bjensen"`, Output: qs.Filter{Field: "userName", Operator: "eq", Value: "bjensen"}},
+		{Input: `userName Eq "b \"j\" é"`, Output: qs.Filter{Field: "userName", Operator: "eq", Value: `b "j" é`}},
+		{
+			Input: `userName eq "bjensen" and (emails co "example.com" or title pr)`,
+			Output: qs.And{
+				qs.Filter{Field: "userName", Operator: "eq", Value: "bjensen"},
+				qs.Or{
+					qs.Filter{Field: "emails", Operator: "co", Value: "example.com"},
+					qs.Filter{Field: "title", Operator: "pr"},
+				},
+			},
+		},
+		{
+			Input: `a ne 1 OR b ge -2.5 AND c le 3e2`,
+			Output: qs.Or{
+				qs.Filter{Field: "a", Operator: "neq", Value: "1"},
+				qs.And{
+					qs.Filter{Field: "b", Operator: "gte", Value: "-2.5"},
+					qs.Filter{Field: "c", Operator: "lte", Value: "3e2"},
+				},
+			},
+		},
+		{
+			Input: `not (active eq TRUE) and title ew "pie"`,
+			Output: qs.And{
+				qs.Not{Expr: qs.Filter{Field: "active", Operator: "eq", Value: "true"}},
+				qs.Filter{Field: "title", Operator: "ew", Value: "pie"},
+			},
+		},
+		{Input: `not eq false`, Output: qs.Filter{Field: "not", Operator: "eq", Value: "false"}},
+		{Input: `title`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonMissingOperator},
+		{Input: `title is "x"`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonUnknownOperator},
+		{Input: `title eq`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonMissingValue},
+		{Input: `title eq "x`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonUnterminatedQuote},
+		{Input: `title eq x`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonUnexpectedToken},
+		{Input: `title eq null`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonInvalidValue},
+		{Input: `(title pr`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonUnclosedGroup},
+		{Input: `title pr)`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonUnexpectedToken},
+		{Input: `name.familyName pr`, Err: qs.ErrInvalidFilter, Reason: qs.ReasonBadField},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		expr, err := ParseFilter(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if tc.Err != nil {
+			perr := &qs.ParseError{}
+			if !errors.As(err, &perr) {
+				t.Errorf("Expected *qs.ParseError, got %v", err)
+			} else if perr.Reason != tc.Reason {
+				t.Errorf("Expected reason %q, got %q", tc.Reason, perr.Reason)
+			}
+		}
+
+		if !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	type TestCase struct {
+		Input  qs.FilterExpr
+		Output string
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: qs.Filter{Field: "userName", Operator: "eq", Value: "bjensen"}, Output: `userName eq "bjensen"`},
+		{Input: qs.Filter{Field: "title", Operator: "pr"}, Output: `title pr`},
+		{Input: qs.Filter{Field: "serves", Operator: "gte", Value: "4"}, Output: `serves ge 4`},
+		{Input: qs.Filter{Field: "title", Operator: "neq", Value: `a "b" <c>`}, Output: `title ne "a \"b\" <c>"`},
+		{Input: qs.Filter{Field: "status", Operator: "in", Value: "draft,live"}, Output: `(status eq "draft" or status eq "live")`},
+		{Input: qs.Filter{Field: "status", Operator: "in", Value: "draft"}, Output: `status eq "draft"`},
+		{Input: qs.Filter{Field: "status", Operator: "not in", Value: "draft,live"}, Output: `not (status eq "draft" or status eq "live")`},
+		{Input: qs.Filter{Field: "title", Operator: "like", Value: "%pie%"}, Output: `title co "pie"`},
+		{Input: qs.Filter{Field: "title", Operator: "like", Value: "Spag%"}, Output: `title sw "Spag"`},
+		{Input: qs.Filter{Field: "title", Operator: "not like", Value: `%100\%`}, Output: `not (title ew "100%")`},
+		{Input: qs.Filter{Field: "title", Operator: "like", Value: "a%b"}, Err: qs.ErrUnsupportedOperator},
+		{
+			Input: qs.And{
+				qs.Filter{Field: "active", Operator: "eq", Value: "true"},
+				qs.Or{qs.Filter{Field: "a", Operator: "lt", Value: "1"}, qs.Filter{Field: "b", Operator: "lte", Value: "2"}},
+				qs.Not{Expr: qs.Filter{Field: "c", Operator: "ew", Value: "x"}},
+			},
+			Output: `active eq true and (a lt 1 or b le 2) and not (c ew "x")`,
+		},
+		{Input: qs.Or{}, Err: qs.ErrUnsupportedExpr},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v", n, tc.Input)
+
+		s, err := Format(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if s != tc.Output {
+			t.Errorf("Expected %q, got %q", tc.Output, s)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	type TestCase struct {
+		Input  *qs.Page
+		Opt    *ReadPageOptions
+		Output string
+		Err    error
+	}
+
+	attributes := &ReadPageOptions{Attributes: map[string]string{"name.familyName": "familyName", "name.givenName": "givenName", "surname": "familyName"}}
+
+	testCases := []TestCase{
+		{Input: &qs.Page{}, Output: ""},
+		{
+			Input: &qs.Page{
+				Pagination: &qs.Pagination{Limit: 10, Offset: 20},
+				Filters:    qs.Filters{{Field: "title", Operator: "like", Value: "%pie%"}, {Field: "serves", Operator: "gte", Value: "4"}},
+				Sorts:      qs.Sorts{{Field: "serves", Direction: "desc"}},
+			},
+			Output: "count=10&filter=title+co+%22pie%22+and+serves+ge+4&sortBy=serves&sortOrder=descending&startIndex=21",
+		},
+		{
+			Input: &qs.Page{
+				FilterExpr: qs.Or{
+					qs.Filter{Field: "familyName", Operator: "eq", Value: "Jensen"},
+					qs.Not{Expr: qs.Filter{Field: "givenName", Operator: "pr"}},
+				},
+				Sorts: qs.Sorts{{Field: "familyName", Direction: "asc"}},
+			},
+			Opt:    attributes,
+			Output: "filter=name.familyName+eq+%22Jensen%22+or+not+%28name.givenName+pr%29&sortBy=name.familyName",
+		},
+		{Input: &qs.Page{Sorts: qs.Sorts{{Field: "a", Direction: "asc"}, {Field: "b", Direction: "asc"}}}, Err: qs.ErrTooManySorts},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %+v with options %+v", n, tc.Input, tc.Opt)
+
+		values, err := Values(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+
+		if err != nil {
+			continue
+		}
+		if values.Encode() != tc.Output {
+			t.Errorf("Expected %q, got %q", tc.Output, values.Encode())
+		}
+
+		// The values must read back as the same page
+		read, err := ReadPage(values, tc.Opt)
+		if err != nil {
+			t.Errorf("Expected no error reading back, got %v", err)
+		} else if values2, _ := Values(read, tc.Opt); values2.Encode() != tc.Output {
+			t.Errorf("Expected %q after reading back, got %q", tc.Output, values2.Encode())
+		}
+	}
+}
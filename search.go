@@ -0,0 +1,268 @@
+package qs
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Query error.
+var (
+	ErrInvalidSearch = errors.New("invalid search")
+	ErrTooManyTerms  = errors.New("too many search terms")
+
+	// Returned by backends that cannot express a search, such as a term without a field when no search fields are configured.
+	ErrUnsupportedSearch = errors.New("unsupported search")
+)
+
+// SearchTerm is a single term in a free-text search.
+type SearchTerm struct {
+	Field   string `json:"field,omitempty"`   // Field to search. If empty, the term applies to all searchable fields.
+	Value   string `json:"value"`             // Text to search for.
+	Phrase  bool   `json:"phrase,omitempty"`  // If true, the value was quoted and should be matched as a whole.
+	Exclude bool   `json:"exclude,omitempty"` // If true, results must not match the term.
+}
+
+// String returns the term in the form read by ParseSearch, e.g. -title:"spaghetti bolognese".
+func (term SearchTerm) String() string {
+	s := ""
+	if term.Exclude {
+		s = "-"
+	}
+	if term.Field != "" {
+		s += term.Field + ":"
+	}
+	if term.Phrase || term.Value == "" || strings.ContainsAny(term.Value, " \"") ||
+		term.Field == "" && (strings.HasPrefix(term.Value, "-") || scanSearchField(term.Value) > 0) {
+		return s + `"` + searchQuoteEscaper.Replace(term.Value) + `"`
+	}
+	return s + term.Value
+}
+
+var searchQuoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// Search represents a free-text search for, most likely, a database query.
+// Terms are combined with "and": results must match every included term and none of the excluded terms.
+type Search struct {
+	Terms []SearchTerm `json:"terms"`
+}
+
+// Expr returns a filter expression matching the search using like filters.
+// Each term matches if its field contains the value; a term without a field matches if any of the given fields contain it.
+// Terms without a field are ignored if no fields are given.
+// This function returns nil if there is nothing to match.
+func (search *Search) Expr(fields []string) FilterExpr {
+	and := And{}
+	for _, term := range search.Terms {
+		var expr FilterExpr
+		value := "%" + EscapeLike(term.Value) + "%"
+		if term.Field != "" {
+			expr = Filter{Field: term.Field, Operator: "like", Value: value}
+		} else if len(fields) == 1 {
+			expr = Filter{Field: fields[0], Operator: "like", Value: value}
+		} else if len(fields) > 1 {
+			or := Or{}
+			for _, field := range fields {
+				or = append(or, Filter{Field: field, Operator: "like", Value: value})
+			}
+			expr = or
+		} else {
+			continue
+		}
+		if term.Exclude {
+			expr = Not{Expr: expr}
+		}
+		and = append(and, expr)
+	}
+
+	switch len(and) {
+	case 0:
+		return nil
+	case 1:
+		return and[0]
+	}
+	return and
+}
+
+// Fields returns the terms that apply to a specific field, or to all fields if field is empty.
+func (search *Search) Fields(field string) []SearchTerm {
+	terms := []SearchTerm{}
+	for _, term := range search.Terms {
+		if term.Field == field {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// String returns the search in the form read by ParseSearch.
+func (search *Search) String() string {
+	terms := make([]string, len(search.Terms))
+	for i, term := range search.Terms {
+		terms[i] = term.String()
+	}
+	return strings.Join(terms, " ")
+}
+
+// Values returns the search as URL values, using the same options as ReadSearch.
+func (search *Search) Values(opt *ReadSearchOptions) url.Values {
+	opt = initSearchOptions(opt)
+
+	values := url.Values{}
+	if search != nil && len(search.Terms) > 0 {
+		values.Set(opt.Key, search.String())
+	}
+	return values
+}
+
+// ParseSearch tokenises a free-text search.
+//
+// Terms are separated by whitespace. A term may be:
+//
+//	pasta               A word
+//	"spaghetti sauce"   A phrase, matched as a whole. Use \" and \\ to include quotes and backslashes
+//	title:pasta         A word or phrase qualified by a field
+//	-mushroom           An excluded word or phrase, which may also be qualified
+//
+// If the search cannot be parsed, this function returns a *ParseError wrapping ErrInvalidSearch.
+func ParseSearch(s string) (*Search, error) {
+	search := &Search{Terms: []SearchTerm{}}
+	i := 0
+	for {
+		for i < len(s) && isSearchSpace(s[i]) {
+			i++
+		}
+		if i == len(s) {
+			return search, nil
+		}
+
+		term := SearchTerm{}
+		if s[i] == '-' {
+			term.Exclude = true
+			i++
+		}
+		if n := scanSearchField(s[i:]); n > 0 {
+			term.Field = s[i : i+n-1]
+			i += n
+		}
+
+		if i < len(s) && s[i] == '"' {
+			start := i
+			value := strings.Builder{}
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, NewParseError("", 0, s, start, ReasonUnterminatedQuote, ErrInvalidSearch)
+			}
+			i++
+			term.Value = value.String()
+			term.Phrase = true
+		} else {
+			start := i
+			for i < len(s) && !isSearchSpace(s[i]) {
+				i++
+			}
+			if i == start {
+				return nil, NewParseError("", 0, s, i, ReasonMissingValue, ErrInvalidSearch)
+			}
+			term.Value = s[start:i]
+		}
+
+		search.Terms = append(search.Terms, term)
+	}
+}
+
+// ReadSearchOptions configures the behaviour of ReadSearch.
+type ReadSearchOptions struct {
+	Key      string // Query string key. The default value is "q"
+	MaxTerms int    // If this is > 0, a maximum number of search terms is imposed
+}
+
+// ReadSearch parses URL values into a free-text search.
+// If there are multiple values for the key, their terms are combined.
+// This function returns nil if no search terms are found.
+func ReadSearch(values url.Values, opt *ReadSearchOptions) (*Search, error) {
+	search, errs := readSearch(values, initSearchOptions(opt))
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return search, nil
+}
+
+// readSearch parses URL values into a free-text search, collecting all errors.
+func readSearch(values url.Values, opt *ReadSearchOptions) (*Search, Errors) {
+	search := &Search{Terms: []SearchTerm{}}
+	errs := Errors{}
+	for i, s := range values[opt.Key] {
+		parsed, err := ParseSearch(s)
+		if err != nil {
+			errs = append(errs, WithKey(err, opt.Key, i))
+			continue
+		}
+		if opt.MaxTerms > 0 && len(search.Terms)+len(parsed.Terms) > opt.MaxTerms {
+			return nil, append(errs, NewParseError(opt.Key, i, s, 0, ReasonTooMany, ErrTooManyTerms))
+		}
+		search.Terms = append(search.Terms, parsed.Terms...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(search.Terms) > 0 {
+		return search, nil
+	}
+	return nil, nil
+}
+
+// ReadRequestSearch parses a request's query string into a free-text search.
+// This function returns nil if no search terms are found.
+func ReadRequestSearch(req *http.Request, opt *ReadSearchOptions) (*Search, error) {
+	return ReadSearch(req.URL.Query(), opt)
+}
+
+// ReadStringSearch parses a query string literal into a free-text search.
+// This function returns nil if no search terms are found.
+func ReadStringSearch(qs string, opt *ReadSearchOptions) (*Search, error) {
+	values, err := url.ParseQuery(qs)
+	if err != nil {
+		return nil, err
+	}
+	return ReadSearch(values, opt)
+}
+
+func isSearchSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// scanSearchField returns the length of a field qualifier at the start of a string, including the colon.
+// This function returns 0 if the string does not start with a qualifier followed by a value.
+func scanSearchField(s string) int {
+	n := scanField(s)
+	if n == 0 || n+1 >= len(s) || s[n] != ':' || isSearchSpace(s[n+1]) {
+		return 0
+	}
+	return n + 1
+}
+
+func initSearchOptions(opt *ReadSearchOptions) *ReadSearchOptions {
+	def := &ReadSearchOptions{
+		Key: "q",
+	}
+
+	if opt != nil {
+		if len(opt.Key) > 0 {
+			def.Key = opt.Key
+		}
+
+		if opt.MaxTerms > def.MaxTerms {
+			def.MaxTerms = opt.MaxTerms
+		}
+	}
+
+	return def
+}
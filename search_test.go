@@ -0,0 +1,145 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseSearch(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Output []SearchTerm
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Output: []SearchTerm{}},
+		{
+			Input:  "spaghetti  bolognese",
+			Output: []SearchTerm{{Value: "spaghetti"}, {Value: "bolognese"}},
+		},
+		{
+			Input:  `"spaghetti bolognese" -mushroom`,
+			Output: []SearchTerm{{Value: "spaghetti bolognese", Phrase: true}, {Value: "mushroom", Exclude: true}},
+		},
+		{
+			Input: `title:pasta -author:"Nan \"Pie\" Smith" 100%`,
+			Output: []SearchTerm{
+				{Field: "title", Value: "pasta"},
+				{Field: "author", Value: `Nan "Pie" Smith`, Phrase: true, Exclude: true},
+				{Value: "100%"},
+			},
+		},
+		{Input: "title: -", Err: ErrInvalidSearch},
+		{Input: `"spaghetti`, Err: ErrInvalidSearch},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		search, err := ParseSearch(tc.Input)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(search.Terms, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, search.Terms)
+		}
+
+		// Formatting the search must produce an equivalent search
+		reparsed, err := ParseSearch(search.String())
+		if err != nil {
+			t.Errorf("Expected no error parsing %q, got %v", search.String(), err)
+		} else if !reflect.DeepEqual(reparsed, search) {
+			t.Errorf("Expected %+v after parsing %q, got %+v", search, search.String(), reparsed)
+		}
+	}
+}
+
+func TestReadSearch(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Opt    *ReadSearchOptions
+		Output *Search
+		Err    error
+	}
+
+	testCases := []TestCase{
+		{Input: ""},
+		{Input: "q="},
+		{
+			Input:  "q=pasta&q=-mushroom",
+			Output: &Search{Terms: []SearchTerm{{Value: "pasta"}, {Value: "mushroom", Exclude: true}}},
+		},
+		{
+			Input:  "search=pasta&q=soup",
+			Opt:    &ReadSearchOptions{Key: "search"},
+			Output: &Search{Terms: []SearchTerm{{Value: "pasta"}}},
+		},
+		{Input: "q=a b c", Opt: &ReadSearchOptions{MaxTerms: 2}, Err: ErrTooManyTerms},
+		{Input: `q="a b`, Err: ErrInvalidSearch},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		search, err := ReadStringSearch(tc.Input, tc.Opt)
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+		if tc.Err != nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(search, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, search)
+		}
+	}
+}
+
+func TestSearchExpr(t *testing.T) {
+	type TestCase struct {
+		Input  string
+		Fields []string
+		Output FilterExpr
+	}
+
+	testCases := []TestCase{
+		{Input: "pasta"},
+		{
+			Input:  "pasta",
+			Fields: []string{"title"},
+			Output: Filter{Field: "title", Operator: "like", Value: "%pasta%"},
+		},
+		{
+			Input:  "-100% title:soup",
+			Fields: []string{"title", "body"},
+			Output: And{
+				Not{Expr: Or{
+					Filter{Field: "title", Operator: "like", Value: `%100\%%`},
+					Filter{Field: "body", Operator: "like", Value: `%100\%%`},
+				}},
+				Filter{Field: "title", Operator: "like", Value: "%soup%"},
+			},
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with fields %v", n, tc.Input, tc.Fields)
+
+		search, err := ParseSearch(tc.Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expr := search.Expr(tc.Fields); !reflect.DeepEqual(expr, tc.Output) {
+			t.Errorf("Expected %+v, got %+v", tc.Output, expr)
+		}
+	}
+}
@@ -27,6 +27,11 @@ type Sort struct {
 	Direction string `json:"direction"` // Direction in which to sort, namely asc or desc.
 }
 
+// String returns the sort in the form read by ReadSorts, e.g. "title asc".
+func (sort Sort) String() string {
+	return sort.Field + " " + sort.Direction
+}
+
 // Sorts is a slice of Sort structs.
 type Sorts []Sort
 
@@ -66,6 +71,32 @@ func (sorts Sorts) HasField(field string) bool {
 	return false
 }
 
+// Reverse returns a new Sorts slice with every direction reversed.
+// The original order of sorts is preserved.
+func (sorts Sorts) Reverse() Sorts {
+	rev := Sorts{}
+	for _, sort := range sorts {
+		if sort.Direction == "desc" {
+			sort.Direction = "asc"
+		} else {
+			sort.Direction = "desc"
+		}
+		rev = append(rev, sort)
+	}
+	return rev
+}
+
+// Values returns the sorts as URL values, using the same options as ReadSorts.
+func (sorts Sorts) Values(opt *ReadSortsOptions) url.Values {
+	opt = initSortsOptions(opt)
+
+	values := url.Values{}
+	for _, sort := range sorts {
+		values.Add(opt.Key, sort.String())
+	}
+	return values
+}
+
 // ReadRequestSorts parses a request's query string into a slice of sorts.
 // This function returns nil if no sorts are found.
 func ReadRequestSorts(req *http.Request, opt *ReadSortsOptions) (Sorts, error) {
@@ -75,21 +106,31 @@ func ReadRequestSorts(req *http.Request, opt *ReadSortsOptions) (Sorts, error) {
 // ReadSorts parses URL values into a slice of sorts.
 // This function returns nil if no sorts are found.
 func ReadSorts(values url.Values, opt *ReadSortsOptions) (Sorts, error) {
-	opt = initSortsOptions(opt)
+	sorts, errs := readSorts(values, initSortsOptions(opt))
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return sorts, nil
+}
 
+// readSorts parses URL values into a slice of sorts, collecting all errors.
+func readSorts(values url.Values, opt *ReadSortsOptions) (Sorts, Errors) {
 	if !values.Has(opt.Key) {
 		return nil, nil
 	}
 
 	if opt.MaxSorts > 0 && len(values[opt.Key]) > opt.MaxSorts {
-		return nil, ErrTooManySorts
+		return nil, Errors{NewParseError(opt.Key, opt.MaxSorts, values[opt.Key][opt.MaxSorts], 0, ReasonTooMany, ErrTooManySorts)}
 	}
 
 	sorts := []Sort{}
-	for _, sortStr := range values[opt.Key] {
+	errs := Errors{}
+	for i, sortStr := range values[opt.Key] {
 		match := sortRegexp.FindStringSubmatch(sortStr)
 		if match == nil {
-			return nil, ErrInvalidSort
+			offset, reason := diagnoseSort(sortStr)
+			errs = append(errs, NewParseError(opt.Key, i, sortStr, offset, reason, ErrInvalidSort))
+			continue
 		}
 
 		sort := Sort{
@@ -99,6 +140,9 @@ func ReadSorts(values url.Values, opt *ReadSortsOptions) (Sorts, error) {
 		sorts = append(sorts, sort)
 	}
 
+	if len(errs) > 0 {
+		return nil, errs
+	}
 	return sorts, nil
 }
 
@@ -112,6 +156,18 @@ func ReadStringSorts(qs string, opt *ReadSortsOptions) (Sorts, error) {
 	return ReadSorts(values, opt)
 }
 
+// diagnoseSort finds the offset and reason for a sort string not matching sortRegexp.
+func diagnoseSort(s string) (int, Reason) {
+	n := scanField(s)
+	if n == 0 || n < len(s) && s[n] != ' ' {
+		return n, ReasonBadField
+	}
+	if n == len(s) {
+		return n, ReasonBadDirection
+	}
+	return n + 1, ReasonBadDirection
+}
+
 func initSortsOptions(opt *ReadSortsOptions) *ReadSortsOptions {
 	def := &ReadSortsOptions{
 		Key: "sort",
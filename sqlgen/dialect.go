@@ -0,0 +1,147 @@
+package sqlgen
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// Dialect describes how SQL is written for a particular database.
+type Dialect interface {
+	Placeholder(n int) string          // Placeholder for the nth argument, counting from 1.
+	QuoteIdent(name string) string     // Quote an identifier. Qualified names such as table.column are quoted per segment.
+	LikeEscape() string                // String literal for the ESCAPE clause of a LIKE expression. The escape character must be a backslash.
+	Limit(limit, offset string) string // LIMIT/OFFSET clause. Either placeholder may be empty if not required.
+}
+
+// FullTextDialect is implemented by dialects that support full-text search.
+type FullTextDialect interface {
+	Dialect
+	FullText(columns []string, placeholder string) string // Predicate matching quoted columns against a full-text query argument.
+	FullTextQuery(terms []qs.SearchTerm) string           // Full-text query argument requiring all terms to match.
+}
+
+// Supported dialects.
+// MySQL and Postgres also implement FullTextDialect.
+var (
+	MySQL    Dialect = mysqlDialect{}
+	Postgres Dialect = postgresDialect{}
+	SQLite   Dialect = sqliteDialect{}
+)
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) QuoteIdent(name string) string {
+	return quoteIdent(name, "`")
+}
+
+func (mysqlDialect) LikeEscape() string {
+	return `'\\'`
+}
+
+func (mysqlDialect) Limit(limit, offset string) string {
+	if limit == "" {
+		// MySQL does not support OFFSET without LIMIT
+		limit = "18446744073709551615"
+	}
+	if offset == "" {
+		return "LIMIT " + limit
+	}
+	return "LIMIT " + limit + " OFFSET " + offset
+}
+
+func (mysqlDialect) FullText(columns []string, placeholder string) string {
+	return "MATCH(" + strings.Join(columns, ", ") + ") AGAINST(" + placeholder + " IN BOOLEAN MODE)"
+}
+
+func (mysqlDialect) FullTextQuery(terms []qs.SearchTerm) string {
+	words := make([]string, len(terms))
+	for i, term := range terms {
+		words[i] = "+" + fullTextPhrase(term.Value)
+	}
+	return strings.Join(words, " ")
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) QuoteIdent(name string) string {
+	return quoteIdent(name, `"`)
+}
+
+func (postgresDialect) LikeEscape() string {
+	return `'\'`
+}
+
+func (postgresDialect) Limit(limit, offset string) string {
+	clauses := []string{}
+	if limit != "" {
+		clauses = append(clauses, "LIMIT "+limit)
+	}
+	if offset != "" {
+		clauses = append(clauses, "OFFSET "+offset)
+	}
+	return strings.Join(clauses, " ")
+}
+
+func (postgresDialect) FullText(columns []string, placeholder string) string {
+	document := columns[0]
+	if len(columns) > 1 {
+		document = "concat_ws(' ', " + strings.Join(columns, ", ") + ")"
+	}
+	return "to_tsvector(" + document + ") @@ websearch_to_tsquery(" + placeholder + ")"
+}
+
+func (postgresDialect) FullTextQuery(terms []qs.SearchTerm) string {
+	words := make([]string, len(terms))
+	for i, term := range terms {
+		words[i] = fullTextPhrase(term.Value)
+	}
+	return strings.Join(words, " ")
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) QuoteIdent(name string) string {
+	return quoteIdent(name, `"`)
+}
+
+func (sqliteDialect) LikeEscape() string {
+	return `'\'`
+}
+
+func (sqliteDialect) Limit(limit, offset string) string {
+	if limit == "" {
+		// SQLite does not support OFFSET without LIMIT
+		limit = "-1"
+	}
+	if offset == "" {
+		return "LIMIT " + limit
+	}
+	return "LIMIT " + limit + " OFFSET " + offset
+}
+
+// fullTextPhrase quotes a search term so that operators within it are not interpreted.
+func fullTextPhrase(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, " ") + `"`
+}
+
+func quoteIdent(name, quote string) string {
+	segments := strings.Split(name, ".")
+	for i, segment := range segments {
+		segments[i] = quote + strings.ReplaceAll(segment, quote, quote+quote) + quote
+	}
+	return strings.Join(segments, ".")
+}
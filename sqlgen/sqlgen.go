@@ -0,0 +1,363 @@
+// Package sqlgen compiles qs query objects into parameterised SQL.
+package sqlgen
+
+import (
+	"strings"
+
+	"github.com/annybs/go-qs"
+)
+
+// Compiler compiles qs query objects into SQL fragments and arguments for use with database/sql.
+// All values are passed as arguments; only identifiers are written into SQL, and these are always quoted.
+type Compiler struct {
+	Dialect   Dialect
+	Columns   map[string]string // Maps field names to column names. Fields that are not mapped are used as column names as-is.
+	ArgOffset int               // Number of arguments already used in the enclosing query. Affects numbered placeholders only.
+
+	SearchFields []string // Fields matched by search terms that do not specify a field. If this is empty, such terms are ignored.
+	FullText     bool     // If true, search terms that do not specify a field use the dialect's full-text search. The dialect must implement FullTextDialect.
+}
+
+type builder struct {
+	c    *Compiler
+	sql  strings.Builder
+	args []any
+}
+
+// New creates a Compiler for the given dialect.
+func New(dialect Dialect) *Compiler {
+	return &Compiler{Dialect: dialect}
+}
+
+// Where compiles a filter expression into an SQL condition, excluding the WHERE keyword.
+// This function returns an empty string if the expression is nil.
+func (c *Compiler) Where(expr qs.FilterExpr) (string, []any, error) {
+	b := c.builder()
+	if expr == nil {
+		return "", nil, nil
+	}
+	if err := b.writeExpr(expr); err != nil {
+		return "", nil, err
+	}
+	return b.sql.String(), b.args, nil
+}
+
+// OrderBy compiles sorts into an SQL ordering, excluding the ORDER BY keywords.
+// This function returns an empty string if there are no sorts.
+func (c *Compiler) OrderBy(sorts qs.Sorts) (string, error) {
+	b := c.builder()
+	if err := b.writeSorts(sorts); err != nil {
+		return "", err
+	}
+	return b.sql.String(), nil
+}
+
+// Search compiles a search into an SQL condition, excluding the WHERE keyword.
+// This function returns an empty string if there is nothing to match.
+//
+// Terms are matched using LIKE, unless FullText is set (see Compiler).
+// Terms that specify a field are always matched using LIKE.
+func (c *Compiler) Search(search *qs.Search) (string, []any, error) {
+	b := c.builder()
+	if err := b.writeWhere(nil, search); err != nil {
+		return "", nil, err
+	}
+	return b.sql.String(), b.args, nil
+}
+
+// Limit compiles pagination into an SQL LIMIT/OFFSET clause.
+// This function returns an empty string if neither limit nor offset are set.
+func (c *Compiler) Limit(pag *qs.Pagination) (string, []any) {
+	b := c.builder()
+	b.writeLimit(pag)
+	return b.sql.String(), b.args
+}
+
+// Select compiles the root entity's fields in a projection into an SQL column list, excluding the SELECT keyword.
+// This function returns "*" if the projection does not restrict the root entity's fields.
+// Fields of joined entities are not included.
+func (c *Compiler) Select(fields qs.Fields) string {
+	names := fields.Get("")
+	if len(names) == 0 {
+		return "*"
+	}
+	columns := make([]string, len(names))
+	for i, name := range names {
+		columns[i] = c.column(name)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// Page compiles a page into SQL WHERE, ORDER BY and LIMIT clauses, including keywords.
+// Clauses that are not required are omitted, so this function may return an empty string.
+//
+// If the page has a filter expression, it is used in preference to flat filters.
+// If the page has a search, it is added to the WHERE clause (see Compiler.Search).
+//
+// If pagination has a cursor, a seek condition is added to the WHERE clause (see qs.Page.QueryExpr).
+// For a cursor that reads before its position, the ORDER BY clause is reversed; the caller must reverse the resulting rows.
+func (c *Compiler) Page(page *qs.Page) (string, []any, error) {
+	b := c.builder()
+	clauses := []string{}
+
+	expr, sorts, err := page.QueryExpr()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := b.writeWhere(expr, page.Search); err != nil {
+		return "", nil, err
+	}
+	if b.sql.Len() > 0 {
+		clauses = append(clauses, "WHERE "+b.flush())
+	}
+
+	if len(sorts) > 0 {
+		if err := b.writeSorts(sorts); err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, "ORDER BY "+b.flush())
+	}
+
+	if b.writeLimit(page.Pagination) {
+		clauses = append(clauses, b.flush())
+	}
+
+	return strings.Join(clauses, " "), b.args, nil
+}
+
+func (c *Compiler) builder() *builder {
+	return &builder{c: c}
+}
+
+func (c *Compiler) column(field string) string {
+	if column, ok := c.Columns[field]; ok {
+		field = column
+	}
+	return c.Dialect.QuoteIdent(field)
+}
+
+func (b *builder) arg(value any) string {
+	b.args = append(b.args, value)
+	return b.c.Dialect.Placeholder(b.c.ArgOffset + len(b.args))
+}
+
+func (b *builder) flush() string {
+	s := b.sql.String()
+	b.sql.Reset()
+	return s
+}
+
+// writeWhere writes a filter expression and search as a single condition.
+// Nothing is written if neither is required.
+func (b *builder) writeWhere(expr qs.FilterExpr, search *qs.Search) error {
+	if search == nil {
+		search = &qs.Search{}
+	}
+
+	fullText := []qs.SearchTerm{}
+	if b.c.FullText {
+		like := &qs.Search{}
+		for _, term := range search.Terms {
+			if term.Field == "" {
+				fullText = append(fullText, term)
+			} else {
+				like.Terms = append(like.Terms, term)
+			}
+		}
+		search = like
+	}
+
+	if searchExpr := search.Expr(b.c.SearchFields); searchExpr != nil {
+		and := qs.And{}
+		for _, node := range []qs.FilterExpr{expr, searchExpr} {
+			switch node := node.(type) {
+			case nil:
+			case qs.And:
+				and = append(and, node...)
+			default:
+				and = append(and, node)
+			}
+		}
+		if len(and) == 1 {
+			expr = and[0]
+		} else {
+			expr = and
+		}
+	}
+
+	if len(fullText) == 0 || len(b.c.SearchFields) == 0 {
+		if expr == nil {
+			return nil
+		}
+		return b.writeExpr(expr)
+	}
+
+	dialect, ok := b.c.Dialect.(FullTextDialect)
+	if !ok {
+		return qs.ErrUnsupportedSearch
+	}
+	columns := make([]string, len(b.c.SearchFields))
+	for i, field := range b.c.SearchFields {
+		columns[i] = b.c.column(field)
+	}
+
+	predicates := []string{}
+	if expr != nil {
+		if err := b.writeExpr(expr); err != nil {
+			return err
+		}
+		if _, ok := expr.(qs.Or); ok {
+			predicates = append(predicates, "("+b.flush()+")")
+		} else {
+			predicates = append(predicates, b.flush())
+		}
+	}
+
+	include := []qs.SearchTerm{}
+	for _, term := range fullText {
+		if term.Exclude {
+			query := dialect.FullTextQuery([]qs.SearchTerm{term})
+			predicates = append(predicates, "NOT ("+dialect.FullText(columns, b.arg(query))+")")
+		} else {
+			include = append(include, term)
+		}
+	}
+	if len(include) > 0 {
+		query := dialect.FullTextQuery(include)
+		predicates = append(predicates, dialect.FullText(columns, b.arg(query)))
+	}
+
+	b.sql.WriteString(strings.Join(predicates, " AND "))
+	return nil
+}
+
+func (b *builder) writeExpr(expr qs.FilterExpr) error {
+	switch node := expr.(type) {
+	case qs.Filter:
+		return b.writeFilter(node)
+	case qs.And:
+		return b.writeJunction([]qs.FilterExpr(node), " AND ", "1=1")
+	case qs.Or:
+		return b.writeJunction([]qs.FilterExpr(node), " OR ", "1=0")
+	case qs.Not:
+		b.sql.WriteString("NOT (")
+		if err := b.writeExpr(node.Expr); err != nil {
+			return err
+		}
+		b.sql.WriteString(")")
+		return nil
+	}
+	return qs.ErrUnsupportedExpr
+}
+
+func (b *builder) writeJunction(operands []qs.FilterExpr, sep, empty string) error {
+	if len(operands) == 0 {
+		b.sql.WriteString(empty)
+		return nil
+	}
+	for i, operand := range operands {
+		if i > 0 {
+			b.sql.WriteString(sep)
+		}
+		switch operand.(type) {
+		case qs.And, qs.Or:
+			b.sql.WriteString("(")
+			if err := b.writeExpr(operand); err != nil {
+				return err
+			}
+			b.sql.WriteString(")")
+		default:
+			if err := b.writeExpr(operand); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *builder) writeFilter(filter qs.Filter) error {
+	column := b.c.column(filter.Field)
+
+	switch filter.Operator {
+	case "eq":
+		b.sql.WriteString(column + " = " + b.arg(filter.Value))
+	case "neq":
+		b.sql.WriteString(column + " <> " + b.arg(filter.Value))
+	case "gt":
+		b.sql.WriteString(column + " > " + b.arg(filter.Value))
+	case "gte":
+		b.sql.WriteString(column + " >= " + b.arg(filter.Value))
+	case "lt":
+		b.sql.WriteString(column + " < " + b.arg(filter.Value))
+	case "lte":
+		b.sql.WriteString(column + " <= " + b.arg(filter.Value))
+	case "in", "not in":
+		values, _ := filter.StringSlice()
+		placeholders := []string{}
+		for _, value := range values {
+			placeholders = append(placeholders, b.arg(value))
+		}
+		op := " IN ("
+		if filter.Operator == "not in" {
+			op = " NOT IN ("
+		}
+		b.sql.WriteString(column + op + strings.Join(placeholders, ", ") + ")")
+	case "like", "not like", "co", "sw", "ew":
+		op := " LIKE "
+		if filter.Operator == "not like" {
+			op = " NOT LIKE "
+		}
+		b.sql.WriteString(column + op + b.arg(likePattern(filter)) + " ESCAPE " + b.c.Dialect.LikeEscape())
+	case "pr":
+		b.sql.WriteString(column + " IS NOT NULL")
+	default:
+		return qs.ErrUnsupportedOperator
+	}
+	return nil
+}
+
+func (b *builder) writeSorts(sorts qs.Sorts) error {
+	for i, sort := range sorts {
+		if i > 0 {
+			b.sql.WriteString(", ")
+		}
+		switch sort.Direction {
+		case "asc":
+			b.sql.WriteString(b.c.column(sort.Field) + " ASC")
+		case "desc":
+			b.sql.WriteString(b.c.column(sort.Field) + " DESC")
+		default:
+			return qs.ErrInvalidSort
+		}
+	}
+	return nil
+}
+
+func (b *builder) writeLimit(pag *qs.Pagination) bool {
+	if pag == nil || (pag.Limit <= 0 && pag.Offset <= 0) {
+		return false
+	}
+
+	limit := ""
+	if pag.Limit > 0 {
+		limit = b.arg(pag.Limit)
+	}
+	offset := ""
+	if pag.Offset > 0 {
+		offset = b.arg(pag.Offset)
+	}
+	b.sql.WriteString(b.c.Dialect.Limit(limit, offset))
+	return true
+}
+
+var sqlLikeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likePattern converts a qs like pattern into an SQL LIKE pattern using backslash as the escape character.
+func likePattern(filter qs.Filter) string {
+	segments := filter.LikeSegments()
+	for i, segment := range segments {
+		segments[i] = sqlLikeEscaper.Replace(segment)
+	}
+	return strings.Join(segments, "%")
+}
@@ -0,0 +1,190 @@
+package sqlgen
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/annybs/go-qs"
+)
+
+func TestCompilerPage(t *testing.T) {
+	type TestCase struct {
+		Input    string
+		Opt      *qs.ReadPageOptions
+		Compiler *Compiler
+		SQL      string
+		Args     []any
+		Err      error
+	}
+
+	testCases := []TestCase{
+		{Input: "", Compiler: New(Postgres), SQL: ""},
+		{
+			Input:    "filter=title eq Bolognese&filter=serves gte 4",
+			Compiler: New(Postgres),
+			SQL:      `WHERE "title" = $1 AND "serves" >= $2`,
+			Args:     []any{"Bolognese", "4"},
+		},
+		{
+			Input:    "filter=title eq Bolognese&filter=serves gte 4",
+			Compiler: New(MySQL),
+			SQL:      "WHERE `title` = ? AND `serves` >= ?",
+			Args:     []any{"Bolognese", "4"},
+		},
+		{
+			Input:    "filter=author in 1,2,3&filter=status not in draft,deleted&sort=serves desc&sort=title asc",
+			Compiler: New(Postgres),
+			SQL:      `WHERE "author" IN ($1, $2, $3) AND "status" NOT IN ($4, $5) ORDER BY "serves" DESC, "title" ASC`,
+			Args:     []any{"1", "2", "3", "draft", "deleted"},
+		},
+		{
+			Input:    `filter=title like %25100\%25_pure%25&filter=title not like Spag%25`,
+			Compiler: New(Postgres),
+			SQL:      `WHERE "title" LIKE $1 ESCAPE '\' AND "title" NOT LIKE $2 ESCAPE '\'`,
+			Args:     []any{`%100\%\_pure%`, "Spag%"},
+		},
+		{
+			Input:    "filter=title co 100%25&filter=title sw Spag&filter=author pr",
+			Compiler: New(Postgres),
+			SQL:      `WHERE "title" LIKE $1 ESCAPE '\' AND "title" LIKE $2 ESCAPE '\' AND "author" IS NOT NULL`,
+			Args:     []any{`%100\%%`, "Spag%"},
+		},
+		{
+			Input:    "filter=title like %25a%25",
+			Compiler: New(MySQL),
+			SQL:      "WHERE `title` LIKE ? ESCAPE '\\\\'",
+			Args:     []any{"%a%"},
+		},
+		{
+			Input:    "filter=(status eq draft or author eq 3) and not serves lt 4",
+			Opt:      &qs.ReadPageOptions{Filter: &qs.ReadFiltersOptions{Expr: true}},
+			Compiler: New(Postgres),
+			SQL:      `WHERE ("status" = $1 OR "author" = $2) AND NOT ("serves" < $3)`,
+			Args:     []any{"draft", "3", "4"},
+		},
+		{
+			Input:    "filter=title eq Bolognese&limit=10&offset=20",
+			Compiler: &Compiler{Dialect: Postgres, ArgOffset: 1, Columns: map[string]string{"title": "r.title"}},
+			SQL:      `WHERE "r"."title" = $2 LIMIT $3 OFFSET $4`,
+			Args:     []any{"Bolognese", 10, 20},
+		},
+		{
+			Input:    "filter=vegan eq true&sort=serves desc&sort=id asc&limit=10&before=eyJ2Ijp7ImlkIjoiMTAiLCJzZXJ2ZXMiOiI0In19",
+			Compiler: New(Postgres),
+			SQL:      `WHERE "vegan" = $1 AND ("serves" > $2 OR ("serves" = $3 AND "id" < $4)) ORDER BY "serves" ASC, "id" DESC LIMIT $5`,
+			Args:     []any{"true", "4", "4", "10", 10},
+		},
+		{Input: "offset=20", Compiler: New(Postgres), SQL: "OFFSET $1", Args: []any{20}},
+		{Input: "offset=20", Compiler: New(SQLite), SQL: "LIMIT -1 OFFSET ?", Args: []any{20}},
+		{Input: "limit=5", Compiler: New(MySQL), SQL: "LIMIT ?", Args: []any{5}},
+		{Input: "q=pasta", Opt: &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}}, Compiler: New(Postgres), SQL: ""},
+		{
+			Input:    "q=pasta -title:soup&filter=serves gte 4&limit=5",
+			Opt:      &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}},
+			Compiler: &Compiler{Dialect: Postgres, SearchFields: []string{"title", "body"}},
+			SQL:      `WHERE "serves" >= $1 AND ("title" LIKE $2 ESCAPE '\' OR "body" LIKE $3 ESCAPE '\') AND NOT ("title" LIKE $4 ESCAPE '\') LIMIT $5`,
+			Args:     []any{"4", "%pasta%", "%pasta%", "%soup%", 5},
+		},
+		{
+			Input:    `q="spaghetti bolognese" -mushroom title:pasta&filter=vegan eq true or serves gte 4`,
+			Opt:      &qs.ReadPageOptions{Filter: &qs.ReadFiltersOptions{Expr: true}, Search: &qs.ReadSearchOptions{}},
+			Compiler: &Compiler{Dialect: Postgres, SearchFields: []string{"title", "body"}, FullText: true},
+			SQL:      `WHERE ("vegan" = $1 OR "serves" >= $2) AND "title" LIKE $3 ESCAPE '\' AND NOT (to_tsvector(concat_ws(' ', "title", "body")) @@ websearch_to_tsquery($4)) AND to_tsvector(concat_ws(' ', "title", "body")) @@ websearch_to_tsquery($5)`,
+			Args:     []any{"true", "4", "%pasta%", `"mushroom"`, `"spaghetti bolognese"`},
+		},
+		{
+			Input:    `q=c%2B%2B "nan's pie"`,
+			Opt:      &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}},
+			Compiler: &Compiler{Dialect: MySQL, SearchFields: []string{"title"}, FullText: true},
+			SQL:      "WHERE MATCH(`title`) AGAINST(? IN BOOLEAN MODE)",
+			Args:     []any{`+"c++" +"nan's pie"`},
+		},
+		{
+			Input:    "q=pasta",
+			Opt:      &qs.ReadPageOptions{Search: &qs.ReadSearchOptions{}},
+			Compiler: &Compiler{Dialect: SQLite, SearchFields: []string{"title"}, FullText: true},
+			Err:      qs.ErrUnsupportedSearch,
+		},
+		{
+			Input:    `filter=title" eq x`,
+			Compiler: New(Postgres),
+			Err:      qs.ErrInvalidFilter,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q with options %+v", n, tc.Input, tc.Opt)
+
+		page, err := qs.ReadStringPage(tc.Input, tc.Opt)
+		if err == nil {
+			var sql string
+			var args []any
+			sql, args, err = tc.Compiler.Page(page)
+			if err == nil {
+				if sql != tc.SQL {
+					t.Errorf("Expected SQL %q, got %q", tc.SQL, sql)
+				}
+				if len(args) > 0 || len(tc.Args) > 0 {
+					if !reflect.DeepEqual(args, tc.Args) {
+						t.Errorf("Expected args %v, got %v", tc.Args, args)
+					}
+				}
+			}
+		}
+
+		if !errors.Is(err, tc.Err) {
+			t.Errorf("Expected error %v, got %v", tc.Err, err)
+		}
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	c := New(Postgres)
+
+	if _, _, err := c.Where(qs.Filter{Field: "title", Operator: "is", Value: "x"}); !errors.Is(err, qs.ErrUnsupportedOperator) {
+		t.Errorf("Expected error %v, got %v", qs.ErrUnsupportedOperator, err)
+	}
+
+	if _, err := c.OrderBy(qs.Sorts{{Field: "title", Direction: "up"}}); !errors.Is(err, qs.ErrInvalidSort) {
+		t.Errorf("Expected error %v, got %v", qs.ErrInvalidSort, err)
+	}
+
+	sql, _, _ := c.Where(qs.Filter{Field: `a"b`, Operator: "eq", Value: "x"})
+	if sql != `"a""b" = $1` {
+		t.Errorf("Expected identifier to be escaped, got %q", sql)
+	}
+}
+
+func TestCompilerSelect(t *testing.T) {
+	type TestCase struct {
+		Input    string
+		Compiler *Compiler
+		SQL      string
+	}
+
+	testCases := []TestCase{
+		{Input: "", Compiler: New(Postgres), SQL: "*"},
+		{Input: "fields[author]=name", Compiler: New(Postgres), SQL: "*"},
+		{Input: "fields=title,serves", Compiler: New(Postgres), SQL: `"title", "serves"`},
+		{Input: "fields=title,created", Compiler: New(MySQL), SQL: "`title`, `created`"},
+		{
+			Input:    "fields=title,created",
+			Compiler: &Compiler{Dialect: SQLite, Columns: map[string]string{"created": "created_at"}},
+			SQL:      `"title", "created_at"`,
+		},
+	}
+
+	for n, tc := range testCases {
+		t.Logf("(%d) Testing %q", n, tc.Input)
+
+		fields, err := qs.ReadStringFields(tc.Input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sql := tc.Compiler.Select(fields); sql != tc.SQL {
+			t.Errorf("Expected %q, got %q", tc.SQL, sql)
+		}
+	}
+}
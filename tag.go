@@ -0,0 +1,181 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Schema error.
+var (
+	ErrInvalidTag    = errors.New("invalid qs tag")
+	ErrInvalidStruct = errors.New("value is not a struct")
+)
+
+// MustSchemaOf is like SchemaOf but panics on error.
+// It is intended for initialising package-level schema variables.
+func MustSchemaOf(v any) *Schema {
+	schema, err := SchemaOf(v)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// SchemaOf derives a Schema from the qs tags on a struct's fields.
+// v may be a struct, a pointer to a struct or a reflect.Type of either.
+// Fields without a qs tag, or tagged with "-", are not included in the schema.
+//
+// A tag consists of the public field name followed by comma-separated options:
+//
+//	filter              The field can be filtered using any operator
+//	filter=eq|like      The field can be filtered using the listed operators
+//	sort                The field can be sorted
+//	select              The field can be included in a projection
+//	column=name         The internal column name. If omitted, the db tag is used, if present
+//	text                The field is analysed text, so its type is TypeText
+//	join                The field is a permitted join. If it is a struct (or pointer or slice thereof), its schema is derived too
+//
+// If the public name is omitted, the json tag name or Go field name is used instead.
+// Unless the text option is given, the field type is derived from the Go type: integers map to TypeInt, floats to TypeFloat, bools to TypeBool and anything else to TypeString.
+// Fields of embedded structs are included as if they were declared on the outer struct.
+func SchemaOf(v any) (*Schema, error) {
+	t, ok := v.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(v)
+	}
+	t = derefType(t)
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrInvalidStruct
+	}
+	return schemaOf(t, map[reflect.Type]*Schema{})
+}
+
+func schemaOf(t reflect.Type, seen map[reflect.Type]*Schema) (*Schema, error) {
+	if schema, ok := seen[t]; ok {
+		return schema, nil
+	}
+
+	schema := &Schema{
+		Fields: map[string]Field{},
+		Joins:  map[string]*Schema{},
+	}
+	seen[t] = schema
+
+	if err := addStructFields(schema, t, seen); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func addStructFields(schema *Schema, t reflect.Type, seen map[reflect.Type]*Schema) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag, ok := sf.Tag.Lookup("qs")
+		if !ok {
+			if sf.Anonymous && derefType(sf.Type).Kind() == reflect.Struct {
+				if err := addStructFields(schema, derefType(sf.Type), seen); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tag == "-" || !sf.IsExported() {
+			continue
+		}
+
+		name, options := parseTag(tag)
+		if name == "" {
+			name = fieldName(sf)
+		}
+
+		field := Field{Type: fieldType(sf.Type)}
+		isField := false
+		for _, option := range options {
+			key, value, _ := strings.Cut(option, "=")
+			switch key {
+			case "filter":
+				field.Filter = true
+				isField = true
+				if value != "" {
+					for _, op := range strings.Split(value, "|") {
+						if !isFilterOperator(op) {
+							return ErrInvalidTag
+						}
+						field.Operators = append(field.Operators, op)
+					}
+				}
+			case "sort":
+				field.Sort = true
+				isField = true
+			case "select":
+				field.Select = true
+				isField = true
+			case "column":
+				field.Column = value
+			case "text":
+				field.Type = TypeText
+			case "join":
+				elem := derefType(sf.Type)
+				if elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+					elem = derefType(elem.Elem())
+				}
+				var join *Schema
+				if elem.Kind() == reflect.Struct {
+					var err error
+					join, err = schemaOf(elem, seen)
+					if err != nil {
+						return err
+					}
+				}
+				schema.Joins[name] = join
+			default:
+				return ErrInvalidTag
+			}
+		}
+
+		if isField {
+			if field.Column == "" {
+				if db, _ := parseTag(sf.Tag.Get("db")); db != "" && db != "-" {
+					field.Column = db
+				}
+			}
+			schema.Fields[name] = field
+		}
+	}
+	return nil
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// fieldName returns the name of a struct field as it appears in JSON.
+func fieldName(sf reflect.StructField) string {
+	if name, _ := parseTag(sf.Tag.Get("json")); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func fieldType(t reflect.Type) FieldType {
+	switch derefType(t).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TypeInt
+	case reflect.Float32, reflect.Float64:
+		return TypeFloat
+	case reflect.Bool:
+		return TypeBool
+	}
+	return TypeString
+}
+
+func parseTag(tag string) (string, []string) {
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
@@ -0,0 +1,101 @@
+package qs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testAuthor struct {
+	ID      int          `qs:"id,filter=eq|in,sort"`
+	Name    string       `qs:"name,filter,sort" db:"display_name"`
+	Recipes []testRecipe `qs:"recipes,join"`
+}
+
+type testTimestamps struct {
+	Created int64 `qs:"created,sort,column=created_at"`
+}
+
+type testRecipe struct {
+	testTimestamps
+	Title    string      `json:"title" qs:",filter=eq|like,sort"`
+	Serves   int         `qs:"serves,filter,sort"`
+	Rating   *float64    `qs:"rating,sort,select"`
+	Vegan    bool        `qs:"vegan,filter=eq"`
+	Body     string      `qs:"body,select,text"`
+	Secret   string      `qs:"-"`
+	Internal string      // Not tagged, so not queryable
+	Author   *testAuthor `qs:"author,join"`
+	Tags     []string    `qs:"tags,join"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(&testRecipe{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	fields := map[string]Field{
+		"created": {Type: TypeInt, Sort: true, Column: "created_at"},
+		"title":   {Type: TypeString, Filter: true, Operators: []string{"eq", "like"}, Sort: true},
+		"serves":  {Type: TypeInt, Filter: true, Sort: true},
+		"rating":  {Type: TypeFloat, Sort: true, Select: true},
+		"vegan":   {Type: TypeBool, Filter: true, Operators: []string{"eq"}},
+		"body":    {Type: TypeText, Select: true},
+	}
+	if !reflect.DeepEqual(schema.Fields, fields) {
+		t.Errorf("Expected fields %+v, got %+v", fields, schema.Fields)
+	}
+
+	if len(schema.Joins) != 2 {
+		t.Errorf("Expected 2 joins, got %d", len(schema.Joins))
+	}
+	if schema.Joins["tags"] != nil {
+		t.Error("Expected nil schema for tags join")
+	}
+
+	author := schema.Joins["author"]
+	if author == nil {
+		t.Fatal("Expected schema for author join")
+	}
+	if author.Column("name") != "display_name" {
+		t.Errorf("Expected display_name column for author name, got %s", author.Column("name"))
+	}
+	if author.Joins["recipes"] != schema {
+		t.Error("Expected recursive join to reuse recipe schema")
+	}
+
+	columns := map[string]string{"created": "created_at"}
+	if !reflect.DeepEqual(schema.Columns(), columns) {
+		t.Errorf("Expected columns %v, got %v", columns, schema.Columns())
+	}
+
+	_, err = ReadStringPage("filter=serves gte 4&sort=created desc&join=author", &ReadPageOptions{Schema: schema})
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	_, err = ReadStringPage("filter=vegan neq true", &ReadPageOptions{Schema: schema})
+	if !errors.Is(err, ErrOperatorNotAllowed) {
+		t.Errorf("Expected error %v, got %v", ErrOperatorNotAllowed, err)
+	}
+}
+
+func TestSchemaOfErrors(t *testing.T) {
+	type badOperator struct {
+		Title string `qs:"title,filter=eq|is"`
+	}
+	type badOption struct {
+		Title string `qs:"title,search"`
+	}
+
+	if _, err := SchemaOf(1); !errors.Is(err, ErrInvalidStruct) {
+		t.Errorf("Expected error %v, got %v", ErrInvalidStruct, err)
+	}
+	if _, err := SchemaOf(badOperator{}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected error %v, got %v", ErrInvalidTag, err)
+	}
+	if _, err := SchemaOf(badOption{}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected error %v, got %v", ErrInvalidTag, err)
+	}
+}